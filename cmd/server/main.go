@@ -1,35 +1,302 @@
 package main
 
 import (
+    "context"
     "log"
+    "log/slog"
+    "net"
     "net/http"
+    "os"
+    "os/signal"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+
+    "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+    "github.com/stanek-michal/go-ai-summarizer/internal/semantic"
+    "github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+    "github.com/stanek-michal/go-ai-summarizer/internal/tasklog"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+    "github.com/stanek-michal/go-ai-summarizer/internal/tracing"
     "github.com/stanek-michal/go-ai-summarizer/internal/transport"
     "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
 )
 
 func main() {
+    // Structured logging at LOG_LEVEL (debug, info, warn or error, default info) in LOG_FORMAT
+    // (text or json, default text), lines of the log package go through it too
+    slog.SetDefault(slog.New(newLogHandler()))
+
+    // Traces are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT (or
+    // OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set, e.g. http://localhost:4318
+    var tracerProvider *sdktrace.TracerProvider
+    if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+        exporter, err := tracing.NewOTLPExporter(context.Background())
+        if err != nil {
+            log.Fatal("Failed to create OTLP exporter: ", err)
+        }
+        if tracerProvider, err = tracing.Setup(context.Background(), exporter); err != nil {
+            log.Fatal("Failed to set up tracing: ", err)
+        }
+    }
+
+    // Load summary prompt templates
+    templates, err := prompts.NewStore("./templates")
+    if err != nil {
+        log.Fatal("Failed to open templates directory: ", err)
+    }
+
+    // Load enrolled voices for automatic speaker labeling
+    registry, err := speakers.NewRegistry("./speakers/registry.json", speakers.DefaultThreshold)
+    if err != nil {
+        log.Fatal("Failed to load speaker registry: ", err)
+    }
+
+    // Open the archive of completed meetings
+    meetings, err := archive.Open("./archive")
+    if err != nil {
+        log.Fatal("Failed to open meeting archive: ", err)
+    }
+
+    // The local LLM server is shared by summarization and Q&A, and stopped after 5 minutes idle.
+    // It listens on LLAMA_PORT (default 8000) of the loopback interface.
+    llamaPort := processing.DefaultLlamaPort
+    if value := os.Getenv("LLAMA_PORT"); value != "" {
+        if llamaPort, err = strconv.Atoi(value); err != nil || llamaPort <= 0 || llamaPort > 65535 {
+            log.Fatal("Invalid LLAMA_PORT: ", value)
+        }
+    }
+    llm := processing.NewLLM(5 * time.Minute, llamaPort)
+
+    // Semantic Q&A needs an OpenAI-compatible embeddings server
+    var index *semantic.Index
+    if url := os.Getenv("EMBEDDINGS_URL"); url != "" {
+        index, err = semantic.OpenIndex("./index", meetings, semantic.NewHTTPEmbedder(url, os.Getenv("EMBEDDINGS_MODEL")))
+        if err != nil {
+            log.Fatal("Failed to open semantic index: ", err)
+        }
+    }
+
+    // Results are kept for RESULT_TTL after processing (default 30 days, 0 keeps them until deleted)
+    // and the archive is limited to ARCHIVE_MAX_MB (default 1024, 0 for no limit)
+    retention := queue.RetentionPolicy{TTL: 30 * 24 * time.Hour, MaxBytes: 1024 << 20}
+    if value := os.Getenv("RESULT_TTL"); value != "" {
+        if retention.TTL, err = time.ParseDuration(value); err != nil || retention.TTL < 0 {
+            log.Fatal("Invalid RESULT_TTL: ", value)
+        }
+    }
+    if value := os.Getenv("ARCHIVE_MAX_MB"); value != "" {
+        maxMB, err := strconv.ParseInt(value, 10, 64)
+        if err != nil || maxMB < 0 {
+            log.Fatal("Invalid ARCHIVE_MAX_MB: ", value)
+        }
+        retention.MaxBytes = maxMB << 20
+    }
+
+    // Load user accounts
+    users, err := auth.NewUsers("./users/users.json")
+    if err != nil {
+        log.Fatal("Failed to load user accounts: ", err)
+    }
+    // The first account is an admin, ADMIN_USERS (comma-separated usernames) makes others admins
+    for _, name := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+        if name = strings.TrimSpace(name); name == "" {
+            continue
+        }
+        user, err := users.GetByName(name)
+        if err != nil {
+            log.Fatal("Invalid ADMIN_USERS: ", name, ": ", err)
+        }
+        if err := users.SetAdmin(user.ID, true); err != nil {
+            log.Fatal("Failed to make ", name, " an admin: ", err)
+        }
+    }
+
+    // Teams share meeting libraries between users
+    teamStore, err := teams.NewTeams("./users/teams.json")
+    if err != nil {
+        log.Fatal("Failed to load teams: ", err)
+    }
+
+    // On shutdown the running task may finish for SHUTDOWN_TIMEOUT (default 1 minute), then it
+    // and the waiting ones are checkpointed to CHECKPOINT_DIR and queued again on the next start
+    shutdownTimeout := time.Minute
+    if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+        if shutdownTimeout, err = time.ParseDuration(value); err != nil || shutdownTimeout < 0 {
+            log.Fatal("Invalid SHUTDOWN_TIMEOUT: ", value)
+        }
+    }
+    checkpointDir := os.Getenv("CHECKPOINT_DIR")
+    if checkpointDir == "" {
+        checkpointDir = "./checkpoint"
+    }
+
+    // Every task gets a log file, served by GET /tasks/{id}/logs and deleted with the task
+    taskLogs, err := tasklog.NewStore("./logs")
+    if err != nil {
+        log.Fatal("Failed to open task log directory: ", err)
+    }
+
     // Initialize the queue
-    taskQueue := queue.NewQueue()
+    taskQueue := queue.NewQueue(processing.NewProcessor(registry, llm), templates, meetings, retention, taskLogs)
+    if err := taskQueue.Restore(checkpointDir); err != nil {
+        log.Fatal("Failed to restore checkpointed tasks: ", err)
+    }
     go taskQueue.StartProcessing()
+    go taskQueue.StartRetention()
+    if index != nil {
+        go index.StartIndexing()
+    }
 
     // Initialize the HTTP server
-    httpHandler := transport.NewHTTPHandler(taskQueue)
+    httpHandler := transport.NewHTTPHandler(taskQueue, templates, registry, meetings, llm, index)
+    // Local accounts, logged in with a session cookie or an API key. Only the first account
+    // can sign up unless ALLOW_SIGNUP=true.
+    httpHandler.Users = users
+    httpHandler.Sessions = auth.NewSessions(users)
+    httpHandler.AllowSignup = os.Getenv("ALLOW_SIGNUP") == "true"
+    httpHandler.Teams = teamStore
+    // /readyz fails while the temp directory has less than MIN_FREE_DISK_MB (default 1024) free
+    httpHandler.MinFreeDisk = 1024 << 20
+    if value := os.Getenv("MIN_FREE_DISK_MB"); value != "" {
+        minFreeMB, err := strconv.ParseInt(value, 10, 64)
+        if err != nil || minFreeMB < 0 {
+            log.Fatal("Invalid MIN_FREE_DISK_MB: ", value)
+        }
+        httpHandler.MinFreeDisk = minFreeMB << 20
+    }
+    authenticator := auth.Chain{httpHandler.Sessions, auth.APIKeyAuthenticator{Users: users}}
 
-    // Setup handler for processing related endpoints
-    http.HandleFunc("/upload", httpHandler.HandleFileUpload)
-    http.HandleFunc("/status", httpHandler.HandleStatus)
-    http.HandleFunc("/counter", httpHandler.HandleCounter)
-    http.HandleFunc("/tasksInQueue", httpHandler.HandleTasksInQueue)
-    http.HandleFunc("/get-testimonials", httpHandler.GetTestimonials)
-    http.HandleFunc("/submit-testimonial", httpHandler.SubmitTestimonial)
+    // Versioned REST API
+    http.Handle("/api/v1/", http.StripPrefix("/api/v1", httpHandler.APIv1()))
+    openAPI, err := httpHandler.OpenAPI()
+    if err != nil {
+        log.Fatal("Invalid API description: ", err)
+    }
+    http.HandleFunc("/api/openapi.json", openAPI)
 
-    // Serve static files
-    fs := http.FileServer(http.Dir("./web/static"))
-    http.Handle("/", fs) // Serve index.html at root
+    // Prometheus metrics
+    metrics.RegisterQueue(taskQueue.CountByStatus)
+    http.Handle("/metrics", metrics.Handler())
+
+    // Liveness and readiness probes
+    health := httpHandler.Health()
+    http.Handle("/healthz", health)
+    http.Handle("/readyz", health)
+
+    // OpenAI-compatible transcription API
+    http.Handle("/v1/", httpHandler.OpenAI())
+
+    // Original paths used by the web page, anything else is served from the static files
+    legacy := httpHandler.Legacy()
+    legacy.NotFound = http.FileServer(http.Dir("./web/static"))
+    http.Handle("/", legacy)
+
+    // gRPC API with the same accounts, on its own port
+    grpcAddr := os.Getenv("GRPC_ADDR")
+    if grpcAddr == "" {
+        grpcAddr = ":9002"
+    }
+    grpcListener, err := net.Listen("tcp", grpcAddr)
+    if err != nil {
+        log.Fatal("Failed to listen for gRPC: ", err)
+    }
+    grpcServer := httpHandler.GRPCServer(authenticator)
+    go func() {
+        slog.Info("Starting gRPC server", "addr", grpcAddr)
+        if err := grpcServer.Serve(grpcListener); err != nil {
+            log.Fatal("gRPC Serve: ", err)
+        }
+    }()
 
     // Start the server
-    log.Println("Starting server on :9001")
-    if err := http.ListenAndServe(":9001", nil); err != nil {
-        log.Fatal("ListenAndServe: ", err)
+    // Every request is traced except metrics scrapes and probes, routers rename the span after their route
+    traced := otelhttp.NewHandler(auth.Middleware(authenticator, http.DefaultServeMux), "HTTP",
+        otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+        otelhttp.WithFilter(func(r *http.Request) bool {
+            return r.URL.Path != "/metrics" && r.URL.Path != "/healthz" && r.URL.Path != "/readyz"
+        }))
+    server := &http.Server{Addr: ":9001", Handler: traced}
+    go func() {
+        slog.Info("Starting server", "addr", server.Addr)
+        if err := server.ListenAndServe(); err != http.ErrServerClosed {
+            log.Fatal("ListenAndServe: ", err)
+        }
+    }()
+
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+    slog.Info("Shutting down", "signal", <-signals)
+
+    // Refuse new uploads and let the running task finish
+    taskQueue.Close()
+    ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    if err := taskQueue.Wait(ctx); err != nil {
+        slog.Warn("Task still running, interrupting it", "timeout", shutdownTimeout)
+    }
+    cancel()
+    if err := taskQueue.Checkpoint(checkpointDir); err != nil {
+        slog.Error("Failed to checkpoint unfinished tasks", "err", err)
+    }
+
+    // Kill whisperx, the python scripts and the LLM server, give the interrupted task time
+    // to clean up its files
+    processing.KillChildren()
+    llm.Close()
+    ctx, cancel = context.WithTimeout(context.Background(), 10 * time.Second)
+    defer cancel()
+    taskQueue.Wait(ctx)
+
+    // Finish the requests in flight, streams that are still open are cut off
+    stopped := make(chan struct{})
+    go func() {
+        grpcServer.GracefulStop()
+        close(stopped)
+    }()
+    if err := server.Shutdown(ctx); err != nil {
+        slog.Error("HTTP shutdown failed", "err", err)
+        server.Close()
+    }
+    select {
+    case <-stopped:
+    case <-ctx.Done():
+        grpcServer.Stop()
+    }
+    if tracerProvider != nil {
+        // Flush the spans of the last requests and tasks
+        ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+        defer cancel()
+        if err := tracerProvider.Shutdown(ctx); err != nil {
+            slog.Error("Failed to export remaining spans", "err", err)
+        }
+    }
+    slog.Info("Server stopped")
+}
+
+// newLogHandler returns the log handler configured by LOG_LEVEL and LOG_FORMAT
+func newLogHandler() slog.Handler {
+    var level slog.Level
+    if value := os.Getenv("LOG_LEVEL"); value != "" {
+        if err := level.UnmarshalText([]byte(value)); err != nil {
+            log.Fatal("Invalid LOG_LEVEL: ", value)
+        }
+    }
+    options := &slog.HandlerOptions{Level: level}
+    switch format := os.Getenv("LOG_FORMAT"); format {
+    case "", "text":
+        return slog.NewTextHandler(os.Stderr, options)
+    case "json":
+        return slog.NewJSONHandler(os.Stderr, options)
+    default:
+        log.Fatal("Invalid LOG_FORMAT: ", format)
+        return nil
     }
 }
@@ -0,0 +1,342 @@
+package archive
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+var ErrNotFound = errors.New("meeting not found in archive")
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// record is the on-disk format of an archived meeting
+type record struct {
+    Task       types.Task
+    TokenHash  string // Task.TokenHash, not serialized with the task
+    ArchivedAt time.Time
+}
+
+// Meeting is the listing entry of an archived meeting
+type Meeting struct {
+    TaskID     string
+    OwnerID    string `json:",omitempty"`
+    TeamID     string `json:",omitempty"`
+    Title      string
+    Date       string
+    ArchivedAt time.Time
+    ExpiresAt  *time.Time `json:",omitempty"`
+    Size       int64      // bytes on disk
+}
+
+// Segment is a searchable piece of a meeting - a transcript cue or a summary paragraph
+type Segment struct {
+    Kind      string  // "transcript" or "summary"
+    SummaryID string  `json:",omitempty"`
+    Speaker   string  `json:",omitempty"`
+    Start     float64 // seconds, transcript segments only
+    End       float64
+    Timestamp string  `json:",omitempty"` // Start as hh:mm:ss
+    Text      string
+}
+
+// Hit is a search result, Link points to the archived meeting
+type Hit struct {
+    TaskID  string
+    Title   string
+    Link    string
+    Score   int
+    Segment Segment
+}
+
+// entry is an archived meeting with its search index
+type entry struct {
+    record   record
+    size     int64
+    segments []Segment
+    terms    []map[string]int // term frequencies per segment
+    allTerms map[string]bool
+}
+
+// Archive keeps completed meetings as JSON files in a directory (<dir>/<task id>.json)
+// and an in-memory full-text index over their transcripts and summaries
+type Archive struct {
+    dir     string
+    mu      sync.RWMutex
+    entries map[string]*entry
+    changed chan struct{} // closed and replaced whenever a meeting is saved or deleted
+}
+
+// Open loads all archived meetings from dir
+func Open(dir string) (*Archive, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    a := &Archive{dir: dir, entries: make(map[string]*entry), changed: make(chan struct{})}
+
+    files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    for _, file := range files {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            return nil, err
+        }
+        var rec record
+        if err := json.Unmarshal(data, &rec); err != nil {
+            return nil, err
+        }
+        rec.Task.TokenHash = rec.TokenHash
+        a.entries[rec.Task.ID] = newEntry(rec, int64(len(data)))
+    }
+    return a, nil
+}
+
+// Save archives (or updates) a meeting. An update keeps the time the meeting was first archived.
+func (a *Archive) Save(task types.Task) error {
+    if !validID.MatchString(task.ID) {
+        return errors.New("invalid task ID")
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    rec := record{Task: task, TokenHash: task.TokenHash, ArchivedAt: time.Now()}
+    if e, ok := a.entries[task.ID]; ok {
+        rec.ArchivedAt = e.record.ArchivedAt
+    }
+    data, err := json.Marshal(rec)
+    if err != nil {
+        return err
+    }
+    path := filepath.Join(a.dir, task.ID + ".json")
+    tmpPath := path + ".tmp"
+    if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+        return err
+    }
+    if err := os.Rename(tmpPath, path); err != nil {
+        return err
+    }
+    a.entries[task.ID] = newEntry(rec, int64(len(data)))
+    a.notify()
+    return nil
+}
+
+// Delete removes a meeting from the archive
+func (a *Archive) Delete(taskID string) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if _, ok := a.entries[taskID]; !ok {
+        return ErrNotFound
+    }
+    if err := os.Remove(filepath.Join(a.dir, taskID + ".json")); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    delete(a.entries, taskID)
+    a.notify()
+    return nil
+}
+
+// Changed returns a channel that is closed the next time a meeting is saved or deleted
+func (a *Archive) Changed() <-chan struct{} {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return a.changed
+}
+
+// notify wakes up everyone waiting on Changed, caller must hold a.mu for writing
+func (a *Archive) notify() {
+    close(a.changed)
+    a.changed = make(chan struct{})
+}
+
+// Get returns an archived meeting
+func (a *Archive) Get(taskID string) (*types.Task, error) {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    e, ok := a.entries[taskID]
+    if !ok {
+        return nil, ErrNotFound
+    }
+    task := e.record.Task
+    return &task, nil
+}
+
+// List returns all archived meetings, most recently archived first
+func (a *Archive) List() []Meeting {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    meetings := make([]Meeting, 0, len(a.entries))
+    for _, e := range a.entries {
+        meetings = append(meetings, e.meeting())
+    }
+    sort.Slice(meetings, func(i, j int) bool { return meetings[i].ArchivedAt.After(meetings[j].ArchivedAt) })
+    return meetings
+}
+
+// Filter selects the meetings a search covers, e.g. the ones a user may read
+type Filter func(meeting Meeting) bool
+
+// Search returns up to limit segments of meetings selected by filter containing all words of
+// the query, best matches first. Parts of the query in double quotes must appear as an exact phrase.
+func (a *Archive) Search(query string, limit int, filter Filter) []Hit {
+    terms, phrases := parseQuery(query)
+    if len(terms) == 0 {
+        return []Hit{}
+    }
+
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    hits := []Hit{}
+    for _, e := range a.entries {
+        if !filter(e.meeting()) || !e.hasAll(terms) {
+            continue
+        }
+        for i, segment := range e.segments {
+            score := 0
+            for _, term := range terms {
+                count := e.terms[i][term]
+                if count == 0 {
+                    score = 0
+                    break
+                }
+                score += count
+            }
+            if score == 0 || !containsPhrases(segment.Text, phrases) {
+                continue
+            }
+            hits = append(hits, Hit{
+                TaskID:  e.record.Task.ID,
+                Title:   e.record.Task.Meeting.Title,
+                Link:    "/archive/" + e.record.Task.ID,
+                Score:   score,
+                Segment: segment,
+            })
+        }
+    }
+
+    sort.SliceStable(hits, func(i, j int) bool {
+        if hits[i].Score != hits[j].Score {
+            return hits[i].Score > hits[j].Score
+        }
+        if hits[i].TaskID != hits[j].TaskID {
+            return hits[i].TaskID < hits[j].TaskID
+        }
+        return hits[i].Segment.Start < hits[j].Segment.Start
+    })
+    if limit > 0 && len(hits) > limit {
+        hits = hits[:limit]
+    }
+    return hits
+}
+
+func newEntry(rec record, size int64) *entry {
+    e := &entry{record: rec, size: size, allTerms: make(map[string]bool)}
+
+    for _, cue := range processing.ParseCues(rec.Task.Result.Transcript) {
+        e.segments = append(e.segments, Segment{
+            Kind:      "transcript",
+            Speaker:   cue.Speaker,
+            Start:     cue.Start,
+            End:       cue.End,
+            Timestamp: processing.FormatTimestamp(cue.Start),
+            Text:      cue.Text,
+        })
+    }
+    for _, summary := range rec.Task.Result.Summaries {
+        for _, paragraph := range strings.Split(summary.Text, "\n\n") {
+            if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+                e.segments = append(e.segments, Segment{Kind: "summary", SummaryID: summary.ID, Text: paragraph})
+            }
+        }
+    }
+
+    e.terms = make([]map[string]int, len(e.segments))
+    for i, segment := range e.segments {
+        e.terms[i] = make(map[string]int)
+        for _, term := range tokenize(segment.Text) {
+            e.terms[i][term]++
+            e.allTerms[term] = true
+        }
+    }
+    return e
+}
+
+// meeting returns the listing entry of an archived meeting
+func (e *entry) meeting() Meeting {
+    return Meeting{
+        TaskID:     e.record.Task.ID,
+        OwnerID:    e.record.Task.OwnerID,
+        TeamID:     e.record.Task.TeamID,
+        Title:      e.record.Task.Meeting.Title,
+        Date:       e.record.Task.Meeting.Date,
+        ArchivedAt: e.record.ArchivedAt,
+        ExpiresAt:  e.record.Task.ExpiresAt,
+        Size:       e.size,
+    }
+}
+
+func (e *entry) hasAll(terms []string) bool {
+    for _, term := range terms {
+        if !e.allTerms[term] {
+            return false
+        }
+    }
+    return true
+}
+
+// tokenize splits text into lowercase words
+func tokenize(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+// parseQuery returns the distinct words of the query and its quoted phrases (lowercased)
+func parseQuery(query string) ([]string, []string) {
+    var phrases []string
+    parts := strings.Split(query, "\"")
+    for i := 1; i < len(parts); i += 2 {
+        if phrase := strings.Join(tokenize(parts[i]), " "); phrase != "" {
+            phrases = append(phrases, phrase)
+        }
+    }
+
+    var terms []string
+    seen := make(map[string]bool)
+    for _, term := range tokenize(query) {
+        if !seen[term] {
+            seen[term] = true
+            terms = append(terms, term)
+        }
+    }
+    return terms, phrases
+}
+
+func containsPhrases(text string, phrases []string) bool {
+    if len(phrases) == 0 {
+        return true
+    }
+    normalized := " " + strings.Join(tokenize(text), " ") + " "
+    for _, phrase := range phrases {
+        if !strings.Contains(normalized, " " + phrase + " ") {
+            return false
+        }
+    }
+    return true
+}
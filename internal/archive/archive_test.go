@@ -0,0 +1,187 @@
+package archive
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+func all(Meeting) bool { return true }
+
+func meeting(id string, owner string, title string, transcript string, summary string) types.Task {
+    return types.Task{
+        ID:        id,
+        OwnerID:   owner,
+        TokenHash: "hash-" + id,
+        Status:    "completed",
+        Meeting:   types.Meeting{Title: title},
+        Result: types.Result{
+            Transcript: transcript,
+            Summaries:  []types.Summary{{ID: "1", Status: "completed", Text: summary}},
+        },
+    }
+}
+
+// newArchive returns an archive in a temporary directory with two meetings
+func newArchive(t *testing.T) (*Archive, string) {
+    t.Helper()
+    dir := t.TempDir()
+    a, err := Open(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tasks := []types.Task{
+        meeting("planning", "ana", "Sprint planning",
+            "WEBVTT\n\n00:00.000 --> 00:05.000\n[Ana]: The rollout date moves to March.\n\n" +
+            "01:05.000 --> 01:09.000\n[Bob]: Rollout, rollout, rollout! The date is fine.\n\n",
+            "The team agreed on the rollout.\n\nBudget was not discussed."),
+        meeting("retro", "bob", "Retrospective",
+            "WEBVTT\n\n00:10.000 --> 00:12.000\n[Cid]: The date of the rollout slipped.\n\n",
+            "Rollout issues were reviewed."),
+    }
+    for _, task := range tasks {
+        if err := a.Save(task); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return a, dir
+}
+
+func TestParseQuery(t *testing.T) {
+    terms, phrases := parseQuery(`Rollout "the DATE moves" rollout`)
+    if want := []string{"rollout", "the", "date", "moves"}; !reflect.DeepEqual(terms, want) {
+        t.Errorf("terms = %q, want %q", terms, want)
+    }
+    if want := []string{"the date moves"}; !reflect.DeepEqual(phrases, want) {
+        t.Errorf("phrases = %q, want %q", phrases, want)
+    }
+    if terms, _ := parseQuery(` "" ,. `); len(terms) != 0 {
+        t.Errorf("terms of an empty query = %q, want none", terms)
+    }
+}
+
+func TestSearchRanking(t *testing.T) {
+    a, _ := newArchive(t)
+
+    hits := a.Search("rollout date", 0, all)
+    // Segments need every word, the ones repeating them most come first
+    if len(hits) != 3 {
+        t.Fatalf("got %d hits, want 3: %+v", len(hits), hits)
+    }
+    first := hits[0]
+    if first.TaskID != "planning" || first.Segment.Speaker != "Bob" || first.Score != 4 {
+        t.Errorf("first hit = %+v, want Bob's transcript segment with score 4", first)
+    }
+    if first.Segment.Kind != "transcript" || first.Segment.Timestamp != "00:01:05" || first.Link != "/archive/planning" {
+        t.Errorf("first hit segment = %+v, link %s", first.Segment, first.Link)
+    }
+    // Ties are ordered by task ID
+    if hits[1].TaskID != "planning" || hits[2].TaskID != "retro" {
+        t.Errorf("tied hits in order %s, %s, want planning, retro", hits[1].TaskID, hits[2].TaskID)
+    }
+
+    if hits := a.Search("rollout date", 1, all); len(hits) != 1 {
+        t.Errorf("got %d hits with limit 1", len(hits))
+    }
+    if hits := a.Search("rollout budget", 0, all); len(hits) != 0 {
+        t.Errorf("words in different segments should not match, got %+v", hits)
+    }
+
+    summaries := a.Search("budget", 0, all)
+    if len(summaries) != 1 || summaries[0].Segment.Kind != "summary" || summaries[0].Segment.SummaryID != "1" {
+        t.Errorf("summary hits = %+v, want the second paragraph of summary 1", summaries)
+    }
+}
+
+func TestSearchPhrase(t *testing.T) {
+    a, _ := newArchive(t)
+
+    hits := a.Search(`"date moves"`, 0, all)
+    if len(hits) != 1 || hits[0].Segment.Speaker != "Ana" {
+        t.Errorf("phrase hits = %+v, want Ana's segment", hits)
+    }
+    // All words are there, but not next to each other
+    if hits := a.Search(`"date rollout"`, 0, all); len(hits) != 0 {
+        t.Errorf("phrase hits = %+v, want none", hits)
+    }
+    // Case and punctuation do not matter
+    if hits := a.Search(`"ROLLOUT, rollout"`, 0, all); len(hits) != 1 {
+        t.Errorf("phrase hits = %+v, want Bob's segment", hits)
+    }
+}
+
+func TestSearchFilter(t *testing.T) {
+    a, _ := newArchive(t)
+
+    hits := a.Search("rollout", 0, func(m Meeting) bool { return m.OwnerID == "bob" })
+    if len(hits) == 0 {
+        t.Fatal("no hits in Bob's meetings")
+    }
+    for _, hit := range hits {
+        if hit.TaskID != "retro" {
+            t.Errorf("hit in %s, want only retro", hit.TaskID)
+        }
+    }
+}
+
+func TestReload(t *testing.T) {
+    a, dir := newArchive(t)
+    if err := a.Delete("retro"); err != nil {
+        t.Fatal(err)
+    }
+    if err := a.Delete("retro"); err != ErrNotFound {
+        t.Errorf("deleting again: err = %v, want ErrNotFound", err)
+    }
+
+    reopened, err := Open(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    meetings := reopened.List()
+    if len(meetings) != 1 || meetings[0].TaskID != "planning" || meetings[0].Title != "Sprint planning" || meetings[0].Size == 0 {
+        t.Fatalf("List = %+v, want the planning meeting", meetings)
+    }
+    task, err := reopened.Get("planning")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if task.TokenHash != "hash-planning" || task.OwnerID != "ana" {
+        t.Errorf("reloaded task = %+v, want its owner and token hash", task)
+    }
+    if hits := reopened.Search(`"date moves"`, 0, all); len(hits) != 1 {
+        t.Errorf("reloaded index: got %d hits, want 1", len(hits))
+    }
+    if _, err := reopened.Get("retro"); err != ErrNotFound {
+        t.Errorf("Get of a deleted meeting: err = %v, want ErrNotFound", err)
+    }
+}
+
+func TestSaveKeepsArchivedAt(t *testing.T) {
+    a, dir := newArchive(t)
+    before := a.List()
+    if len(before) != 2 || before[0].TaskID != "retro" {
+        t.Fatalf("List = %+v, want the last archived meeting first", before)
+    }
+    archivedAt := before[1].ArchivedAt
+
+    // Renaming the older meeting does not move it to the front
+    time.Sleep(10 * time.Millisecond)
+    renamed := meeting("planning", "ana", "Q3 planning", "WEBVTT\n", "Renamed.")
+    if err := a.Save(renamed); err != nil {
+        t.Fatal(err)
+    }
+    after := a.List()
+    if after[0].TaskID != "retro" || after[1].Title != "Q3 planning" || !after[1].ArchivedAt.Equal(archivedAt) {
+        t.Errorf("List after the rename = %+v, want planning still archived at %v", after, archivedAt)
+    }
+
+    reopened, err := Open(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if meetings := reopened.List(); !meetings[1].ArchivedAt.Equal(archivedAt) {
+        t.Errorf("reloaded ArchivedAt = %v, want %v", meetings[1].ArchivedAt, archivedAt)
+    }
+}
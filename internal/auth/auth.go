@@ -0,0 +1,114 @@
+package auth
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+var (
+    // ErrNoCredentials is returned by an Authenticator when the request carries none of its credentials
+    ErrNoCredentials      = errors.New("no credentials")
+    ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator identifies the user making a request. Implementations return ErrNoCredentials
+// if the request has nothing for them (so the next authenticator in a Chain is tried) and
+// ErrInvalidCredentials if it has credentials that are wrong or expired.
+type Authenticator interface {
+    Authenticate(r *http.Request) (*User, error)
+}
+
+// Chain tries authenticators in order until one finds credentials in the request
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*User, error) {
+    for _, authenticator := range c {
+        user, err := authenticator.Authenticate(r)
+        if err != ErrNoCredentials {
+            return user, err
+        }
+    }
+    return nil, ErrNoCredentials
+}
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user *User) context.Context {
+    return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user of a request, nil if anonymous
+func UserFromContext(ctx context.Context) *User {
+    user, _ := ctx.Value(contextKey{}).(*User)
+    return user
+}
+
+// Middleware authenticates every request with authenticator and stores the user in the
+// request context. Requests without credentials pass through anonymously, handlers decide
+// whether they need a user; requests with invalid credentials are rejected.
+func Middleware(authenticator Authenticator, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        user, err := authenticator.Authenticate(r)
+        switch err {
+        case nil:
+            r = r.WithContext(WithUser(r.Context(), user))
+        case ErrNoCredentials:
+        default:
+            writeError(w, http.StatusUnauthorized, "invalid_credentials", "Invalid credentials")
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// RequireUser responds with 401 to anonymous requests
+func RequireUser(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if UserFromContext(r.Context()) == nil {
+            writeError(w, http.StatusUnauthorized, "authentication_required", "Authentication required")
+            return
+        }
+        next(w, r)
+    }
+}
+
+// RequireAdmin responds with 401 to anonymous requests and with 403 to users who are not admins
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+    return RequireUser(func(w http.ResponseWriter, r *http.Request) {
+        if !UserFromContext(r.Context()).Admin {
+            writeError(w, http.StatusForbidden, "admin_required", "Only admins can do this")
+            return
+        }
+        next(w, r)
+    })
+}
+
+// writeError responds with the same JSON error envelope as the HTTP handlers
+func writeError(w http.ResponseWriter, status int, code, message string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(types.ErrorResponse{Error: types.APIError{Code: code, Message: message}})
+}
+
+// randomToken returns a random hex secret of n bytes
+func randomToken(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
+
+// hashSecret returns the form session and API key secrets are kept in
+func hashSecret(secret string) string {
+    sum := sha256.Sum256([]byte(secret))
+    return hex.EncodeToString(sum[:])
+}
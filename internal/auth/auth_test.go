@@ -0,0 +1,417 @@
+package auth
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func newUsers(t *testing.T) (*Users, string) {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "users.json")
+    users, err := NewUsers(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return users, path
+}
+
+func createUser(t *testing.T, users *Users, username string) *User {
+    t.Helper()
+    user, err := users.Create(username, "correct horse", false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return user
+}
+
+func TestLogin(t *testing.T) {
+    users, path := newUsers(t)
+    if _, err := users.Create("Ana", "correct horse", true); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := users.Create("bob", "correct horse", true); err != ErrSignupClosed {
+        t.Errorf("second signup with firstOnly: err = %v, want ErrSignupClosed", err)
+    }
+    if _, err := users.Create("ana", "correct horse", false); err != ErrUserExists {
+        t.Errorf("duplicate username: err = %v, want ErrUserExists", err)
+    }
+    if _, err := users.Create("bob", "short", false); err != ErrWeakPassword {
+        t.Errorf("short password: err = %v, want ErrWeakPassword", err)
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(string(data), "correct horse") || !strings.Contains(string(data), "$2a$") {
+        t.Error("the users file should hold a bcrypt hash, not the password")
+    }
+
+    // Reloaded from disk, usernames are not case sensitive
+    users, err = NewUsers(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    user, err := users.Login(" ANA ", "correct horse")
+    if err != nil || user.Username != "ana" {
+        t.Fatalf("Login = %+v, %v", user, err)
+    }
+    if _, err := users.Login("ana", "wrong horse"); err != ErrInvalidCredentials {
+        t.Errorf("wrong password: err = %v, want ErrInvalidCredentials", err)
+    }
+}
+
+func TestAdmins(t *testing.T) {
+    users, path := newUsers(t)
+    ana := createUser(t, users, "ana")
+    bob := createUser(t, users, "bob")
+    if !ana.Admin || bob.Admin {
+        t.Fatalf("Admin = %v, %v, want only the first account to be an admin", ana.Admin, bob.Admin)
+    }
+    if err := users.SetAdmin(bob.ID, true); err != nil {
+        t.Fatal(err)
+    }
+    if err := users.SetAdmin("nobody", true); err != ErrUserNotFound {
+        t.Errorf("SetAdmin of an unknown user: err = %v, want ErrUserNotFound", err)
+    }
+    users, err := NewUsers(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if user, _ := users.Get(bob.ID); !user.Admin {
+        t.Error("the admin role was not stored")
+    }
+
+    // Accounts stored before admins existed: the oldest one becomes the admin
+    data := `[{"ID": "1", "Username": "cid", "CreatedAt": "2024-02-01T00:00:00Z"},
+        {"ID": "2", "Username": "dan", "CreatedAt": "2024-01-01T00:00:00Z"}]`
+    if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+        t.Fatal(err)
+    }
+    if users, err = NewUsers(path); err != nil {
+        t.Fatal(err)
+    }
+    cid, _ := users.Get("1")
+    dan, _ := users.Get("2")
+    if cid.Admin || !dan.Admin {
+        t.Errorf("Admin = %v, %v, want the oldest account to be the admin", cid.Admin, dan.Admin)
+    }
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+    users, _ := newUsers(t)
+    if _, err := users.Login("nobody", "correct horse"); err != ErrInvalidCredentials {
+        t.Errorf("unknown user: err = %v, want ErrInvalidCredentials", err)
+    }
+    // The password is still compared, against the dummy hash
+    if len(dummyHash) == 0 {
+        t.Error("unknown usernames should be checked against the dummy hash")
+    }
+
+    // Accounts of an external identity provider have no password
+    users.users["external"] = &User{ID: "external", Username: "carol"}
+    if _, err := users.Login("carol", ""); err != ErrInvalidCredentials {
+        t.Errorf("account without password: err = %v, want ErrInvalidCredentials", err)
+    }
+}
+
+func TestAPIKeys(t *testing.T) {
+    users, path := newUsers(t)
+    user := createUser(t, users, "ana")
+    secret, key, err := users.CreateAPIKey(user.ID, " ci ")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(secret, apiKeyPrefix) || key.Name != "ci" || key.Hash != hashSecret(secret) {
+        t.Errorf("CreateAPIKey = %q, %+v", secret, key)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(string(data), secret) || !strings.Contains(string(data), key.Hash) {
+        t.Error("the users file should hold the key's hash, not the key")
+    }
+
+    // Reloaded from disk, the key is found by its hash
+    users, err = NewUsers(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    authenticator := APIKeyAuthenticator{Users: users}
+    for _, header := range [][2]string{{"X-API-Key", secret}, {"Authorization", "Bearer " + secret}} {
+        r := httptest.NewRequest("GET", "/", nil)
+        r.Header.Set(header[0], header[1])
+        if got, err := authenticator.Authenticate(r); err != nil || got.ID != user.ID {
+            t.Errorf("key in %s: Authenticate = %+v, %v", header[0], got, err)
+        }
+    }
+
+    r := httptest.NewRequest("GET", "/", nil)
+    r.Header.Set("Authorization", "Bearer some-other-token")
+    if _, err := authenticator.Authenticate(r); err != ErrNoCredentials {
+        t.Errorf("other bearer token: err = %v, want ErrNoCredentials", err)
+    }
+
+    if err := users.DeleteAPIKey(user.ID, key.ID); err != nil {
+        t.Fatal(err)
+    }
+    if err := users.DeleteAPIKey(user.ID, key.ID); err != ErrKeyNotFound {
+        t.Errorf("deleting again: err = %v, want ErrKeyNotFound", err)
+    }
+    r = httptest.NewRequest("GET", "/", nil)
+    r.Header.Set("X-API-Key", secret)
+    if _, err := authenticator.Authenticate(r); err != ErrInvalidCredentials {
+        t.Errorf("revoked key: err = %v, want ErrInvalidCredentials", err)
+    }
+}
+
+func sessionRequest(secret string) *http.Request {
+    r := httptest.NewRequest("GET", "/", nil)
+    r.AddCookie(&http.Cookie{Name: SessionCookie, Value: secret})
+    return r
+}
+
+func TestSessionExpiry(t *testing.T) {
+    users, _ := newUsers(t)
+    user := createUser(t, users, "ana")
+    sessions := NewSessions(users)
+    secret, err := sessions.Create(user.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, err := sessions.Authenticate(sessionRequest(secret)); err != nil || got.ID != user.ID {
+        t.Fatalf("Authenticate = %+v, %v", got, err)
+    }
+
+    // Using a session keeps it alive, one unused for longer than the TTL is dropped
+    sess := sessions.sessions[hashSecret(secret)]
+    sess.lastUsed = time.Now().Add(-sessionTTL + time.Minute)
+    if _, err := sessions.Authenticate(sessionRequest(secret)); err != nil {
+        t.Fatalf("session close to expiry: err = %v", err)
+    }
+    if time.Since(sess.lastUsed) > time.Minute {
+        t.Error("Authenticate should refresh the session")
+    }
+    sess.lastUsed = time.Now().Add(-sessionTTL - time.Minute)
+    if _, err := sessions.Authenticate(sessionRequest(secret)); err != ErrNoCredentials {
+        t.Errorf("expired session: err = %v, want ErrNoCredentials", err)
+    }
+    if len(sessions.sessions) != 0 {
+        t.Error("the expired session should be removed")
+    }
+
+    secret, err = sessions.Create(user.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    sessions.Delete(secret)
+    if _, err := sessions.Authenticate(sessionRequest(secret)); err != ErrNoCredentials {
+        t.Errorf("deleted session: err = %v, want ErrNoCredentials", err)
+    }
+}
+
+// stubAuthenticator returns a fixed result and counts its calls
+type stubAuthenticator struct {
+    user  *User
+    err   error
+    calls int
+}
+
+func (s *stubAuthenticator) Authenticate(r *http.Request) (*User, error) {
+    s.calls++
+    return s.user, s.err
+}
+
+func TestChainOrder(t *testing.T) {
+    none := &stubAuthenticator{err: ErrNoCredentials}
+    ana := &stubAuthenticator{user: &User{ID: "ana"}}
+    bob := &stubAuthenticator{user: &User{ID: "bob"}}
+
+    user, err := Chain{none, ana, bob}.Authenticate(httptest.NewRequest("GET", "/", nil))
+    if err != nil || user.ID != "ana" {
+        t.Errorf("Authenticate = %+v, %v, want the first authenticator with credentials", user, err)
+    }
+    if none.calls != 1 || bob.calls != 0 {
+        t.Errorf("calls: %d before, %d after the match, want 1 and 0", none.calls, bob.calls)
+    }
+
+    // Invalid credentials stop the chain, later authenticators cannot override them
+    invalid := &stubAuthenticator{err: ErrInvalidCredentials}
+    if _, err := (Chain{invalid, ana}).Authenticate(httptest.NewRequest("GET", "/", nil)); err != ErrInvalidCredentials {
+        t.Errorf("err = %v, want ErrInvalidCredentials", err)
+    }
+    if _, err := (Chain{none}).Authenticate(httptest.NewRequest("GET", "/", nil)); err != ErrNoCredentials {
+        t.Errorf("no credentials: err = %v, want ErrNoCredentials", err)
+    }
+}
+
+// mockIdP is an identity provider answering userinfo requests for the access tokens it issued
+type mockIdP struct {
+    *httptest.Server
+    tokens map[string]string // access token -> subject
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+    idp := &mockIdP{tokens: map[string]string{"idp-token": "carol"}}
+    idp.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        subject, ok := idp.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+        if r.URL.Path != "/userinfo" || !ok {
+            w.WriteHeader(http.StatusUnauthorized)
+            return
+        }
+        json.NewEncoder(w).Encode(map[string]string{"sub": subject})
+    }))
+    t.Cleanup(idp.Close)
+    return idp
+}
+
+// idpAuthenticator accepts bearer tokens of an external identity provider, mapping its
+// subjects to accounts without a password
+type idpAuthenticator struct {
+    url string
+}
+
+func (a idpAuthenticator) Authenticate(r *http.Request) (*User, error) {
+    auth := r.Header.Get("Authorization")
+    if !strings.HasPrefix(auth, "Bearer ") || strings.HasPrefix(auth, "Bearer " + apiKeyPrefix) {
+        return nil, ErrNoCredentials
+    }
+    req, err := http.NewRequestWithContext(r.Context(), "GET", a.url + "/userinfo", nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Authorization", auth)
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    var info struct {
+        Subject string `json:"sub"`
+    }
+    if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&info) != nil {
+        return nil, ErrInvalidCredentials
+    }
+    return &User{ID: "idp:" + info.Subject, Username: info.Subject}, nil
+}
+
+func TestMiddleware(t *testing.T) {
+    users, _ := newUsers(t)
+    ana := createUser(t, users, "ana")
+    key, _, err := users.CreateAPIKey(ana.ID, "ci")
+    if err != nil {
+        t.Fatal(err)
+    }
+    sessions := NewSessions(users)
+    session, err := sessions.Create(ana.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    idp := newMockIdP(t)
+    authenticator := Chain{sessions, APIKeyAuthenticator{Users: users}, idpAuthenticator{url: idp.URL}}
+
+    handler := Middleware(authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if user := UserFromContext(r.Context()); user != nil {
+            w.Write([]byte(user.Username))
+        } else {
+            w.Write([]byte("anonymous"))
+        }
+    }))
+
+    tests := []struct {
+        name   string
+        header [2]string
+        cookie string
+        status int
+        body   string
+    }{
+        {"anonymous", [2]string{}, "", http.StatusOK, "anonymous"},
+        {"session", [2]string{}, session, http.StatusOK, "ana"},
+        // Browsers keep cookies of sessions lost on restart, they can still log in again
+        {"invalid session", [2]string{}, "stale", http.StatusOK, "anonymous"},
+        {"API key", [2]string{"X-API-Key", key}, "", http.StatusOK, "ana"},
+        {"invalid API key", [2]string{"X-API-Key", "sk_wrong"}, "", http.StatusUnauthorized, ""},
+        {"invalid bearer API key", [2]string{"Authorization", "Bearer sk_wrong"}, "", http.StatusUnauthorized, ""},
+        {"identity provider", [2]string{"Authorization", "Bearer idp-token"}, "", http.StatusOK, "carol"},
+        {"invalid identity provider token", [2]string{"Authorization", "Bearer expired"}, "", http.StatusUnauthorized, ""},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "/", nil)
+        if tt.header[0] != "" {
+            r.Header.Set(tt.header[0], tt.header[1])
+        }
+        if tt.cookie != "" {
+            r.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+        }
+        w := httptest.NewRecorder()
+        handler.ServeHTTP(w, r)
+        if w.Code != tt.status {
+            t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+            continue
+        }
+        if tt.status != http.StatusOK {
+            var resp struct {
+                Error struct {
+                    Code string `json:"code"`
+                } `json:"error"`
+            }
+            if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error.Code != "invalid_credentials" {
+                t.Errorf("%s: error body %q", tt.name, w.Body.String())
+            }
+        } else if w.Body.String() != tt.body {
+            t.Errorf("%s: handler saw %q, want %q", tt.name, w.Body.String(), tt.body)
+        }
+    }
+}
+
+func TestRequireUser(t *testing.T) {
+    handler := RequireUser(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    })
+
+    w := httptest.NewRecorder()
+    handler(w, httptest.NewRequest("GET", "/", nil))
+    if w.Code != http.StatusUnauthorized {
+        t.Errorf("anonymous: status %d, want 401", w.Code)
+    }
+
+    w = httptest.NewRecorder()
+    r := httptest.NewRequest("GET", "/", nil)
+    handler(w, r.WithContext(WithUser(r.Context(), &User{ID: "ana"})))
+    if w.Code != http.StatusNoContent {
+        t.Errorf("logged in: status %d, want 204", w.Code)
+    }
+}
+
+func TestRequireAdmin(t *testing.T) {
+    handler := RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    })
+
+    for _, test := range []struct {
+        name string
+        user *User
+        want int
+    }{
+        {"anonymous", nil, http.StatusUnauthorized},
+        {"user", &User{ID: "bob"}, http.StatusForbidden},
+        {"admin", &User{ID: "ana", Admin: true}, http.StatusNoContent},
+    } {
+        w := httptest.NewRecorder()
+        r := httptest.NewRequest("POST", "/", nil)
+        if test.user != nil {
+            r = r.WithContext(WithUser(r.Context(), test.user))
+        }
+        handler(w, r)
+        if w.Code != test.want {
+            t.Errorf("%s: status %d, want %d", test.name, w.Code, test.want)
+        }
+    }
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+    "net/http"
+    "sync"
+    "time"
+)
+
+// Name of the session cookie
+const SessionCookie = "session"
+
+// Sessions expire after this long without use
+const sessionTTL = 7 * 24 * time.Hour
+
+type session struct {
+    userID   string
+    lastUsed time.Time
+}
+
+// Sessions keeps browser login sessions in memory, keyed by the hash of the cookie value.
+// Users have to log in again after a restart.
+type Sessions struct {
+    users    *Users
+    mu       sync.Mutex
+    sessions map[string]*session
+}
+
+func NewSessions(users *Users) *Sessions {
+    return &Sessions{users: users, sessions: make(map[string]*session)}
+}
+
+// Create starts a session for a user and returns its secret for the cookie
+func (s *Sessions) Create(userID string) (string, error) {
+    secret, err := randomToken(32)
+    if err != nil {
+        return "", err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for key, sess := range s.sessions {
+        if time.Since(sess.lastUsed) > sessionTTL {
+            delete(s.sessions, key)
+        }
+    }
+    s.sessions[hashSecret(secret)] = &session{userID: userID, lastUsed: time.Now()}
+    return secret, nil
+}
+
+// Delete ends a session
+func (s *Sessions) Delete(secret string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.sessions, hashSecret(secret))
+}
+
+// Cookie returns the session cookie for a secret, an empty secret clears the cookie
+func (s *Sessions) Cookie(secret string, secure bool) *http.Cookie {
+    cookie := &http.Cookie{
+        Name:     SessionCookie,
+        Value:    secret,
+        Path:     "/",
+        HttpOnly: true,
+        Secure:   secure,
+        SameSite: http.SameSiteLaxMode,
+        MaxAge:   int(sessionTTL / time.Second),
+    }
+    if secret == "" {
+        cookie.MaxAge = -1
+    }
+    return cookie
+}
+
+// Authenticate implements Authenticator for the session cookie
+func (s *Sessions) Authenticate(r *http.Request) (*User, error) {
+    cookie, err := r.Cookie(SessionCookie)
+    if err != nil || cookie.Value == "" {
+        return nil, ErrNoCredentials
+    }
+
+    s.mu.Lock()
+    sess, ok := s.sessions[hashSecret(cookie.Value)]
+    if ok && time.Since(sess.lastUsed) > sessionTTL {
+        delete(s.sessions, hashSecret(cookie.Value))
+        ok = false
+    }
+    var userID string
+    if ok {
+        sess.lastUsed = time.Now()
+        userID = sess.userID
+    }
+    s.mu.Unlock()
+
+    // Unknown or expired sessions (e.g. after a restart) leave the request anonymous, so
+    // the browser can still load the page and log in again
+    if !ok {
+        return nil, ErrNoCredentials
+    }
+    user, err := s.users.Get(userID)
+    if err != nil {
+        return nil, ErrNoCredentials
+    }
+    return user, nil
+}
@@ -0,0 +1,350 @@
+package auth
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Prefix of API keys, so they can be told apart from other bearer tokens
+const apiKeyPrefix = "sk_"
+
+const minPasswordLength = 8
+
+var (
+    ErrUserNotFound    = errors.New("user not found")
+    ErrUserExists      = errors.New("user already exists")
+    ErrInvalidUsername = errors.New("invalid username")
+    ErrWeakPassword    = errors.New("password too short")
+    ErrKeyNotFound     = errors.New("API key not found")
+    ErrSignupClosed    = errors.New("signup is closed")
+)
+
+var validUsername = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Hash compared against for unknown usernames
+var (
+    dummyHash     []byte
+    dummyHashOnce sync.Once
+)
+
+// User is a local account. Admins manage what is shared by all users, the summary templates
+// and the speaker registry; the first account is an admin.
+type User struct {
+    ID           string
+    Username     string
+    PasswordHash string `json:"-"` // bcrypt, empty for accounts of external identity providers
+    Admin        bool
+    APIKeys      []APIKey
+    CreatedAt    time.Time
+}
+
+// APIKey is a long-lived credential for scripted clients, only its hash is stored
+type APIKey struct {
+    ID        string
+    Name      string
+    Hash      string `json:"-"`
+    CreatedAt time.Time
+}
+
+// storedKey is the on-disk format of an API key, including its hash
+type storedKey struct {
+    APIKey
+    Hash string
+}
+
+type storedUser struct {
+    User
+    PasswordHash string
+    APIKeys      []storedKey
+}
+
+// Users keeps local accounts in a JSON file
+type Users struct {
+    path  string
+    mu    sync.Mutex
+    users map[string]*User // by ID
+}
+
+// NewUsers loads accounts from path (created with the first account)
+func NewUsers(path string) (*Users, error) {
+    u := &Users{path: path, users: make(map[string]*User)}
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return u, nil
+        }
+        return nil, err
+    }
+    var stored []storedUser
+    if err := json.Unmarshal(data, &stored); err != nil {
+        return nil, err
+    }
+    for _, s := range stored {
+        user := s.User
+        user.PasswordHash = s.PasswordHash
+        user.APIKeys = nil
+        for _, key := range s.APIKeys {
+            key.APIKey.Hash = key.Hash
+            user.APIKeys = append(user.APIKeys, key.APIKey)
+        }
+        u.users[user.ID] = &user
+    }
+    // Accounts created before admins existed, the first one becomes the admin
+    var first *User
+    for _, user := range u.users {
+        if user.Admin {
+            return u, nil
+        }
+        if first == nil || user.CreatedAt.Before(first.CreatedAt) {
+            first = user
+        }
+    }
+    if first != nil {
+        first.Admin = true
+    }
+    return u, nil
+}
+
+// Count returns the number of accounts
+func (u *Users) Count() int {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    return len(u.users)
+}
+
+// Create adds an account with a bcrypt hash of password. With firstOnly it fails with
+// ErrSignupClosed unless there are no accounts yet.
+func (u *Users) Create(username string, password string, firstOnly bool) (*User, error) {
+    username = strings.ToLower(strings.TrimSpace(username))
+    if !validUsername.MatchString(username) {
+        return nil, ErrInvalidUsername
+    }
+    if len(password) < minPasswordLength {
+        return nil, ErrWeakPassword
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return nil, err
+    }
+    id, err := randomToken(12)
+    if err != nil {
+        return nil, err
+    }
+
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    if firstOnly && len(u.users) > 0 {
+        return nil, ErrSignupClosed
+    }
+    if u.findByName(username) != nil {
+        return nil, ErrUserExists
+    }
+    user := &User{ID: id, Username: username, PasswordHash: string(hash), Admin: len(u.users) == 0, CreatedAt: time.Now()}
+    u.users[id] = user
+    if err := u.save(); err != nil {
+        delete(u.users, id)
+        return nil, err
+    }
+    return copyUser(user), nil
+}
+
+// Login checks a username and password
+func (u *Users) Login(username string, password string) (*User, error) {
+    u.mu.Lock()
+    user := u.findByName(strings.ToLower(strings.TrimSpace(username)))
+    var hash []byte
+    if user != nil {
+        hash = []byte(user.PasswordHash)
+        user = copyUser(user)
+    }
+    u.mu.Unlock()
+
+    if user == nil || len(hash) == 0 {
+        // Spend the same time as for a wrong password, so usernames cannot be probed
+        dummyHashOnce.Do(func() {
+            dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+        })
+        bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+        return nil, ErrInvalidCredentials
+    }
+    if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+        return nil, ErrInvalidCredentials
+    }
+    return user, nil
+}
+
+// Get returns an account by ID
+func (u *Users) Get(id string) (*User, error) {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    user, ok := u.users[id]
+    if !ok {
+        return nil, ErrUserNotFound
+    }
+    return copyUser(user), nil
+}
+
+// GetByName returns an account by username
+func (u *Users) GetByName(username string) (*User, error) {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    user := u.findByName(strings.ToLower(strings.TrimSpace(username)))
+    if user == nil {
+        return nil, ErrUserNotFound
+    }
+    return copyUser(user), nil
+}
+
+// SetAdmin grants or revokes the admin role of a user
+func (u *Users) SetAdmin(userID string, admin bool) error {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    user, ok := u.users[userID]
+    if !ok {
+        return ErrUserNotFound
+    }
+    previous := user.Admin
+    user.Admin = admin
+    if err := u.save(); err != nil {
+        user.Admin = previous
+        return err
+    }
+    return nil
+}
+
+// CreateAPIKey issues a new API key for a user, the returned secret is not stored and cannot be shown again
+func (u *Users) CreateAPIKey(userID string, name string) (string, *APIKey, error) {
+    secret, err := randomToken(32)
+    if err != nil {
+        return "", nil, err
+    }
+    id, err := randomToken(8)
+    if err != nil {
+        return "", nil, err
+    }
+    secret = apiKeyPrefix + secret
+
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    user, ok := u.users[userID]
+    if !ok {
+        return "", nil, ErrUserNotFound
+    }
+    key := APIKey{ID: id, Name: strings.TrimSpace(name), Hash: hashSecret(secret), CreatedAt: time.Now()}
+    user.APIKeys = append(user.APIKeys, key)
+    if err := u.save(); err != nil {
+        user.APIKeys = user.APIKeys[:len(user.APIKeys)-1]
+        return "", nil, err
+    }
+    return secret, &key, nil
+}
+
+// DeleteAPIKey revokes an API key of a user
+func (u *Users) DeleteAPIKey(userID string, keyID string) error {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    user, ok := u.users[userID]
+    if !ok {
+        return ErrUserNotFound
+    }
+    for i, key := range user.APIKeys {
+        if key.ID == keyID {
+            user.APIKeys = append(user.APIKeys[:i:i], user.APIKeys[i+1:]...)
+            return u.save()
+        }
+    }
+    return ErrKeyNotFound
+}
+
+// lookupAPIKey returns the owner of an API key secret
+func (u *Users) lookupAPIKey(secret string) (*User, error) {
+    hash := []byte(hashSecret(secret))
+
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    for _, user := range u.users {
+        for _, key := range user.APIKeys {
+            if subtle.ConstantTimeCompare(hash, []byte(key.Hash)) == 1 {
+                return copyUser(user), nil
+            }
+        }
+    }
+    return nil, ErrInvalidCredentials
+}
+
+// findByName returns the account with a username, caller must hold u.mu
+func (u *Users) findByName(username string) *User {
+    for _, user := range u.users {
+        if user.Username == username {
+            return user
+        }
+    }
+    return nil
+}
+
+// save writes all accounts to the JSON file, caller must hold u.mu
+func (u *Users) save() error {
+    stored := make([]storedUser, 0, len(u.users))
+    for _, user := range u.users {
+        s := storedUser{User: *user, PasswordHash: user.PasswordHash}
+        for _, key := range user.APIKeys {
+            s.APIKeys = append(s.APIKeys, storedKey{APIKey: key, Hash: key.Hash})
+        }
+        stored = append(stored, s)
+    }
+    sort.Slice(stored, func(i, j int) bool { return stored[i].Username < stored[j].Username })
+
+    data, err := json.Marshal(stored)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(u.path), 0700); err != nil {
+        return err
+    }
+    // Write to a temp file first so a crash never leaves a truncated file
+    tmpPath := u.path + ".tmp"
+    if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, u.path)
+}
+
+func copyUser(user *User) *User {
+    c := *user
+    c.APIKeys = append([]APIKey(nil), user.APIKeys...)
+    return &c
+}
+
+// APIKeyAuthenticator accepts API keys sent as a bearer token or in the X-API-Key header
+type APIKeyAuthenticator struct {
+    Users *Users
+}
+
+func (a APIKeyAuthenticator) Authenticate(r *http.Request) (*User, error) {
+    secret := r.Header.Get("X-API-Key")
+    if auth := r.Header.Get("Authorization"); secret == "" && strings.HasPrefix(auth, "Bearer " + apiKeyPrefix) {
+        secret = strings.TrimPrefix(auth, "Bearer ")
+    }
+    if secret == "" {
+        return nil, ErrNoCredentials
+    }
+    return a.Users.lookupAPIKey(secret)
+}
@@ -0,0 +1,199 @@
+package chat
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/internal/semantic"
+)
+
+// Conversations without a message for this long are dropped
+const conversationTTL = 2 * time.Hour
+
+// Tokens reserved for the model's reply
+const replyTokens = 1024
+
+// At most this many earlier messages are sent back to the model
+const maxHistoryMessages = 20
+
+var ErrNotFound = errors.New("conversation not found")
+
+const systemPrompt = "You are answering questions about a single work meeting. Answer only from the transcript below, " +
+    "mention the hh:mm:ss timestamps of the parts you rely on, and say so when the transcript does not cover the question."
+
+// Conversation is a chat over one meeting's transcript
+type Conversation struct {
+    ID        string
+    TaskID    string
+    Messages  []processing.ChatMessage
+    UpdatedAt time.Time
+    mu        sync.Mutex // held while a reply is generated
+}
+
+// Chats keeps the open conversations in memory
+type Chats struct {
+    llm           *processing.LLM
+    mu            sync.Mutex
+    conversations map[string]*Conversation
+}
+
+func New(llm *processing.LLM) *Chats {
+    return &Chats{llm: llm, conversations: make(map[string]*Conversation)}
+}
+
+// Conversation returns the conversation with the given ID for the task, or starts a new one if id is empty
+func (c *Chats) Conversation(taskID string, id string) (*Conversation, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for key, conv := range c.conversations {
+        if time.Since(conv.UpdatedAt) > conversationTTL {
+            delete(c.conversations, key)
+        }
+    }
+
+    if id != "" {
+        conv, ok := c.conversations[id]
+        if !ok || conv.TaskID != taskID {
+            return nil, ErrNotFound
+        }
+        return conv, nil
+    }
+
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return nil, err
+    }
+    conv := &Conversation{ID: hex.EncodeToString(buf), TaskID: taskID, UpdatedAt: time.Now()}
+    c.conversations[conv.ID] = conv
+    return conv, nil
+}
+
+// Reply sends the user's message with the conversation so far to the LLM, streaming the reply
+// through onToken. The transcript is included whole if it fits the context window, otherwise
+// only the parts most relevant to the message.
+func (c *Chats) Reply(ctx context.Context, conv *Conversation, transcript string, message string, onToken func(string) error) (string, error) {
+    conv.mu.Lock()
+    defer conv.mu.Unlock()
+
+    history := conv.Messages
+    if len(history) > maxHistoryMessages {
+        history = history[len(history)-maxHistoryMessages:]
+    }
+    // Keep the history to a quarter of the context, dropping the oldest exchanges first
+    for len(history) > 2 && estimateTokens(history...) > c.llm.ContextSize()/4 {
+        history = history[2:]
+    }
+
+    budget := c.llm.ContextSize() - replyTokens - estimateTokens(history...) -
+        estimateTokens(processing.ChatMessage{Content: systemPrompt + message})
+    query := message
+    if len(history) >= 2 {
+        // Follow-ups ("and when is it due?") often depend on the previous question
+        query += " " + history[len(history)-2].Content
+    }
+
+    messages := []processing.ChatMessage{{Role: "system", Content: systemPrompt + "\n\nTranscript:\n" + fitTranscript(transcript, query, budget)}}
+    messages = append(messages, history...)
+    messages = append(messages, processing.ChatMessage{Role: "user", Content: message})
+
+    reply, err := c.llm.ChatStream(ctx, messages, replyTokens, onToken)
+    if err != nil {
+        return reply, err
+    }
+    reply = strings.TrimSpace(reply)
+
+    conv.Messages = append(conv.Messages,
+        processing.ChatMessage{Role: "user", Content: message},
+        processing.ChatMessage{Role: "assistant", Content: reply},
+    )
+    c.mu.Lock()
+    conv.UpdatedAt = time.Now()
+    c.mu.Unlock()
+    return reply, nil
+}
+
+// fitTranscript returns the whole transcript if it fits into budget tokens, otherwise the
+// transcript chunks sharing the most (rare) words with the query, in meeting order
+func fitTranscript(transcript string, query string, budget int) string {
+    if estimateTokens(processing.ChatMessage{Content: transcript}) <= budget {
+        return transcript
+    }
+
+    chunks := semantic.ChunkTranscript(transcript)
+    chunkWords := make([]map[string]bool, len(chunks))
+    frequency := make(map[string]int)
+    for i, chunk := range chunks {
+        chunkWords[i] = make(map[string]bool)
+        for _, word := range words(chunk.Text) {
+            if !chunkWords[i][word] {
+                chunkWords[i][word] = true
+                frequency[word]++
+            }
+        }
+    }
+
+    scores := make([]float64, len(chunks))
+    for _, word := range words(query) {
+        for i := range chunks {
+            if chunkWords[i][word] {
+                scores[i] += 1 / float64(frequency[word])
+            }
+        }
+    }
+    order := make([]int, len(chunks))
+    for i := range order {
+        order[i] = i
+    }
+    sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+    var selected []int
+    used := 0
+    for _, i := range order {
+        tokens := estimateTokens(processing.ChatMessage{Content: chunks[i].Text}) + 8
+        if used+tokens > budget {
+            continue
+        }
+        selected = append(selected, i)
+        used += tokens
+    }
+    sort.Ints(selected)
+
+    var excerpt strings.Builder
+    for _, i := range selected {
+        excerpt.WriteString("[" + processing.FormatTimestamp(chunks[i].Start) + "]\n" + chunks[i].Text + "\n\n")
+    }
+    return excerpt.String()
+}
+
+// estimateTokens is a conservative token estimate of about 3 characters per token
+func estimateTokens(messages ...processing.ChatMessage) int {
+    total := 0
+    for _, message := range messages {
+        total += len(message.Content)/3 + 4
+    }
+    return total
+}
+
+// words splits text into distinct lowercase words
+func words(text string) []string {
+    var distinct []string
+    seen := make(map[string]bool)
+    for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    }) {
+        if !seen[word] {
+            seen[word] = true
+            distinct = append(distinct, word)
+        }
+    }
+    return distinct
+}
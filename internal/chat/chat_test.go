@@ -0,0 +1,239 @@
+package chat
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+)
+
+// completionRequest is the part of a chat completion request the tests look at
+type completionRequest struct {
+    Messages []processing.ChatMessage `json:"messages"`
+}
+
+// newLLM serves the llama server API with completions, started by a python on PATH that
+// only idles
+func newLLM(t *testing.T, completions http.HandlerFunc) *processing.LLM {
+    t.Helper()
+    bin := t.TempDir()
+    if err := os.WriteFile(filepath.Join(bin, "python"), []byte("#!/bin/sh\nexec sleep 600\n"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("PATH", bin + string(os.PathListSeparator) + os.Getenv("PATH"))
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte(`{"data": []}`))
+    })
+    mux.HandleFunc("/v1/chat/completions", completions)
+    server := httptest.NewServer(mux)
+    t.Cleanup(server.Close)
+
+    llm := processing.NewLLM(time.Minute, server.Listener.Addr().(*net.TCPAddr).Port)
+    t.Cleanup(llm.Close)
+    return llm
+}
+
+// streamTokens answers a streaming chat completion with tokens, one event each
+func streamTokens(w http.ResponseWriter, tokens ...string) {
+    w.Header().Set("Content-Type", "text/event-stream")
+    for _, token := range tokens {
+        data, _ := json.Marshal(map[string]interface{}{
+            "choices": []map[string]interface{}{{"delta": map[string]string{"content": token}}},
+        })
+        fmt.Fprintf(w, "data: %s\n\n", data)
+        w.(http.Flusher).Flush()
+    }
+    fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// transcript builds a transcript with one chunk of 150 words and a topic per minute, a minute apart
+func transcript(topics ...string) string {
+    var b strings.Builder
+    b.WriteString("WEBVTT\n\n")
+    for i, topic := range topics {
+        fmt.Fprintf(&b, "%02d:00.000 --> %02d:59.000\n[SPEAKER_00]: %s%s\n\n", i, i, strings.Repeat("we talked about ", 50), topic)
+    }
+    return b.String()
+}
+
+func TestFitTranscript(t *testing.T) {
+    meeting := transcript("pricing", "hiring", "rollout", "budget", "pricing again")
+
+    // A transcript that fits is sent whole
+    if fitted := fitTranscript(meeting, "what about pricing?", 100000); fitted != meeting {
+        t.Errorf("a transcript within the budget was cut:\n%s", fitted)
+    }
+
+    // Otherwise the chunks sharing the rarest words with the question, in meeting order
+    chunkTokens := estimateTokens(processing.ChatMessage{Content: "SPEAKER_00: " + strings.Repeat("we talked about ", 50) + "pricing again"}) + 8
+    tests := []struct {
+        name   string
+        query  string
+        budget int
+        want   []string
+    }{
+        {"one chunk", "when do we start the rollout?", chunkTokens, []string{"[00:02:00]", "rollout"}},
+        {"two chunks", "what about pricing?", 2 * chunkTokens, []string{"[00:00:00]", "pricing", "[00:04:00]", "pricing again"}},
+        {"rare word first", "pricing again and budget", chunkTokens, []string{"[00:04:00]", "pricing again"}},
+        {"nothing fits", "pricing", chunkTokens / 2, nil},
+    }
+    for _, tt := range tests {
+        fitted := fitTranscript(meeting, tt.query, tt.budget)
+        if tokens := estimateTokens(processing.ChatMessage{Content: fitted}); tokens > tt.budget {
+            t.Errorf("%s: %d tokens over the budget of %d", tt.name, tokens, tt.budget)
+        }
+        rest := fitted
+        for _, want := range tt.want {
+            i := strings.Index(rest, want)
+            if i < 0 {
+                t.Errorf("%s: %q missing or out of order in:\n%s", tt.name, want, fitted)
+                break
+            }
+            rest = rest[i+len(want):]
+        }
+        if chunks := strings.Count(fitted, "SPEAKER_00: "); chunks != len(tt.want) / 2 {
+            t.Errorf("%s: got %d chunks, want %d", tt.name, chunks, len(tt.want) / 2)
+        }
+    }
+}
+
+func TestReplyTrimsHistory(t *testing.T) {
+    var sent completionRequest
+    llm := newLLM(t, func(w http.ResponseWriter, r *http.Request) {
+        json.NewDecoder(r.Body).Decode(&sent)
+        streamTokens(w, "Fine.")
+    })
+    chats := New(llm)
+    conv, err := chats.Conversation("t1", "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    meeting := transcript("pricing")
+
+    // A short conversation is sent back whole
+    for i := 0; i < 3; i++ {
+        if _, err := chats.Reply(context.Background(), conv, meeting, fmt.Sprintf("question %d", i), nil); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if len(sent.Messages) != 1 + 4 + 1 || sent.Messages[1].Content != "question 0" || sent.Messages[5].Content != "question 2" {
+        t.Errorf("sent %+v, want the system prompt, the two earlier exchanges and the question", sent.Messages)
+    }
+
+    // Long messages are dropped oldest exchange first, to a quarter of the context
+    long := strings.Repeat("a long question ", 200)
+    for i := 3; i < 30; i++ {
+        if _, err := chats.Reply(context.Background(), conv, meeting, fmt.Sprintf("question %d: %s", i, long), nil); err != nil {
+            t.Fatal(err)
+        }
+    }
+    history := sent.Messages[1:len(sent.Messages)-1]
+    if len(history) == 0 || len(history) % 2 != 0 || len(history) > maxHistoryMessages {
+        t.Fatalf("sent %d history messages, want whole exchanges and at most %d", len(history), maxHistoryMessages)
+    }
+    if tokens := estimateTokens(history...); tokens > llm.ContextSize() / 4 {
+        t.Errorf("history of %d tokens exceeds a quarter of the context", tokens)
+    }
+    if history[0].Role != "user" || !strings.HasPrefix(history[len(history)-2].Content, "question 28:") {
+        t.Errorf("history does not end with the previous exchange: %+v", history[len(history)-2])
+    }
+    if !strings.HasPrefix(sent.Messages[len(sent.Messages)-1].Content, "question 29:") {
+        t.Error("the question is not the last message")
+    }
+    if len(conv.Messages) != 60 {
+        t.Errorf("conversation has %d messages, want all 60 kept", len(conv.Messages))
+    }
+
+    // The whole transcript fits next to the history
+    if system := sent.Messages[0]; system.Role != "system" || !strings.HasSuffix(system.Content, meeting) {
+        t.Errorf("system prompt does not end with the transcript: %q", system.Content)
+    }
+}
+
+func TestReplyStreams(t *testing.T) {
+    llm := newLLM(t, func(w http.ResponseWriter, r *http.Request) {
+        streamTokens(w, "The rollout ", "moves to ", "March. ")
+    })
+    chats := New(llm)
+    conv, err := chats.Conversation("t1", "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var tokens []string
+    reply, err := chats.Reply(context.Background(), conv, transcript("rollout"), "When?", func(token string) error {
+        tokens = append(tokens, token)
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if reply != "The rollout moves to March." || len(tokens) != 3 || tokens[2] != "March. " {
+        t.Errorf("reply %q streamed as %q", reply, tokens)
+    }
+    if len(conv.Messages) != 2 || conv.Messages[1].Content != reply {
+        t.Errorf("conversation = %+v", conv.Messages)
+    }
+
+    // Conversations are found again by ID, for their own task only
+    if found, err := chats.Conversation("t1", conv.ID); err != nil || found != conv {
+        t.Errorf("Conversation(t1, %s) = %v, %v", conv.ID, found, err)
+    }
+    if _, err := chats.Conversation("t2", conv.ID); err != ErrNotFound {
+        t.Errorf("conversation of another task: err = %v, want ErrNotFound", err)
+    }
+}
+
+func TestReplyCancelled(t *testing.T) {
+    aborted := make(chan struct{})
+    llm := newLLM(t, func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/event-stream")
+        fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"The\"}}]}\n\n")
+        w.(http.Flusher).Flush()
+        <-r.Context().Done()
+        close(aborted)
+    })
+    chats := New(llm)
+    conv, err := chats.Conversation("t1", "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The client goes away after the first token
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error)
+    go func() {
+        _, err := chats.Reply(ctx, conv, transcript("rollout"), "When?", func(token string) error {
+            cancel()
+            return nil
+        })
+        done <- err
+    }()
+    select {
+    case err := <-done:
+        if err == nil {
+            t.Error("a cancelled reply succeeded")
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("Reply did not return after the context was cancelled")
+    }
+    select {
+    case <-aborted:
+    case <-time.After(5 * time.Second):
+        t.Fatal("the completion request was not aborted")
+    }
+    if len(conv.Messages) != 0 {
+        t.Errorf("a cancelled reply was added to the conversation: %+v", conv.Messages)
+    }
+}
@@ -0,0 +1,96 @@
+// Package metrics defines the Prometheus metrics of the server, served at /metrics
+package metrics
+
+import (
+    "net/http"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promauto"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Durations from a second to a few hours, recordings take up to 40+ minutes to process
+var durationBuckets = prometheus.ExponentialBuckets(1, 2, 15)
+
+var (
+    // StageDuration is observed for every processing stage: convert (ffmpeg), transcribe
+    // (whisperx), speakers (voice embeddings) and summarize (LLM)
+    StageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "summarizer_stage_duration_seconds",
+        Help:    "Duration of the processing stages of a task.",
+        Buckets: durationBuckets,
+    }, []string{"stage"})
+
+    // TaskDuration is the processing time of a queued job, kind is meeting, transcription or summary
+    TaskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "summarizer_task_duration_seconds",
+        Help:    "Processing time of queued jobs by kind and outcome.",
+        Buckets: durationBuckets,
+    }, []string{"kind", "status"})
+
+    QueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+        Name:    "summarizer_queue_wait_seconds",
+        Help:    "Time tasks waited in the queue before processing started.",
+        Buckets: durationBuckets,
+    })
+
+    AudioSeconds = promauto.NewCounter(prometheus.CounterOpts{
+        Name: "summarizer_audio_seconds_total",
+        Help: "Length of the recordings transcribed.",
+    })
+
+    Failures = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "summarizer_failures_total",
+        Help: "Failed processing stages by stage and reason.",
+    }, []string{"stage", "reason"})
+
+    // LLMTokens counts prompt and completion tokens of the local LLM
+    LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "summarizer_llm_tokens_total",
+        Help: "Tokens sent to (prompt) and generated by (completion) the local LLM.",
+    }, []string{"direction"})
+
+    // UploadBytes counts received recordings, api is rest, grpc or openai
+    UploadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "summarizer_upload_bytes_total",
+        Help: "Bytes of uploaded recordings.",
+    }, []string{"api"})
+
+    // ProcessStarts counts started subprocesses, llama-server starts after the first one are
+    // restarts after it was stopped for being idle
+    ProcessStarts = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "summarizer_subprocess_starts_total",
+        Help: "Started subprocesses (whisperx, ffmpeg, python scripts, llama-server).",
+    }, []string{"process"})
+)
+
+// Task statuses always reported by the queue depth, so their series exist from the start
+var taskStatuses = []string{"waiting", "processing", "completed", "failed"}
+
+var queueDepthDesc = prometheus.NewDesc("summarizer_queue_tasks", "Tasks held by the queue by status.", []string{"status"}, nil)
+
+// queueCollector reads the queue depth when scraped
+type queueCollector struct {
+    count func() map[string]int
+}
+
+func (c queueCollector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- queueDepthDesc
+}
+
+func (c queueCollector) Collect(ch chan<- prometheus.Metric) {
+    counts := c.count()
+    for _, status := range taskStatuses {
+        ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(counts[status]), status)
+    }
+}
+
+// RegisterQueue reports the number of tasks per status returned by count as queue depth
+func RegisterQueue(count func() map[string]int) {
+    prometheus.MustRegister(queueCollector{count: count})
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+    return promhttp.Handler()
+}
@@ -0,0 +1,146 @@
+package processing
+
+import (
+    "sort"
+    "strings"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// ComputeAnalytics derives participation statistics from a diarized transcript.
+// A turn is a run of consecutive cues by the same speaker. A speaker interrupts when
+// they start talking before the previous speaker's cue has ended. A speaker's overlap
+// time is the time they talk while at least one other speaker talks too, counted once
+// however many speakers talk at the same time.
+func ComputeAnalytics(transcript string) types.Analytics {
+    // Only diarized cues count, text without a speaker label cannot be attributed
+    var cues []Cue
+    for _, c := range ParseCues(transcript) {
+        if c.Speaker != "" {
+            cues = append(cues, c)
+        }
+    }
+    analytics := types.Analytics{Speakers: []types.SpeakerStats{}}
+    if len(cues) == 0 {
+        return analytics
+    }
+
+    stats := make(map[string]*types.SpeakerStats)
+    var order []string
+    speaker := func(label string) *types.SpeakerStats {
+        s, ok := stats[label]
+        if !ok {
+            s = &types.SpeakerStats{Speaker: label}
+            stats[label] = s
+            order = append(order, label)
+        }
+        return s
+    }
+
+    // Intervals in which each speaker talks over someone or is talked over, they can overlap
+    overlaps := make(map[string][][2]float64)
+    var speechTime, coveredUntil float64
+    var turnSpeaker string
+    var turnStart, turnEnd float64
+    closeTurn := func() {
+        if turnSpeaker == "" {
+            return
+        }
+        s := stats[turnSpeaker]
+        s.Turns++
+        if length := turnEnd - turnStart; length > s.LongestMonologue {
+            s.LongestMonologue = length
+        }
+    }
+
+    for i, c := range cues {
+        s := speaker(c.Speaker)
+        duration := c.End - c.Start
+        s.SpeakingTime += duration
+        s.Words += len(strings.Fields(c.Text))
+
+        // Union of all speech intervals, for the silence ratio
+        if c.End > coveredUntil {
+            if c.Start > coveredUntil {
+                speechTime += duration
+            } else {
+                speechTime += c.End - coveredUntil
+            }
+            coveredUntil = c.End
+        }
+
+        // Overlaps with earlier cues of other speakers that are still running
+        interrupted := false
+        for j := i - 1; j >= 0; j-- {
+            prev := cues[j]
+            if prev.Speaker == c.Speaker || prev.End <= c.Start {
+                continue
+            }
+            overlap := [2]float64{c.Start, minFloat(prev.End, c.End)}
+            overlaps[c.Speaker] = append(overlaps[c.Speaker], overlap)
+            overlaps[prev.Speaker] = append(overlaps[prev.Speaker], overlap)
+            interrupted = true
+        }
+        if interrupted {
+            s.Interruptions++
+            analytics.Interruptions++
+        }
+
+        if c.Speaker != turnSpeaker {
+            closeTurn()
+            turnSpeaker, turnStart = c.Speaker, c.Start
+        }
+        turnEnd = c.End
+    }
+    closeTurn()
+
+    // The meeting is taken to start at 0 (start of the recording)
+    analytics.Duration = coveredUntil
+    analytics.SpeechTime = speechTime
+    if analytics.Duration > 0 {
+        analytics.SilenceRatio = (analytics.Duration - speechTime) / analytics.Duration
+    }
+    var totalSpeakingTime float64
+    for _, s := range stats {
+        totalSpeakingTime += s.SpeakingTime
+    }
+    for _, label := range order {
+        s := stats[label]
+        s.OverlapTime = unionLength(overlaps[label])
+        if totalSpeakingTime > 0 {
+            s.Share = s.SpeakingTime / totalSpeakingTime
+        }
+        analytics.Turns += s.Turns
+        analytics.Speakers = append(analytics.Speakers, *s)
+    }
+    sort.SliceStable(analytics.Speakers, func(i, j int) bool {
+        return analytics.Speakers[i].SpeakingTime > analytics.Speakers[j].SpeakingTime
+    })
+    return analytics
+}
+
+// unionLength returns the total length covered by intervals ([start, end] pairs)
+func unionLength(intervals [][2]float64) float64 {
+    sort.Slice(intervals, func(i, j int) bool { return intervals[i][0] < intervals[j][0] })
+    var length, coveredUntil float64
+    for i, interval := range intervals {
+        start, end := interval[0], interval[1]
+        if i > 0 && start < coveredUntil {
+            start = coveredUntil
+        }
+        if end > start {
+            length += end - start
+        }
+        if i == 0 || end > coveredUntil {
+            coveredUntil = end
+        }
+    }
+    return length
+}
+
+func minFloat(a float64, b float64) float64 {
+    if a < b {
+        return a
+    }
+    return b
+}
@@ -0,0 +1,126 @@
+package processing
+
+import (
+    "fmt"
+    "math"
+    "strings"
+    "testing"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// cue is a transcript line: speaker (empty for none), start and end in seconds
+type cue struct {
+    speaker    string
+    start, end int
+}
+
+// vtt builds a diarized .vtt transcript from cues
+func vtt(cues ...cue) string {
+    var b strings.Builder
+    b.WriteString("WEBVTT\n\n")
+    for _, c := range cues {
+        fmt.Fprintf(&b, "%02d:%02d.000 --> %02d:%02d.000\n", c.start/60, c.start%60, c.end/60, c.end%60)
+        if c.speaker != "" {
+            fmt.Fprintf(&b, "[%s]: ", c.speaker)
+        }
+        b.WriteString("one two three\n\n")
+    }
+    return b.String()
+}
+
+func speakerStats(t *testing.T, analytics types.Analytics, speaker string) types.SpeakerStats {
+    t.Helper()
+    for _, s := range analytics.Speakers {
+        if s.Speaker == speaker {
+            return s
+        }
+    }
+    t.Fatalf("no stats for %s in %+v", speaker, analytics.Speakers)
+    return types.SpeakerStats{}
+}
+
+func assertFloat(t *testing.T, what string, got float64, want float64) {
+    t.Helper()
+    if math.Abs(got - want) > 1e-9 {
+        t.Errorf("%s = %v, want %v", what, got, want)
+    }
+}
+
+func TestAnalyticsTurnsAndSilence(t *testing.T) {
+    analytics := ComputeAnalytics(vtt(
+        cue{"Ana", 0, 5},
+        cue{"Ana", 5, 10}, // same turn
+        cue{"Bob", 11, 15},
+        cue{"", 15, 16}, // not attributed to anyone
+        cue{"Ana", 16, 20},
+    ))
+
+    if analytics.Turns != 3 || analytics.Interruptions != 0 {
+        t.Errorf("Turns = %d, Interruptions = %d, want 3 and 0", analytics.Turns, analytics.Interruptions)
+    }
+    assertFloat(t, "Duration", analytics.Duration, 20)
+    assertFloat(t, "SpeechTime", analytics.SpeechTime, 18)
+    assertFloat(t, "SilenceRatio", analytics.SilenceRatio, 0.1)
+
+    if analytics.Speakers[0].Speaker != "Ana" {
+        t.Errorf("speakers should be sorted by speaking time, got %+v", analytics.Speakers)
+    }
+    ana := speakerStats(t, analytics, "Ana")
+    if ana.Turns != 2 || ana.Words != 9 {
+        t.Errorf("Ana: Turns = %d, Words = %d, want 2 and 9", ana.Turns, ana.Words)
+    }
+    assertFloat(t, "Ana SpeakingTime", ana.SpeakingTime, 14)
+    assertFloat(t, "Ana LongestMonologue", ana.LongestMonologue, 10)
+    assertFloat(t, "Ana Share", ana.Share, 14.0 / 18)
+    bob := speakerStats(t, analytics, "Bob")
+    assertFloat(t, "Bob LongestMonologue", bob.LongestMonologue, 4)
+}
+
+func TestAnalyticsInterruption(t *testing.T) {
+    analytics := ComputeAnalytics(vtt(
+        cue{"Ana", 0, 10},
+        cue{"Bob", 8, 12},
+    ))
+
+    if analytics.Interruptions != 1 {
+        t.Errorf("Interruptions = %d, want 1", analytics.Interruptions)
+    }
+    ana, bob := speakerStats(t, analytics, "Ana"), speakerStats(t, analytics, "Bob")
+    if ana.Interruptions != 0 || bob.Interruptions != 1 {
+        t.Errorf("Interruptions: Ana %d, Bob %d, want 0 and 1", ana.Interruptions, bob.Interruptions)
+    }
+    assertFloat(t, "Ana OverlapTime", ana.OverlapTime, 2)
+    assertFloat(t, "Bob OverlapTime", bob.OverlapTime, 2)
+    assertFloat(t, "SpeechTime", analytics.SpeechTime, 12)
+    assertFloat(t, "SilenceRatio", analytics.SilenceRatio, 0)
+}
+
+func TestAnalyticsSimultaneousOverlaps(t *testing.T) {
+    // Bob and Cid both talk over Ana, and over each other
+    analytics := ComputeAnalytics(vtt(
+        cue{"Ana", 0, 10},
+        cue{"Bob", 2, 8},
+        cue{"Cid", 3, 9},
+    ))
+
+    if analytics.Interruptions != 2 {
+        t.Errorf("Interruptions = %d, want 2", analytics.Interruptions)
+    }
+    // Overlaps are counted once, never more than the speaker's own speaking time
+    assertFloat(t, "Ana OverlapTime", speakerStats(t, analytics, "Ana").OverlapTime, 7)
+    assertFloat(t, "Bob OverlapTime", speakerStats(t, analytics, "Bob").OverlapTime, 6)
+    assertFloat(t, "Cid OverlapTime", speakerStats(t, analytics, "Cid").OverlapTime, 6)
+    for _, s := range analytics.Speakers {
+        if s.OverlapTime > s.SpeakingTime {
+            t.Errorf("%s: OverlapTime %v exceeds SpeakingTime %v", s.Speaker, s.OverlapTime, s.SpeakingTime)
+        }
+    }
+}
+
+func TestAnalyticsWithoutSpeakers(t *testing.T) {
+    analytics := ComputeAnalytics(vtt(cue{"", 0, 5}))
+    if analytics.Speakers == nil || len(analytics.Speakers) != 0 || analytics.Duration != 0 {
+        t.Errorf("ComputeAnalytics = %+v, want no speakers", analytics)
+    }
+}
@@ -0,0 +1,59 @@
+package processing
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "syscall"
+)
+
+// CheckResult is the outcome of a readiness check
+type CheckResult struct {
+    OK     bool   `json:"ok"`
+    Detail string `json:"detail,omitempty"`
+}
+
+// Commands run by the pipeline, python runs the summarizer, the speaker embeddings and the LLM server
+var requiredCommands = []string{"ffmpeg", "whisperx", "python"}
+
+// CheckCommands reports, per command the pipeline runs, whether it is on PATH
+func CheckCommands() map[string]CheckResult {
+    results := make(map[string]CheckResult)
+    for _, name := range requiredCommands {
+        path, err := exec.LookPath(name)
+        if err != nil {
+            results[name] = CheckResult{Detail: err.Error()}
+        } else {
+            results[name] = CheckResult{OK: true, Detail: path}
+        }
+    }
+    return results
+}
+
+// CheckModel reports whether the model file of the LLM has been downloaded
+func CheckModel() CheckResult {
+    info, err := os.Stat(llamaModelPath)
+    if err != nil {
+        return CheckResult{Detail: err.Error()}
+    }
+    if info.Size() == 0 {
+        return CheckResult{Detail: llamaModelPath + " is empty"}
+    }
+    return CheckResult{OK: true, Detail: llamaModelPath}
+}
+
+// CheckDiskSpace reports whether the temp directory, where uploads and intermediate files
+// are stored, has at least minFree bytes available
+func CheckDiskSpace(minFree int64) CheckResult {
+    dir := os.TempDir()
+    var stat syscall.Statfs_t
+    if err := syscall.Statfs(dir, &stat); err != nil {
+        return CheckResult{Detail: err.Error()}
+    }
+    free := int64(stat.Bavail) * int64(stat.Bsize)
+    detail := fmt.Sprintf("%d MB free in %s", free >> 20, dir)
+    if free < minFree {
+        return CheckResult{Detail: fmt.Sprintf("%s, %d MB required", detail, minFree >> 20)}
+    }
+    return CheckResult{OK: true, Detail: detail}
+}
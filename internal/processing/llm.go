@@ -0,0 +1,438 @@
+package processing
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+    "github.com/stanek-michal/go-ai-summarizer/internal/tracing"
+)
+
+// Port of the local llama-cpp-python server unless the server is configured otherwise
+const DefaultLlamaPort = 8000
+
+// Model loaded by the llama-cpp-python server, downloaded during installation
+const llamaModelPath = "./models/Qwen2.5-14B-Instruct-Q4_K_M.gguf"
+
+// The result of importing llama_cpp for the health check is reused: a success for
+// launchCheckInterval, a failure for launchRetryMin, doubling with every failure in a row
+// up to launchCheckInterval
+const (
+    launchRetryMin      = 10 * time.Second
+    launchCheckInterval = 5 * time.Minute
+)
+
+// ErrLLMClosed is returned by Acquire once the server has been shut down
+var ErrLLMClosed = errors.New("the LLM has been shut down")
+
+// ChatMessage is one message of an OpenAI-style chat completion
+type ChatMessage struct {
+    Role    string `json:"role"`
+    Content string `json:"content"`
+}
+
+// LLM manages the local llama-cpp-python server shared by summarization, Q&A and chat.
+// The server is started on first use and stopped once it has had no users for idleTimeout,
+// so the model does not stay in memory while whisperx is transcribing.
+type LLM struct {
+    mu          sync.Mutex
+    port        int           // the llama server listens on 127.0.0.1:port
+    process     *os.Process
+    starting    *llmStart     // set while the server is loading the model
+    users       int
+    idleTimeout time.Duration
+    idleTimer   *time.Timer
+    closed      bool          // set by Close, the server is not started again
+    closing     chan struct{} // closed by Close, aborts waiting for the model to load
+    closeOnce   sync.Once
+    launch      CheckResult   // last result of importing llama_cpp, reused until launchNext
+    launchNext  time.Time
+    launchWait  time.Duration // after the last failure, 0 after a success
+}
+
+// llmStart is a start of the server in progress, done is closed once it has loaded the model or failed
+type llmStart struct {
+    done chan struct{}
+    err  error
+}
+
+func NewLLM(idleTimeout time.Duration, port int) *LLM {
+    return &LLM{idleTimeout: idleTimeout, port: port, closing: make(chan struct{})}
+}
+
+// serverURL is the root of the llama server, its OpenAI-compatible API is under /v1
+func (l *LLM) serverURL() string {
+    return "http://127.0.0.1:" + strconv.Itoa(l.port)
+}
+
+// Acquire makes sure the llama server is running (waiting for the model to load, traced
+// as a span under ctx) and marks it in use until the matching Release. The lock is not held
+// while the model loads, concurrent callers wait for the same start.
+func (l *LLM) Acquire(ctx context.Context) error {
+    l.mu.Lock()
+    for l.starting != nil {
+        start := l.starting
+        l.mu.Unlock()
+        select {
+        case <-start.done:
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+        if start.err != nil {
+            return start.err
+        }
+        l.mu.Lock()
+    }
+    if l.closed {
+        l.mu.Unlock()
+        return ErrLLMClosed
+    }
+    if l.idleTimer != nil {
+        l.idleTimer.Stop()
+        l.idleTimer = nil
+    }
+    if l.process != nil {
+        l.users++
+        l.mu.Unlock()
+        return nil
+    }
+
+    start := &llmStart{done: make(chan struct{})}
+    l.starting = start
+    l.mu.Unlock()
+
+    _, span := tracer.Start(ctx, "llm.start")
+    process, err := startLlamaServer(l.serverURL(), l.port, l.closing)
+    tracing.End(span, err)
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if err == nil && l.closed {
+        killLocalLlamaProcess(process)
+        err = ErrLLMClosed
+    }
+    l.starting = nil
+    start.err = err
+    close(start.done)
+    if err != nil {
+        return err
+    }
+    l.process = process
+    l.users++
+    return nil
+}
+
+// Release marks the server as no longer in use by the caller, it is stopped after idleTimeout
+func (l *LLM) Release() {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.users--
+    if l.users > 0 || l.process == nil {
+        return
+    }
+    if l.idleTimeout <= 0 {
+        l.stop()
+        return
+    }
+    l.idleTimer = time.AfterFunc(l.idleTimeout, func() {
+        l.mu.Lock()
+        defer l.mu.Unlock()
+        if l.users == 0 {
+            l.stop()
+        }
+    })
+}
+
+// StopIfIdle stops the server right away unless someone is using it
+func (l *LLM) StopIfIdle() {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if l.users == 0 {
+        l.stop()
+    }
+}
+
+// Close stops the server for good, used on shutdown. Requests still using it fail.
+func (l *LLM) Close() {
+    l.closeOnce.Do(func() { close(l.closing) })
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.closed = true
+    l.stop()
+}
+
+// stop kills the server process group, caller must hold l.mu
+func (l *LLM) stop() {
+    if l.idleTimer != nil {
+        l.idleTimer.Stop()
+        l.idleTimer = nil
+    }
+    if l.process == nil {
+        return
+    }
+    killLocalLlamaProcess(l.process)
+    l.process = nil
+}
+
+// Check reports whether the LLM can serve requests: a running server must answer, a stopped
+// one must be startable, with the model downloaded and llama_cpp importable by python. A
+// server still loading the model is ready, requests wait for it.
+func (l *LLM) Check(ctx context.Context) CheckResult {
+    l.mu.Lock()
+    closed, starting, running := l.closed, l.starting != nil, l.process != nil
+    l.mu.Unlock()
+    if closed {
+        return CheckResult{Detail: ErrLLMClosed.Error()}
+    }
+    if starting {
+        return CheckResult{OK: true, Detail: "starting"}
+    }
+    if !running {
+        return l.checkLaunch(ctx)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", l.serverURL() + "/v1/models", nil)
+    if err != nil {
+        return CheckResult{Detail: err.Error()}
+    }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return CheckResult{Detail: err.Error()}
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return CheckResult{Detail: "server answered " + resp.Status}
+    }
+    return CheckResult{OK: true, Detail: "running"}
+}
+
+// checkLaunch reports whether a stopped server can be started: the model file exists and
+// python can import llama_cpp. The import is checked again only once its last result is due.
+func (l *LLM) checkLaunch(ctx context.Context) CheckResult {
+    if model := CheckModel(); !model.OK {
+        return model
+    }
+    l.mu.Lock()
+    launch, due := l.launch, !time.Now().Before(l.launchNext)
+    l.mu.Unlock()
+    if !due {
+        return launch
+    }
+
+    launch = CheckResult{OK: true, Detail: "stopped, started on demand"}
+    output, err := exec.CommandContext(ctx, "python", "-c", "import llama_cpp").CombinedOutput()
+    if err != nil {
+        if ctx.Err() != nil {
+            return CheckResult{Detail: ctx.Err().Error()} // the probe gave up, not python
+        }
+        detail := err.Error()
+        if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); lines[len(lines)-1] != "" {
+            detail = lines[len(lines)-1]
+        }
+        launch = CheckResult{Detail: "cannot import llama_cpp: " + detail}
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.launch = launch
+    if launch.OK {
+        l.launchWait = 0
+        l.launchNext = time.Now().Add(launchCheckInterval)
+        return launch
+    }
+    l.launchWait *= 2
+    if l.launchWait < launchRetryMin {
+        l.launchWait = launchRetryMin
+    }
+    if l.launchWait > launchCheckInterval {
+        l.launchWait = launchCheckInterval
+    }
+    l.launchNext = time.Now().Add(l.launchWait)
+    return launch
+}
+
+// ContextSize is the context window of the model in tokens
+func (l *LLM) ContextSize() int {
+    return llamaContextSize
+}
+
+// Chat runs a chat completion and returns the generated text
+func (l *LLM) Chat(ctx context.Context, messages []ChatMessage, maxTokens int) (string, error) {
+    return l.ChatStream(ctx, messages, maxTokens, nil)
+}
+
+// ChatStream runs a streaming chat completion, calling onToken (if not nil) with every
+// generated piece of text. Returns the complete generated text.
+func (l *LLM) ChatStream(ctx context.Context, messages []ChatMessage, maxTokens int, onToken func(string) error) (reply string, err error) {
+    ctx, span := tracer.Start(ctx, "llm.completion", trace.WithAttributes(attribute.String("llm.call", "chat")))
+    defer func() { tracing.End(span, err) }()
+    if err := l.Acquire(ctx); err != nil {
+        return "", err
+    }
+    defer l.Release()
+
+    body, err := json.Marshal(map[string]interface{}{
+        "model":       "any-model-name-here", // Llama-cpp doesn't strictly use this
+        "messages":    messages,
+        "temperature": 0.3,
+        "max_tokens":  maxTokens,
+        "stream":      true,
+        // Servers that support it send the token usage in a last chunk
+        "stream_options": map[string]bool{"include_usage": true},
+    })
+    if err != nil {
+        return "", err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", l.serverURL() + "/v1/chat/completions", bytes.NewReader(body))
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+        return "", fmt.Errorf("chat completion failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+    }
+
+    // Server-sent events, one JSON chunk per "data:" line until "data: [DONE]"
+    var text strings.Builder
+    scanner := bufio.NewScanner(resp.Body)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if !strings.HasPrefix(line, "data:") {
+            continue
+        }
+        data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+        if data == "[DONE]" {
+            break
+        }
+        var chunk struct {
+            Choices []struct {
+                Delta struct {
+                    Content string `json:"content"`
+                } `json:"delta"`
+            } `json:"choices"`
+            Usage *tokenUsage `json:"usage"`
+        }
+        if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+            return text.String(), err
+        }
+        if chunk.Usage != nil {
+            recordTokens(*chunk.Usage)
+            span.SetAttributes(attribute.Int("llm.prompt_tokens", chunk.Usage.PromptTokens),
+                attribute.Int("llm.completion_tokens", chunk.Usage.CompletionTokens))
+        }
+        if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+            continue
+        }
+        token := chunk.Choices[0].Delta.Content
+        text.WriteString(token)
+        if onToken != nil {
+            if err := onToken(token); err != nil {
+                return text.String(), err
+            }
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return text.String(), err
+    }
+    return text.String(), nil
+}
+
+// Start llama-cpp-python in server mode with an OpenAI-compatible API on port and wait for it
+// to load the model at serverURL, unless closing is closed in the meantime
+func startLlamaServer(serverURL string, port int, closing <-chan struct{}) (*os.Process, error) {
+    llamaCmd := exec.Command("python",
+        "-m", "llama_cpp.server",
+        "--model", llamaModelPath,
+        "--host", "127.0.0.1",
+        "--port", strconv.Itoa(port),
+        "--n_ctx", strconv.Itoa(llamaContextSize),
+        "--n_gpu_layers", "-1",
+        "--chat_format", "chatml",
+    )
+
+    // Set the process to run in its own new process group
+    llamaCmd.SysProcAttr = &syscall.SysProcAttr{
+        Setpgid: true,
+    }
+
+    // For logging
+    devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+    if err != nil {
+        slog.Error("Failed to open "+os.DevNull, "err", err)
+        return nil, err
+    }
+    defer devNull.Close()
+
+    llamaCmd.Stdout = devNull // Redirect stdout to /dev/null
+    llamaCmd.Stderr = devNull // Redirect stderr to /dev/null
+
+    if err := llamaCmd.Start(); err != nil {
+        slog.Error("Failed to start llama-cpp-python server", "err", err)
+        metrics.Failures.WithLabelValues("llm_start", failureReason(err)).Inc()
+        return nil, err
+    }
+    metrics.ProcessStarts.WithLabelValues("llama-server").Inc()
+    slog.Info("Started llama-cpp-python", "pid", llamaCmd.Process.Pid)
+
+    // Periodically call the health-check to see if server is up
+    // The OpenAI-compatible endpoint of llama-cpp-python is:
+    //  http://127.0.0.1:<port>/v1/models
+    apiURL := serverURL + "/v1/models"
+    counter := 0
+    for {
+        resp, err := http.Get(apiURL)
+        if err == nil && resp.StatusCode == 200 {
+            slog.Info("llama-cpp-python server initialized")
+            resp.Body.Close()
+            break
+        }
+        if resp != nil {
+            resp.Body.Close()
+        }
+
+        counter++
+        if counter > 1200 {
+            errorStr := "Error: llama-cpp-python did not initialize in 20 minutes, exiting.."
+            slog.Error(errorStr)
+            killLocalLlamaProcess(llamaCmd.Process)
+            metrics.Failures.WithLabelValues("llm_start", "timeout").Inc()
+            return nil, errors.New(errorStr)
+        }
+
+        select {
+        case <-closing:
+            killLocalLlamaProcess(llamaCmd.Process)
+            return nil, ErrLLMClosed
+        case <-time.After(1 * time.Second):
+        }
+    }
+    return llamaCmd.Process, nil
+}
@@ -0,0 +1,123 @@
+package processing
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+// installPython puts a python script on PATH that exits with status after printing output
+func installPython(t *testing.T, output string, status int) {
+    t.Helper()
+    bin := t.TempDir()
+    script := "#!/bin/sh\necho '" + output + "' >&2\nexit " + strconv.Itoa(status) + "\n"
+    if err := os.WriteFile(filepath.Join(bin, "python"), []byte(script), 0755); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("PATH", bin + string(os.PathListSeparator) + os.Getenv("PATH"))
+}
+
+func TestLLMCheck(t *testing.T) {
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dir := t.TempDir()
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    defer os.Chdir(wd)
+    ctx := context.Background()
+    llm := NewLLM(0, DefaultLlamaPort)
+
+    // Stopped without the model
+    installPython(t, "", 0)
+    if check := llm.Check(ctx); check.OK || !strings.Contains(check.Detail, llamaModelPath) {
+        t.Errorf("Check without the model = %+v", check)
+    }
+
+    // Stopped with the model, but llama_cpp is not installed
+    if err := os.MkdirAll(filepath.Dir(llamaModelPath), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(llamaModelPath, []byte("gguf"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    installPython(t, "ModuleNotFoundError: No module named llama_cpp", 1)
+    failed := "cannot import llama_cpp: ModuleNotFoundError: No module named llama_cpp"
+    if check := llm.Check(ctx); check.OK || check.Detail != failed {
+        t.Errorf("Check without llama_cpp = %+v", check)
+    }
+
+    // The result is reused until it is due, failures are checked again less and less often
+    due := func() {
+        llm.mu.Lock()
+        llm.launchNext = time.Time{}
+        llm.mu.Unlock()
+    }
+    installPython(t, "", 0)
+    if check := llm.Check(ctx); check.OK || check.Detail != failed {
+        t.Errorf("Check before the failure is due = %+v, want it reused", check)
+    }
+    installPython(t, "ModuleNotFoundError: No module named llama_cpp", 1)
+    for _, wait := range []time.Duration{20 * time.Second, 40 * time.Second, launchCheckInterval} {
+        if wait == launchCheckInterval {
+            llm.mu.Lock()
+            llm.launchWait = 4 * time.Minute
+            llm.mu.Unlock()
+        }
+        due()
+        before := time.Now()
+        if check := llm.Check(ctx); check.OK {
+            t.Errorf("Check without llama_cpp = %+v", check)
+        }
+        llm.mu.Lock()
+        if llm.launchWait != wait || llm.launchNext.Before(before.Add(wait)) {
+            t.Errorf("failure checked again in %v, want %v", llm.launchWait, wait)
+        }
+        llm.mu.Unlock()
+    }
+
+    // A probe that gives up is not a failure of python
+    due()
+    cancelled, cancel := context.WithCancel(ctx)
+    cancel()
+    if check := llm.Check(cancelled); check.OK || check.Detail != context.Canceled.Error() {
+        t.Errorf("Check of a cancelled probe = %+v", check)
+    }
+
+    // Stopped and startable, the success is reused too
+    installPython(t, "", 0)
+    if check := llm.Check(ctx); !check.OK || check.Detail != "stopped, started on demand" {
+        t.Errorf("Check of a startable server = %+v", check)
+    }
+    installPython(t, "ModuleNotFoundError: No module named llama_cpp", 1)
+    if check := llm.Check(ctx); !check.OK {
+        t.Errorf("Check before the success is due = %+v, want it reused", check)
+    }
+    llm.mu.Lock()
+    if llm.launchWait != 0 || time.Until(llm.launchNext) <= launchCheckInterval - time.Minute {
+        t.Errorf("success checked again at %v", time.Until(llm.launchNext))
+    }
+    llm.mu.Unlock()
+
+    // Loading the model, requests wait for it
+    llm.mu.Lock()
+    llm.starting = &llmStart{done: make(chan struct{})}
+    llm.mu.Unlock()
+    if check := llm.Check(ctx); !check.OK || check.Detail != "starting" {
+        t.Errorf("Check while starting = %+v", check)
+    }
+    llm.mu.Lock()
+    llm.starting = nil
+    llm.mu.Unlock()
+
+    llm.Close()
+    if check := llm.Check(ctx); check.OK || check.Detail != ErrLLMClosed.Error() {
+        t.Errorf("Check after Close = %+v", check)
+    }
+}
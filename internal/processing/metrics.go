@@ -0,0 +1,147 @@
+package processing
+
+import (
+    "context"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "io"
+    "io/fs"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "syscall"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+)
+
+// observeStage records the duration of a processing stage started at start, and its failure if err is set
+func observeStage(stage string, start time.Time, err error) {
+    metrics.StageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+    if err != nil {
+        metrics.Failures.WithLabelValues(stage, failureReason(err)).Inc()
+    }
+}
+
+// failureReason sorts an error into a few reasons to keep the metric's label values bounded
+func failureReason(err error) string {
+    var exitErr *exec.ExitError
+    switch {
+    case errors.As(err, &exitErr):
+        if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+            return "killed"
+        }
+        return "exit_status"
+    case errors.Is(err, ErrLLMClosed):
+        return "llm_closed"
+    case errors.Is(err, fs.ErrNotExist):
+        return "missing_file"
+    }
+    return "error"
+}
+
+// processName is the metric label of a subprocess, the script name for python
+func processName(cmd *exec.Cmd) string {
+    name := filepath.Base(cmd.Path)
+    if name == "python" && len(cmd.Args) > 1 {
+        return filepath.Base(cmd.Args[1])
+    }
+    return name
+}
+
+// recordAudio adds the length of a .wav recording to the audio processed and returns it,
+// 0 if it cannot be read
+func recordAudio(wavFilePath string) float64 {
+    seconds, err := wavDuration(wavFilePath)
+    if err != nil {
+        return 0
+    }
+    metrics.AudioSeconds.Add(seconds)
+    return seconds
+}
+
+// wavDuration reads the length of a .wav file in seconds from its RIFF header
+func wavDuration(path string) (float64, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer file.Close()
+
+    var riff [12]byte
+    if _, err := io.ReadFull(file, riff[:]); err != nil {
+        return 0, err
+    }
+    if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+        return 0, errors.New("not a wav file")
+    }
+    // Walk the chunks for the byte rate in "fmt " and the size of "data"
+    var byteRate uint32
+    for {
+        var header [8]byte
+        if _, err := io.ReadFull(file, header[:]); err != nil {
+            return 0, err
+        }
+        size := binary.LittleEndian.Uint32(header[4:8])
+        switch string(header[0:4]) {
+        case "fmt ":
+            var format [16]byte
+            if size < 16 {
+                return 0, errors.New("invalid fmt chunk")
+            }
+            if _, err := io.ReadFull(file, format[:]); err != nil {
+                return 0, err
+            }
+            byteRate = binary.LittleEndian.Uint32(format[8:12])
+            size -= 16
+        case "data":
+            if byteRate == 0 {
+                return 0, errors.New("data chunk before fmt chunk")
+            }
+            return float64(size) / float64(byteRate), nil
+        }
+        // Chunks are padded to an even size
+        if _, err := file.Seek(int64(size + size % 2), io.SeekCurrent); err != nil {
+            return 0, err
+        }
+    }
+}
+
+// tokenUsage mirrors the JSON the summarizer script writes with --usage-out
+type tokenUsage struct {
+    PromptTokens     int              `json:"prompt_tokens"`
+    CompletionTokens int              `json:"completion_tokens"`
+    Calls            []completionCall `json:"calls"`
+}
+
+// completionCall is one call of the summarizer script to the LLM
+type completionCall struct {
+    Name             string  `json:"name"`  // e.g. "chunk 3", "reduce 1.2", "extract 3"
+    Start            float64 `json:"start"` // Unix time in seconds
+    End              float64 `json:"end"`
+    PromptTokens     int     `json:"prompt_tokens"`
+    CompletionTokens int     `json:"completion_tokens"`
+    Error            string  `json:"error"`
+}
+
+// recordTokens adds the token usage of LLM calls
+func recordTokens(usage tokenUsage) {
+    metrics.LLMTokens.WithLabelValues("prompt").Add(float64(usage.PromptTokens))
+    metrics.LLMTokens.WithLabelValues("completion").Add(float64(usage.CompletionTokens))
+}
+
+// recordUsageFile adds the token usage written by the summarizer script, if it wrote any,
+// and traces its LLM calls as spans under ctx
+func recordUsageFile(ctx context.Context, path string) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return
+    }
+    var usage tokenUsage
+    if json.Unmarshal(data, &usage) == nil {
+        recordTokens(usage)
+        traceCompletions(ctx, usage.Calls)
+    }
+}
@@ -0,0 +1,108 @@
+package processing
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "testing"
+)
+
+// riffChunk returns a chunk of a RIFF file with the given size field, padded to an even length
+func riffChunk(id string, size uint32, data []byte) []byte {
+    chunk := append([]byte(id), binary.LittleEndian.AppendUint32(nil, size)...)
+    chunk = append(chunk, data...)
+    if len(data) % 2 == 1 {
+        chunk = append(chunk, 0)
+    }
+    return chunk
+}
+
+// fmtChunk is the "fmt " chunk of 16-bit PCM with byteRate bytes per second, with extra
+// bytes after the 16 of the format
+func fmtChunk(byteRate uint32, extra int) []byte {
+    format := make([]byte, 16 + extra)
+    binary.LittleEndian.PutUint16(format[0:2], 1) // PCM
+    binary.LittleEndian.PutUint16(format[2:4], 1)
+    binary.LittleEndian.PutUint32(format[4:8], byteRate / 2)
+    binary.LittleEndian.PutUint32(format[8:12], byteRate)
+    binary.LittleEndian.PutUint16(format[12:14], 2)
+    binary.LittleEndian.PutUint16(format[14:16], 16)
+    return riffChunk("fmt ", uint32(len(format)), format)
+}
+
+// wav joins chunks into a WAVE file
+func wav(chunks ...[]byte) []byte {
+    body := bytes.Join(chunks, nil)
+    return append(riffChunk("RIFF", uint32(4 + len(body)), nil), append([]byte("WAVE"), body...)...)
+}
+
+func TestWavDuration(t *testing.T) {
+    data := riffChunk("data", 32000, make([]byte, 32000))
+    tests := []struct {
+        name     string
+        file     []byte
+        duration float64
+        ok       bool
+    }{
+        {"pcm", wav(fmtChunk(16000, 0), data), 2, true},
+        {"data size from the header", wav(fmtChunk(16000, 0), riffChunk("data", 48000, nil)), 3, true},
+        {"chunk before data", wav(fmtChunk(16000, 0), riffChunk("LIST", 4, []byte("INFO")), data), 2, true},
+        {"odd-sized chunk", wav(fmtChunk(16000, 0), riffChunk("junk", 3, []byte("abc")), data), 2, true},
+        {"odd-sized fmt chunk", wav(fmtChunk(16000, 1), data), 2, true},
+        {"chunk before fmt", wav(riffChunk("bext", 5, []byte("hello")), fmtChunk(16000, 0), data), 2, true},
+        {"empty", nil, 0, false},
+        {"truncated RIFF header", []byte("RIFF\x24\x00"), 0, false},
+        {"not a wav file", append([]byte("RIFF\x04\x00\x00\x00AVI "), fmtChunk(16000, 0)...), 0, false},
+        {"truncated chunk header", append(wav(fmtChunk(16000, 0)), "da"...), 0, false},
+        {"truncated fmt chunk", wav([]byte("fmt \x10\x00\x00\x00\x01\x00")), 0, false},
+        {"short fmt chunk", wav(riffChunk("fmt ", 14, make([]byte, 14)), data), 0, false},
+        {"data before fmt", wav(data, fmtChunk(16000, 0)), 0, false},
+        {"no data", wav(fmtChunk(16000, 0), riffChunk("LIST", 4, []byte("INFO"))), 0, false},
+    }
+    dir := t.TempDir()
+    for i, tt := range tests {
+        path := filepath.Join(dir, fmt.Sprintf("%d.wav", i))
+        if err := os.WriteFile(path, tt.file, 0644); err != nil {
+            t.Fatal(err)
+        }
+        duration, err := wavDuration(path)
+        if (err == nil) != tt.ok || duration != tt.duration {
+            t.Errorf("%s: wavDuration = %v, %v, want %v", tt.name, duration, err, tt.duration)
+        }
+    }
+    if _, err := wavDuration(filepath.Join(dir, "missing.wav")); err == nil {
+        t.Error("wavDuration of a missing file succeeded")
+    }
+}
+
+func TestFailureReason(t *testing.T) {
+    exitStatus := exec.Command("sh", "-c", "exit 3").Run()
+    killed := exec.Command("sh", "-c", "kill -KILL $$").Run()
+    _, missing := os.Open(filepath.Join(t.TempDir(), "missing.vtt"))
+
+    tests := []struct {
+        name   string
+        err    error
+        reason string
+    }{
+        {"exit status", exitStatus, "exit_status"},
+        {"wrapped exit status", fmt.Errorf("whisperx: %w", exitStatus), "exit_status"},
+        {"killed", killed, "killed"},
+        {"llm closed", ErrLLMClosed, "llm_closed"},
+        {"wrapped llm closed", fmt.Errorf("summarize: %w", ErrLLMClosed), "llm_closed"},
+        {"missing file", missing, "missing_file"},
+        {"not exist", fs.ErrNotExist, "missing_file"},
+        {"other", errors.New("invalid fmt chunk"), "error"},
+        {"command not found", exec.Command("no-such-command-here").Run(), "error"},
+    }
+    for _, tt := range tests {
+        if reason := failureReason(tt.err); reason != tt.reason {
+            t.Errorf("%s: failureReason(%v) = %s, want %s", tt.name, tt.err, reason, tt.reason)
+        }
+    }
+}
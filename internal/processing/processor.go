@@ -2,49 +2,81 @@ package processing
 
 import (
     "bytes"
+    "context"
+    "encoding/json"
     "errors"
+    "fmt"
     "io/ioutil"
-    "log"
-    "net/http"
+    "log/slog"
     "os"
     "os/exec"
     "path/filepath"
+    "strconv"
     "strings"
+    "sync"
     "syscall"
-    "time"
 
+    "go.opentelemetry.io/otel/attribute"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+    "github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+    "github.com/stanek-michal/go-ai-summarizer/internal/tasklog"
     "github.com/stanek-michal/go-ai-summarizer/pkg/types"
 )
 
-type Processor struct{}
+type Processor struct {
+    speakers *speakers.Registry // enrolled voices for automatic speaker labeling, may be nil
+    llm      *LLM
+}
+
+// Context size of the local LLM, the summarizer script sizes transcript chunks to fit it
+const llamaContextSize = 25000
+
+// Summarization modes for transcripts longer than one chunk
+const (
+    ModeMapReduce = "map_reduce" // summarize each chunk, then merge the chunk summaries into one document
+    ModeConcat    = "concat"     // concatenate the chunk summaries
+)
+
+// SummaryOptions selects how a transcript is summarized
+type SummaryOptions struct {
+    Prompt  string // rendered summary template
+    Mode    string // ModeMapReduce if empty
+    Extract bool   // also extract action items, decisions and open questions
+}
 
-func NewProcessor() *Processor {
-    return &Processor{}
+// extractedItems mirrors the JSON the summarizer script writes with --extract-out
+type extractedItems struct {
+    ActionItems   []types.ActionItem
+    Decisions     []types.Decision
+    OpenQuestions []types.OpenQuestion
+}
+
+// ValidMode reports whether mode is a known summarization mode
+func ValidMode(mode string) bool {
+    return mode == ModeMapReduce || mode == ModeConcat
+}
+
+func NewProcessor(registry *speakers.Registry, llm *LLM) *Processor {
+    return &Processor{speakers: registry, llm: llm}
 }
 
 // convertToWav takes an absolute path to an MP4 file and converts it to a WAV file in the same path.
-func convertToWav(mp4FilePath string) (string, error) {
-    // Get basename
-    filename := filepath.Base(mp4FilePath)
+func convertToWav(logger *tasklog.Log, mp4FilePath string) (string, error) {
     // Determine the output WAV file path by changing the extension
     wavFilePath := changeFileExtension(mp4FilePath, ".wav")
 
     // Prepare the ffmpeg command
     cmd := exec.Command("ffmpeg", "-i", mp4FilePath, "-vn", "-acodec", "pcm_s16le", "-ar", "32000", "-ac", "2", wavFilePath)
 
-    // Create a log file to capture stdout and stderr
-    logFile, err := os.Create(truncateFileExtension(filename) + "_ffmpeg_output.log")
-    if err != nil {
-        return "", err
-    }
-    defer logFile.Close()
-
-    // Redirect stdout and stderr to the log file
-    cmd.Stdout = logFile
-    cmd.Stderr = logFile
+    // Capture stdout and stderr in the task log
+    output := logger.Output("ffmpeg")
+    cmd.Stdout = output
+    cmd.Stderr = output
 
     // Execute the ffmpeg command
-    err = cmd.Run()
+    err := runChild(cmd)
+    output.Close()
 
     // Grab the result code of the command
     exitCode := 0
@@ -55,14 +87,14 @@ func convertToWav(mp4FilePath string) (string, error) {
             }
         }
         if exitCode != 0 {
-            log.Printf("Error executing ffmpeg command: %v, errorcode: %v", err, exitCode)
+            logger.Error("Error executing ffmpeg command", "err", err, "exit_code", exitCode)
         } else {
-            log.Printf("Error executing ffmpeg command: %v", err)
+            logger.Error("Error executing ffmpeg command", "err", err)
         }
         return "", err
     }
 
-    log.Printf("ffmpeg conversion finished successfully, output file: %s", wavFilePath)
+    logger.Info("ffmpeg conversion finished successfully", "output", wavFilePath)
     return wavFilePath, nil
 }
 
@@ -73,28 +105,27 @@ func changeFileExtension(filePath string, newExt string) string {
     return truncateFileExtension(filePath) + newExt
 }
 
-// Generate diarized transcript from .wav with whisperx tool - may take many minutes
-func generateTranscript(filePath string) (string, string, error) {
+// Generate diarized transcript from .wav with whisperx tool - may take many minutes.
+// language is an ISO 639-1 code, whisperx detects the language if it is empty.
+func generateTranscript(logger *tasklog.Log, filePath string, language string) (string, string, error) {
     // Get basename and extension
     inputFilename := filepath.Base(filePath)
 
     // Prepare the command
-    cmd := exec.Command("whisperx", filePath, "--model", "large-v3", "--compute_type", "int8")
-
-    // Create output.log file to tee the output
-    outputFile, err := os.Create(truncateFileExtension(inputFilename) + "_whisperx_output.log")
-    if err != nil {
-        log.Printf("Error creating output.log file: %v", err)
-        return "", "", err
+    args := []string{filePath, "--model", "large-v3", "--compute_type", "int8"}
+    if language != "" {
+        args = append(args, "--language", language)
     }
-    defer outputFile.Close()
+    cmd := exec.Command("whisperx", args...)
 
-    // Redirect stdout and stderr to the log file
-    cmd.Stdout = outputFile
-    cmd.Stderr = outputFile
+    // Capture stdout and stderr in the task log
+    output := logger.Output("whisperx")
+    cmd.Stdout = output
+    cmd.Stderr = output
 
     // Execute the whisperx command
-    err = cmd.Run()
+    err := runChild(cmd)
+    output.Close()
 
     // Grab the result code of the command
     exitCode := 0
@@ -105,9 +136,9 @@ func generateTranscript(filePath string) (string, string, error) {
             }
         }
         if exitCode != 0 {
-            log.Printf("Error executing whisperx command: %v, errorcode: %v", err, exitCode)
+            logger.Error("Error executing whisperx command", "err", err, "exit_code", exitCode)
         } else {
-            log.Printf("Error executing whisperx command: %v", err)
+            logger.Error("Error executing whisperx command", "err", err)
         }
         return "", "", err
     }
@@ -115,130 +146,220 @@ func generateTranscript(filePath string) (string, string, error) {
     vttFilepath := changeFileExtension(inputFilename, ".vtt")
     vttBytes, err := ioutil.ReadFile(vttFilepath)
     if err != nil {
-        log.Printf("Error: %v not generated", vttFilepath)
+        logger.Error("Transcript not generated", "path", vttFilepath)
         return "", "", err
     } else {
         return string(vttBytes), vttFilepath, nil
     }
 }
 
-func killLocalLlamaProcess(p *os.Process) error {
+// Compute a voice embedding per speaker label of a diarized transcript, keyed by label
+func computeSpeakerEmbeddings(logger *tasklog.Log, wavFilePath string, transcriptFilepath string) (map[string][]float64, error) {
+    embeddingsFilepath := truncateFileExtension(transcriptFilepath) + "_embeddings.json"
+
+    cmd := exec.Command("python", "python/speaker_embeddings.py", wavFilePath, transcriptFilepath, embeddingsFilepath)
+    output := logger.Output("speaker_embeddings")
+    cmd.Stdout = output
+    cmd.Stderr = output
+    err := runChild(cmd)
+    output.Close()
+    if err != nil {
+        return nil, err
+    }
+
+    embeddingsBytes, err := ioutil.ReadFile(embeddingsFilepath)
+    if err != nil {
+        return nil, err
+    }
+    var embeddings map[string][]float64
+    if err := json.Unmarshal(embeddingsBytes, &embeddings); err != nil {
+        return nil, err
+    }
+    return embeddings, nil
+}
+
+// Label known voices in a diarized transcript with their enrolled names. Rewrites the .vtt file
+// so the summary uses the names too. Returns the (possibly renamed) transcript, the embeddings
+// keyed by the new labels and the recognized labels (original label -> name).
+func (p *Processor) recognizeSpeakers(ctx context.Context, logger *tasklog.Log, wavFilePath string, transcript string, transcriptFilepath string) (string, map[string][]float64, map[string]string) {
+    if len(SpeakerLabels(transcript)) == 0 {
+        return transcript, nil, nil
+    }
+    _, st := startStage(ctx, "speakers")
+    embeddings, err := computeSpeakerEmbeddings(logger, wavFilePath, transcriptFilepath)
+    st.end(err)
+    if err != nil {
+        logger.Warn("Speaker embeddings failed, skipping speaker recognition", "err", err)
+        return transcript, nil, nil
+    }
+    if p.speakers == nil {
+        return transcript, embeddings, nil
+    }
+
+    recognized := p.speakers.Identify(embeddings)
+    if len(recognized) == 0 {
+        return transcript, embeddings, nil
+    }
+    renamed := RenameSpeakers(transcript, recognized)
+    if err := ioutil.WriteFile(transcriptFilepath, []byte(renamed), 0644); err != nil {
+        logger.Warn("Failed to write renamed transcript, keeping speaker labels", "err", err)
+        return transcript, embeddings, nil
+    }
+    logger.Info("Recognized speakers", "speakers", recognized)
+    return renamed, RenameEmbeddings(embeddings, recognized), recognized
+}
+
+// Child processes (ffmpeg, whisperx and the python scripts) run in their own process groups,
+// so that KillChildren can stop them together with anything they started
+var (
+    childrenMu sync.Mutex
+    children   = make(map[*os.Process]bool)
+)
+
+// runChild runs cmd like cmd.Run, in a new process group that KillChildren can kill
+func runChild(cmd *exec.Cmd) error {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    if err := cmd.Start(); err != nil {
+        return err
+    }
+    metrics.ProcessStarts.WithLabelValues(processName(cmd)).Inc()
+    childrenMu.Lock()
+    children[cmd.Process] = true
+    childrenMu.Unlock()
+
+    err := cmd.Wait()
+
+    childrenMu.Lock()
+    delete(children, cmd.Process)
+    childrenMu.Unlock()
+    return err
+}
+
+// KillChildren terminates the process groups of all running child processes, used on shutdown.
+// The interrupted work fails like any other failed command.
+func KillChildren() {
+    childrenMu.Lock()
+    defer childrenMu.Unlock()
+    for process := range children {
+        signalProcessGroup(process, syscall.SIGTERM)
+    }
+}
+
+// signalProcessGroup sends sig to the process group of p
+func signalProcessGroup(p *os.Process, sig syscall.Signal) error {
     // Get the process group ID (PGID)
     pgid, err := syscall.Getpgid(p.Pid)
     if err != nil {
-        log.Printf("syscall.Getpgid() failed with %s\n", err)
-        return errors.New("could not get pgid of llama-cpp-python process")
+        slog.Error("syscall.Getpgid() failed", "pid", p.Pid, "err", err)
+        return err
     }
-    log.Printf("Attempting to kill local llama process with PID: %d and PGID: %d", p.Pid, pgid)
+    slog.Info("Signalling process group", "signal", sig, "pid", p.Pid, "pgid", pgid)
 
-    // Kill the process group
-    if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
-        log.Printf("syscall.Kill() failed with %s\n", err)
+    // Signal the whole process group
+    if err := syscall.Kill(-pgid, sig); err != nil {
+        slog.Error("syscall.Kill() failed", "pgid", pgid, "err", err)
+        return err
+    }
+    return nil
+}
+
+func killLocalLlamaProcess(p *os.Process) error {
+    if err := signalProcessGroup(p, syscall.SIGTERM); err != nil {
         return errors.New("could not kill llama-cpp-python server")
     }
-    log.Println("Kill signal sent successfully")
+    slog.Debug("Kill signal sent successfully", "pid", p.Pid)
 
     // Wait for the process to finish
     if _, err := p.Wait(); err != nil {
-        log.Printf("Process exited with error: %v", err)
+        slog.Warn("Process exited with error", "pid", p.Pid, "err", err)
     } else {
-        log.Println("Process wait completed without error")
+        slog.Debug("Process wait completed without error", "pid", p.Pid)
     }
 
     return nil
 }
 
-// Start the llama-cpp-python server, wait for it to load model, then run summarization script
-func generateSummary(transcript string, transcriptFilepath string) (string, error) {
-    // Start llama-cpp-python in server mode with an OpenAI-compatible API
-    llamaCmd := exec.Command("python",
-        "-m", "llama_cpp.server",
-        "--model", "./models/Qwen2.5-14B-Instruct-Q4_K_M.gguf",
-        "--host", "127.0.0.1",
-        "--port", "8000",
-        "--n_ctx", "25000",
-        "--n_gpu_layers", "-1",
-        "--chat_format", "chatml",
-    )
-
-    // Set the process to run in its own new process group
-    llamaCmd.SysProcAttr = &syscall.SysProcAttr{
-        Setpgid: true,
-    }
-
-    // For logging
-    devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
-    if err != nil {
-        log.Printf("Failed to open %s: %v", os.DevNull, err)
-        return "", err
+// Make sure the llama-cpp-python server is up, then run summarization script
+// with the given prompt and mode, optionally extracting structured items in the same run
+func (p *Processor) generateSummary(ctx context.Context, logger *tasklog.Log, transcriptFilepath string, opts SummaryOptions) (types.Summary, extractedItems, error) {
+    mode := opts.Mode
+    if mode == "" {
+        mode = ModeMapReduce
     }
-    defer devNull.Close()
-
-    llamaCmd.Stdout = devNull // Redirect stdout to /dev/null
-    llamaCmd.Stderr = devNull // Redirect stderr to /dev/null
-
-    if err := llamaCmd.Start(); err != nil {
-        log.Printf("Failed to start llama-cpp-python server: %v", err)
-        return "", err
+    if !ValidMode(mode) {
+        return types.Summary{}, extractedItems{}, fmt.Errorf("unknown summary mode: %s", mode)
     }
-    log.Printf("Started llama-cpp-python with PID: %d", llamaCmd.Process.Pid)
-
-    // Periodically call the health-check to see if server is up
-    // The default OpenAI-compatible endpoint for llama-cpp-python is:
-    //  http://127.0.0.1:8000/v1/models
-    apiURL := "http://127.0.0.1:8000/v1/models"
-    counter := 0
-    for {
-        resp, err := http.Get(apiURL)
-        if err == nil && resp.StatusCode == 200 {
-            log.Println("llama-cpp-python server initialized.")
-            resp.Body.Close()
-            break
-        }
-        if resp != nil {
-            resp.Body.Close()
-        }
 
-        counter++
-        if counter > 1200 {
-            errorStr := "Error: llama-cpp-python did not initialize in 20 minutes, exiting.."
-            log.Println(errorStr)
-            killLocalLlamaProcess(llamaCmd.Process)
-            return "", errors.New(errorStr)
-        }
-
-        time.Sleep(1 * time.Second)
+    // Write the preprompt next to the transcript so it is cleaned up together with it
+    promptFilepath := truncateFileExtension(transcriptFilepath) + "_prompt.txt"
+    chunksFilepath := truncateFileExtension(transcriptFilepath) + "_chunks.json"
+    itemsFilepath := truncateFileExtension(transcriptFilepath) + "_items.json"
+    usageFilepath := truncateFileExtension(transcriptFilepath) + "_usage.json"
+    if err := ioutil.WriteFile(promptFilepath, []byte(opts.Prompt), 0644); err != nil {
+        logger.Error("Failed to write prompt file", "path", promptFilepath, "err", err)
+        return types.Summary{}, extractedItems{}, err
     }
 
-    // Open or create the log file for appending
-    pythonLogFile, err := os.OpenFile("python_summarizer_log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-    if err != nil {
-        log.Println("Failed to open error log file:", err)
-        killLocalLlamaProcess(llamaCmd.Process)
-        return "", err
+    // Start the llama-cpp-python server (or reuse the running one) for the summarizer script
+    if err := p.llm.Acquire(ctx); err != nil {
+        return types.Summary{}, extractedItems{}, err
     }
-    defer pythonLogFile.Close()
+    defer p.llm.Release()
 
     // Run the Python summarizer script which will:
     // - preprocess, condense, chunk the .vtt transcript
-    // - call the local llama-cpp (OpenAI-compatible) API to generate a summary
-    pythonCmd := exec.Command("python", "python/generate_ai_summary.py", transcriptFilepath)
+    // - call the local llama-cpp (OpenAI-compatible) API to summarize every chunk
+    // - in map-reduce mode merge the chunk summaries into one document
+    // - with opts.Extract, extract action items, decisions and open questions as JSON
+    args := []string{"python/generate_ai_summary.py", transcriptFilepath, promptFilepath,
+        "--mode", mode, "--chunks-out", chunksFilepath, "--n-ctx", strconv.Itoa(llamaContextSize),
+        "--usage-out", usageFilepath, "--server-url", p.llm.serverURL()}
+    if opts.Extract {
+        args = append(args, "--extract-out", itemsFilepath)
+    }
+    pythonCmd := exec.Command("python", args...)
     var summaryBuf bytes.Buffer
     pythonCmd.Stdout = &summaryBuf
-    pythonCmd.Stderr = pythonLogFile
+    output := logger.Output("summarizer")
+    pythonCmd.Stderr = output
 
-    // Run summarization script
-    if err := pythonCmd.Run(); err != nil {
-        log.Printf("Error running python summarizer: %v\n", err)
-        killLocalLlamaProcess(llamaCmd.Process)
-        return "", err
+    // Run summarization script, its token usage is counted even if it fails halfway
+    err := runChild(pythonCmd)
+    output.Close()
+    recordUsageFile(ctx, usageFilepath)
+    if err != nil {
+        logger.Error("Error running python summarizer", "err", err)
+        return types.Summary{}, extractedItems{}, err
     }
 
-    // Kill the llama-cpp-python process
-    killLocalLlamaProcess(llamaCmd.Process)
+    // Chunk-level summaries are kept alongside the final one
+    var chunkSummaries []string
+    chunksBytes, err := ioutil.ReadFile(chunksFilepath)
+    if err == nil {
+        err = json.Unmarshal(chunksBytes, &chunkSummaries)
+    }
+    if err != nil {
+        logger.Warn("Could not read chunk summaries", "path", chunksFilepath, "err", err)
+    }
+
+    // Structured items are best effort, a failed extraction does not fail the summary
+    var items extractedItems
+    if opts.Extract {
+        itemsBytes, err := ioutil.ReadFile(itemsFilepath)
+        if err == nil {
+            err = json.Unmarshal(itemsBytes, &items)
+        }
+        if err != nil {
+            logger.Warn("Could not read extracted items", "path", itemsFilepath, "err", err)
+        }
+    }
 
     // Return summary
-    return summaryBuf.String(), nil
+    return types.Summary{
+        Mode:           mode,
+        Text:           summaryBuf.String(),
+        ChunkSummaries: chunkSummaries,
+    }, items, nil
 }
 
 // Remove all files starting with filePath after truncating extension
@@ -257,7 +378,7 @@ func RemoveFilesByPrefixAllExtensions(filePath string) error {
             if err != nil {
                 return err
             }
-            log.Printf("Removed: %s\n", path)
+            slog.Debug("Removed file", "path", path)
         }
         return nil
     })
@@ -277,25 +398,28 @@ func CleanUpUserFiles(filePath string, transcriptFilepath string) error {
     return nil
 }
 
-// Do processing on input file (usually /tmp/upload-<randomhexstring>.wav or .mp4 or .vtt)
-func (p *Processor) Process(filePath string) (types.Result, error) {
-    log.Printf("Running Process() for: %v", filePath)
+// Do processing on input file (usually /tmp/upload-<randomhexstring>.wav or .mp4 or .vtt),
+// the generated summary is also returned as the only entry of Result.Summaries
+func (p *Processor) Process(ctx context.Context, logger *tasklog.Log, filePath string, opts SummaryOptions) (types.Result, error) {
+    logger.Info("Processing upload", "path", filePath)
     // Get basename and extension
     baseFilename := filepath.Base(filePath)
     extension := filepath.Ext(baseFilename)
 
     // Make sure input file exists
     if _, err := os.Stat(filePath); err != nil {
-        log.Printf("Input file does not exist: %v", err)
+        logger.Error("Input file does not exist", "err", err)
         return types.Result{}, err
     }
 
     if extension == ".mp4" {
-        log.Printf("Converting %v to .wav", filePath)
-        convertedFilePath, err := convertToWav(filePath)
+        logger.Info("Converting to .wav")
+        _, st := startStage(ctx, "convert")
+        convertedFilePath, err := convertToWav(logger, filePath)
+        st.end(err)
         if err != nil {
-            log.Printf("Conversion to .wav failed, error: %v", err)
-            CleanUpUserFiles(filePath, "")
+            logger.Error("Conversion to .wav failed", "err", err)
+            cleanUp(ctx, filePath, "")
             return types.Result{ErrorMsg: err.Error()}, err
         }
         filePath = convertedFilePath
@@ -303,33 +427,118 @@ func (p *Processor) Process(filePath string) (types.Result, error) {
     }
 
     if extension == ".wav" {
-        log.Printf("Generating transcript for %v", filePath)
-        transcript, transcriptFilepath, err := generateTranscript(filePath)
+        // Free the model memory for whisperx if the LLM is idling after earlier work
+        p.llm.StopIfIdle()
+
+        logger.Info("Generating transcript", "path", filePath)
+        _, st := startStage(ctx, "transcribe")
+        transcript, transcriptFilepath, err := generateTranscript(logger, filePath, "")
+        if err == nil {
+            st.span.SetAttributes(attribute.Float64("audio.seconds", recordAudio(filePath)))
+        }
+        st.end(err)
         if err != nil {
-            log.Printf("Transcript generation failed, error: %v", err)
-            CleanUpUserFiles(filePath, "")
+            logger.Error("Transcript generation failed", "err", err)
+            cleanUp(ctx, filePath, "")
             return types.Result{ErrorMsg: err.Error()}, err
         }
-        log.Printf("Generating summary for %v", transcriptFilepath)
-        summary, err := generateSummary(transcript, transcriptFilepath)
+        transcript, embeddings, recognized := p.recognizeSpeakers(ctx, logger, filePath, transcript, transcriptFilepath)
+
+        logger.Info("Generating summary", "path", transcriptFilepath)
+        summaryCtx, st := startStage(ctx, "summarize", attribute.String("mode", opts.Mode))
+        summary, items, err := p.generateSummary(summaryCtx, logger, transcriptFilepath, opts)
+        st.end(err)
         if err != nil {
-            log.Printf("Summary generation failed, error: %v", err)
-            CleanUpUserFiles(filePath, transcriptFilepath)
-            return types.Result{Transcript: transcript, ErrorMsg: err.Error()}, err
+            logger.Error("Summary generation failed", "err", err)
+            cleanUp(ctx, filePath, transcriptFilepath)
+            return types.Result{
+                Transcript:         transcript,
+                SpeakerEmbeddings:  embeddings,
+                RecognizedSpeakers: recognized,
+                ErrorMsg:           err.Error(),
+            }, err
         }
         // Populate the result object
         result := types.Result{
-            Transcript: transcript,
-            Summary:    summary,
-            ErrorMsg:   "",
+            Transcript:         transcript,
+            Summary:            summary.Text,
+            Summaries:          []types.Summary{summary},
+            ActionItems:        items.ActionItems,
+            Decisions:          items.Decisions,
+            OpenQuestions:      items.OpenQuestions,
+            RecognizedSpeakers: recognized,
+            SpeakerEmbeddings:  embeddings,
+            ErrorMsg:           "",
         }
-        log.Printf("Generated summary for %v", transcriptFilepath)
-        CleanUpUserFiles(filePath, transcriptFilepath)
+        logger.Info("Generated summary")
+        cleanUp(ctx, filePath, transcriptFilepath)
         return result, nil
     } else {
-        log.Printf("Unknown extension: %v", extension)
-        CleanUpUserFiles(filePath, "")
+        logger.Error("Unknown extension", "extension", extension)
+        cleanUp(ctx, filePath, "")
         return types.Result{}, nil
     }
 }
 
+// Transcribe only generates the transcript of a .wav or .mp4 file (with enrolled speakers
+// recognized), without summarizing it. language may be empty to let whisperx detect it.
+func (p *Processor) Transcribe(ctx context.Context, logger *tasklog.Log, filePath string, language string) (types.Result, error) {
+    logger.Info("Transcribing upload", "path", filePath, "language", language)
+    extension := filepath.Ext(filePath)
+    if extension == ".mp4" {
+        _, st := startStage(ctx, "convert")
+        convertedFilePath, err := convertToWav(logger, filePath)
+        st.end(err)
+        if err != nil {
+            logger.Error("Conversion to .wav failed", "err", err)
+            cleanUp(ctx, filePath, "")
+            return types.Result{ErrorMsg: err.Error()}, err
+        }
+        filePath = convertedFilePath
+    } else if extension != ".wav" {
+        cleanUp(ctx, filePath, "")
+        err := fmt.Errorf("unknown extension: %v", extension)
+        return types.Result{ErrorMsg: err.Error()}, err
+    }
+
+    p.llm.StopIfIdle()
+    _, st := startStage(ctx, "transcribe", attribute.String("language", language))
+    transcript, transcriptFilepath, err := generateTranscript(logger, filePath, language)
+    if err == nil {
+        st.span.SetAttributes(attribute.Float64("audio.seconds", recordAudio(filePath)))
+    }
+    st.end(err)
+    if err != nil {
+        logger.Error("Transcript generation failed", "err", err)
+        cleanUp(ctx, filePath, "")
+        return types.Result{ErrorMsg: err.Error()}, err
+    }
+    transcript, embeddings, recognized := p.recognizeSpeakers(ctx, logger, filePath, transcript, transcriptFilepath)
+    cleanUp(ctx, filePath, transcriptFilepath)
+    return types.Result{Transcript: transcript, RecognizedSpeakers: recognized, SpeakerEmbeddings: embeddings}, nil
+}
+
+// Summarize runs summarization again on an existing transcript (.vtt contents) with the given options
+func (p *Processor) Summarize(ctx context.Context, logger *tasklog.Log, transcript string, opts SummaryOptions) (types.Summary, error) {
+    // The summarizer script works on files, so store the transcript in a temp .vtt first
+    transcriptFile, err := os.CreateTemp("", "resummary-*.vtt")
+    if err != nil {
+        logger.Error("Failed to create transcript file", "err", err)
+        return types.Summary{}, err
+    }
+    transcriptFilepath := transcriptFile.Name()
+    defer cleanUp(ctx, transcriptFilepath, "")
+
+    _, err = transcriptFile.WriteString(transcript)
+    transcriptFile.Close()
+    if err != nil {
+        logger.Error("Failed to write transcript file", "err", err)
+        return types.Summary{}, err
+    }
+
+    logger.Info("Generating summary", "path", transcriptFilepath, "mode", opts.Mode)
+    summaryCtx, st := startStage(ctx, "summarize", attribute.String("mode", opts.Mode))
+    summary, _, err := p.generateSummary(summaryCtx, logger, transcriptFilepath, opts)
+    st.end(err)
+    return summary, err
+}
@@ -0,0 +1,63 @@
+package processing
+
+import (
+    "strings"
+)
+
+// Diarized whisperx .vtt cue lines look like "[SPEAKER_00]: some text"
+
+// speakerLabel returns the speaker label of a diarized cue line, if it has one
+func speakerLabel(line string) (string, bool) {
+    if !strings.HasPrefix(line, "[") {
+        return "", false
+    }
+    end := strings.Index(line, "]: ")
+    if end < 2 {
+        return "", false
+    }
+    return line[1:end], true
+}
+
+// SpeakerLabels returns the distinct speaker labels of a diarized transcript in order of first appearance
+func SpeakerLabels(transcript string) []string {
+    var labels []string
+    seen := make(map[string]bool)
+    for _, line := range strings.Split(transcript, "\n") {
+        label, ok := speakerLabel(line)
+        if ok && !seen[label] {
+            seen[label] = true
+            labels = append(labels, label)
+        }
+    }
+    return labels
+}
+
+// RenameSpeakers replaces speaker labels of a diarized transcript according to names (label -> new name)
+func RenameSpeakers(transcript string, names map[string]string) string {
+    lines := strings.Split(transcript, "\n")
+    for i, line := range lines {
+        label, ok := speakerLabel(line)
+        if !ok {
+            continue
+        }
+        if name, ok := names[label]; ok {
+            lines[i] = "[" + name + line[len(label)+1:]
+        }
+    }
+    return strings.Join(lines, "\n")
+}
+
+// RenameEmbeddings re-keys per-label speaker embeddings according to names (label -> new name)
+func RenameEmbeddings(embeddings map[string][]float64, names map[string]string) map[string][]float64 {
+    if embeddings == nil {
+        return nil
+    }
+    renamed := make(map[string][]float64, len(embeddings))
+    for label, embedding := range embeddings {
+        if name, ok := names[label]; ok {
+            label = name
+        }
+        renamed[label] = embedding
+    }
+    return renamed
+}
@@ -0,0 +1,67 @@
+package processing
+
+import (
+    "reflect"
+    "testing"
+)
+
+const diarized = "WEBVTT\n\n" +
+    "00:00.000 --> 00:05.000\n[SPEAKER_00]: Let's start.\n\n" +
+    "00:05.000 --> 00:09.000\n[SPEAKER_01]: Ana, the rollout [moves]: to March.\n\n" +
+    "00:09.000 --> 00:12.000\nNo speaker here.\n\n" +
+    "00:12.000 --> 00:15.000\n[SPEAKER_00]: Fine.\n"
+
+func TestSpeakerLabels(t *testing.T) {
+    tests := []struct {
+        name       string
+        transcript string
+        labels     []string
+    }{
+        {"diarized", diarized, []string{"SPEAKER_00", "SPEAKER_01"}},
+        {"not diarized", "WEBVTT\n\n00:00.000 --> 00:05.000\nLet's start.\n", nil},
+        {"empty label", "[]: nobody\n", nil},
+        {"bracket without colon", "[laughs] and then\n", nil},
+    }
+    for _, tt := range tests {
+        if labels := SpeakerLabels(tt.transcript); !reflect.DeepEqual(labels, tt.labels) {
+            t.Errorf("%s: SpeakerLabels = %v, want %v", tt.name, labels, tt.labels)
+        }
+    }
+}
+
+func TestRenameSpeakers(t *testing.T) {
+    renamed := RenameSpeakers(diarized, map[string]string{"SPEAKER_00": "Ana", "SPEAKER_02": "Eve"})
+
+    want := "WEBVTT\n\n" +
+        "00:00.000 --> 00:05.000\n[Ana]: Let's start.\n\n" +
+        "00:05.000 --> 00:09.000\n[SPEAKER_01]: Ana, the rollout [moves]: to March.\n\n" +
+        "00:09.000 --> 00:12.000\nNo speaker here.\n\n" +
+        "00:12.000 --> 00:15.000\n[Ana]: Fine.\n"
+    if renamed != want {
+        t.Errorf("RenameSpeakers =\n%s\nwant\n%s", renamed, want)
+    }
+    if labels := SpeakerLabels(renamed); !reflect.DeepEqual(labels, []string{"Ana", "SPEAKER_01"}) {
+        t.Errorf("labels after renaming = %v", labels)
+    }
+
+    // Names can be swapped in one go
+    swapped := RenameSpeakers(diarized, map[string]string{"SPEAKER_00": "SPEAKER_01", "SPEAKER_01": "SPEAKER_00"})
+    if labels := SpeakerLabels(swapped); !reflect.DeepEqual(labels, []string{"SPEAKER_01", "SPEAKER_00"}) {
+        t.Errorf("labels after swapping = %v", labels)
+    }
+}
+
+func TestRenameEmbeddings(t *testing.T) {
+    if RenameEmbeddings(nil, map[string]string{"SPEAKER_00": "Ana"}) != nil {
+        t.Error("renaming no embeddings should keep them nil")
+    }
+    embeddings := map[string][]float64{"SPEAKER_00": {1, 0}, "SPEAKER_01": {0, 1}}
+    renamed := RenameEmbeddings(embeddings, map[string]string{"SPEAKER_00": "Ana"})
+    want := map[string][]float64{"Ana": {1, 0}, "SPEAKER_01": {0, 1}}
+    if !reflect.DeepEqual(renamed, want) {
+        t.Errorf("RenameEmbeddings = %v, want %v", renamed, want)
+    }
+    if _, ok := embeddings["Ana"]; ok {
+        t.Error("RenameEmbeddings modified its input")
+    }
+}
@@ -0,0 +1,66 @@
+package processing
+
+import (
+    "context"
+    "errors"
+    "math"
+    "time"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/trace"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/tracing"
+)
+
+var tracer = otel.Tracer("github.com/stanek-michal/go-ai-summarizer/internal/processing")
+
+// stage is a running processing stage, traced as a span and measured by the stage metrics
+type stage struct {
+    name  string
+    start time.Time
+    span  trace.Span
+}
+
+// startStage starts a stage span under ctx, end it with stage.end
+func startStage(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *stage) {
+    ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+    return ctx, &stage{name: name, start: time.Now(), span: span}
+}
+
+func (s *stage) end(err error) {
+    observeStage(s.name, s.start, err)
+    tracing.End(s.span, err)
+}
+
+// cleanUp removes the files of a task like CleanUpUserFiles, traced as a span
+func cleanUp(ctx context.Context, filePath string, transcriptFilepath string) {
+    _, span := tracer.Start(ctx, "cleanup")
+    tracing.End(span, CleanUpUserFiles(filePath, transcriptFilepath))
+}
+
+// traceCompletions adds spans for LLM calls that were timed by the summarizer script
+func traceCompletions(ctx context.Context, calls []completionCall) {
+    for _, call := range calls {
+        if call.End == 0 {
+            continue // the script was killed during the call
+        }
+        _, span := tracer.Start(ctx, "llm.completion", trace.WithTimestamp(unixTime(call.Start)), trace.WithAttributes(
+            attribute.String("llm.call", call.Name),
+            attribute.Int("llm.prompt_tokens", call.PromptTokens),
+            attribute.Int("llm.completion_tokens", call.CompletionTokens),
+        ))
+        if call.Error != "" {
+            span.RecordError(errors.New(call.Error))
+            span.SetStatus(codes.Error, call.Error)
+        }
+        span.End(trace.WithTimestamp(unixTime(call.End)))
+    }
+}
+
+// unixTime converts Unix time in (fractional) seconds, as Python's time.time() returns it
+func unixTime(seconds float64) time.Time {
+    whole, fraction := math.Modf(seconds)
+    return time.Unix(int64(whole), int64(fraction * 1e9))
+}
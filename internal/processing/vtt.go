@@ -0,0 +1,79 @@
+package processing
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Cue is one timed block of a .vtt transcript, Speaker is empty for non-diarized transcripts
+type Cue struct {
+    Start   float64
+    End     float64
+    Speaker string
+    Text    string
+}
+
+// parseVTTTime parses "mm:ss.mmm" or "hh:mm:ss.mmm" into seconds
+func parseVTTTime(value string) (float64, bool) {
+    var seconds float64
+    for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+        v, err := strconv.ParseFloat(part, 64)
+        if err != nil {
+            return 0, false
+        }
+        seconds = seconds*60 + v
+    }
+    return seconds, true
+}
+
+// parseTimeRange parses a "00:00.651 --> 00:28.203" cue timing line (cue settings may follow)
+func parseTimeRange(line string) (float64, float64, bool) {
+    times := strings.Split(line, " --> ")
+    if len(times) != 2 {
+        return 0, 0, false
+    }
+    endFields := strings.Fields(times[1])
+    if len(endFields) == 0 {
+        return 0, 0, false
+    }
+    start, okStart := parseVTTTime(times[0])
+    end, okEnd := parseVTTTime(endFields[0])
+    if !okStart || !okEnd || end < start {
+        return 0, 0, false
+    }
+    return start, end, true
+}
+
+// ParseCues returns the cues of a .vtt transcript ordered by start time
+func ParseCues(transcript string) []Cue {
+    var cues []Cue
+    lines := strings.Split(transcript, "\n")
+    for i := 0; i < len(lines); i++ {
+        start, end, ok := parseTimeRange(strings.TrimSpace(lines[i]))
+        if !ok {
+            continue
+        }
+        // Cue text runs until the next blank line
+        var text []string
+        for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+            i++
+            text = append(text, strings.TrimSpace(lines[i]))
+        }
+        c := Cue{Start: start, End: end, Text: strings.Join(text, " ")}
+        if label, ok := speakerLabel(c.Text); ok {
+            c.Speaker = label
+            c.Text = c.Text[len(label)+4:]
+        }
+        cues = append(cues, c)
+    }
+    sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+    return cues
+}
+
+// FormatTimestamp formats seconds as "hh:mm:ss"
+func FormatTimestamp(seconds float64) string {
+    total := int(seconds)
+    return fmt.Sprintf("%02d:%02d:%02d", total/3600, total/60%60, total%60)
+}
@@ -0,0 +1,317 @@
+package prompts
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "text/template"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// Name of the summary template used when an upload does not pick one
+const DefaultTemplate = "detailed"
+
+var (
+    ErrNotFound        = errors.New("template not found")
+    ErrExists          = errors.New("template already exists")
+    ErrInvalidName     = errors.New("invalid template name")
+    ErrInvalidTemplate = errors.New("invalid template")
+    ErrProtected       = errors.New("the default template cannot be deleted")
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Functions available to templates in addition to the text/template builtins
+var funcs = template.FuncMap{
+    "join": strings.Join,
+}
+
+// Template is one version of a named summary prompt template
+type Template struct {
+    Name      string
+    Version   int
+    Body      string
+    UpdatedAt time.Time
+}
+
+// Store keeps versioned prompt templates as files in a directory:
+// <dir>/<name>/v<version>.tmpl, a new version is written on every update.
+// Deleting a template only marks it with a <dir>/<name>/deleted file, so that summaries
+// pinned to one of its versions can still be rendered.
+type Store struct {
+    dir string
+    mu  sync.Mutex
+}
+
+func NewStore(dir string) (*Store, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    return &Store{dir: dir}, nil
+}
+
+// List returns the latest version of every template, sorted by name
+func (s *Store) List() ([]Template, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    entries, err := os.ReadDir(s.dir)
+    if err != nil {
+        return nil, err
+    }
+    templates := make([]Template, 0, len(entries))
+    for _, entry := range entries {
+        if !entry.IsDir() || !validName.MatchString(entry.Name()) || s.deleted(entry.Name()) {
+            continue
+        }
+        versions, err := s.versions(entry.Name())
+        if err != nil {
+            return nil, err
+        }
+        if len(versions) == 0 {
+            continue
+        }
+        tmpl, err := s.read(entry.Name(), versions[len(versions)-1])
+        if err != nil {
+            return nil, err
+        }
+        templates = append(templates, *tmpl)
+    }
+    sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+    return templates, nil
+}
+
+// Get returns the given version of a template, or the latest one if version is 0
+func (s *Store) Get(name string, version int) (*Template, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if validName.MatchString(name) && s.deleted(name) {
+        return nil, ErrNotFound
+    }
+    return s.get(name, version)
+}
+
+// Versions returns all versions of a template, oldest first
+func (s *Store) Versions(name string) ([]Template, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if !validName.MatchString(name) {
+        return nil, ErrInvalidName
+    }
+    versions, err := s.versions(name)
+    if err != nil {
+        return nil, err
+    }
+    if len(versions) == 0 || s.deleted(name) {
+        return nil, ErrNotFound
+    }
+    templates := make([]Template, 0, len(versions))
+    for _, version := range versions {
+        tmpl, err := s.read(name, version)
+        if err != nil {
+            return nil, err
+        }
+        templates = append(templates, *tmpl)
+    }
+    return templates, nil
+}
+
+// Create adds a new template, it fails if a template with this name already exists.
+// A deleted template's name can be reused, numbering continues after its old versions.
+func (s *Store) Create(name string, body string) (*Template, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if !validName.MatchString(name) {
+        return nil, ErrInvalidName
+    }
+    versions, err := s.versions(name)
+    if err != nil {
+        return nil, err
+    }
+    if len(versions) == 0 {
+        return s.write(name, 1, body)
+    }
+    if !s.deleted(name) {
+        return nil, ErrExists
+    }
+    tmpl, err := s.write(name, versions[len(versions)-1]+1, body)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.Remove(s.deletedPath(name)); err != nil {
+        return nil, err
+    }
+    return tmpl, nil
+}
+
+// Update stores body as the next version of an existing template
+func (s *Store) Update(name string, body string) (*Template, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if !validName.MatchString(name) {
+        return nil, ErrInvalidName
+    }
+    versions, err := s.versions(name)
+    if err != nil {
+        return nil, err
+    }
+    if len(versions) == 0 || s.deleted(name) {
+        return nil, ErrNotFound
+    }
+    return s.write(name, versions[len(versions)-1]+1, body)
+}
+
+// Delete hides a template from List and Get, its versions stay on disk for Render.
+// The default template cannot be deleted.
+func (s *Store) Delete(name string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if !validName.MatchString(name) {
+        return ErrInvalidName
+    }
+    if name == DefaultTemplate {
+        return ErrProtected
+    }
+    versions, err := s.versions(name)
+    if err != nil {
+        return err
+    }
+    if len(versions) == 0 || s.deleted(name) {
+        return ErrNotFound
+    }
+    return os.WriteFile(s.deletedPath(name), nil, 0644)
+}
+
+// Render executes the given template version (latest if 0) with the meeting details.
+// Versions of deleted templates can still be rendered, queued summaries are pinned to them.
+func (s *Store) Render(name string, version int, meeting types.Meeting) (string, *Template, error) {
+    s.mu.Lock()
+    tmpl, err := s.get(name, version)
+    s.mu.Unlock()
+    if err != nil {
+        return "", nil, err
+    }
+
+    parsed, err := parse(tmpl.Name, tmpl.Body)
+    if err != nil {
+        return "", nil, err
+    }
+    var buf bytes.Buffer
+    if err := parsed.Execute(&buf, meeting); err != nil {
+        return "", nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+    }
+    return buf.String(), tmpl, nil
+}
+
+func parse(name string, body string) (*template.Template, error) {
+    parsed, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(body)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+    }
+    return parsed, nil
+}
+
+// Following helpers expect the caller to hold s.mu
+
+func (s *Store) get(name string, version int) (*Template, error) {
+    if !validName.MatchString(name) {
+        return nil, ErrInvalidName
+    }
+    if version == 0 {
+        versions, err := s.versions(name)
+        if err != nil {
+            return nil, err
+        }
+        if len(versions) == 0 || s.deleted(name) {
+            return nil, ErrNotFound
+        }
+        version = versions[len(versions)-1]
+    }
+    return s.read(name, version)
+}
+
+func (s *Store) deletedPath(name string) string {
+    return filepath.Join(s.dir, name, "deleted")
+}
+
+// deleted reports whether the template was deleted
+func (s *Store) deleted(name string) bool {
+    _, err := os.Stat(s.deletedPath(name))
+    return err == nil
+}
+
+// versions returns the sorted version numbers stored for a template
+func (s *Store) versions(name string) ([]int, error) {
+    entries, err := os.ReadDir(filepath.Join(s.dir, name))
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, nil
+        }
+        return nil, err
+    }
+    var versions []int
+    for _, entry := range entries {
+        fileName := entry.Name()
+        if !strings.HasPrefix(fileName, "v") || !strings.HasSuffix(fileName, ".tmpl") {
+            continue
+        }
+        version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(fileName, "v"), ".tmpl"))
+        if err != nil || version < 1 {
+            continue
+        }
+        versions = append(versions, version)
+    }
+    sort.Ints(versions)
+    return versions, nil
+}
+
+func (s *Store) path(name string, version int) string {
+    return filepath.Join(s.dir, name, "v" + strconv.Itoa(version) + ".tmpl")
+}
+
+func (s *Store) read(name string, version int) (*Template, error) {
+    path := s.path(name, version)
+    info, err := os.Stat(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, ErrNotFound
+        }
+        return nil, err
+    }
+    body, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return &Template{Name: name, Version: version, Body: string(body), UpdatedAt: info.ModTime()}, nil
+}
+
+func (s *Store) write(name string, version int, body string) (*Template, error) {
+    // Refuse templates that would fail at summarization time
+    if strings.TrimSpace(body) == "" {
+        return nil, fmt.Errorf("%w: empty body", ErrInvalidTemplate)
+    }
+    if _, err := parse(name, body); err != nil {
+        return nil, err
+    }
+
+    if err := os.MkdirAll(filepath.Join(s.dir, name), 0755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(s.path(name, version), []byte(body), 0644); err != nil {
+        return nil, err
+    }
+    return s.read(name, version)
+}
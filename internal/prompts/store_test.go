@@ -0,0 +1,197 @@
+package prompts
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+func newStore(t *testing.T) *Store {
+    t.Helper()
+    s, err := NewStore(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Create(DefaultTemplate, "Summarize {{.Title}}."); err != nil {
+        t.Fatal(err)
+    }
+    return s
+}
+
+func TestCreateAndUpdate(t *testing.T) {
+    s := newStore(t)
+
+    tests := []struct {
+        name    string
+        op      func() (*Template, error)
+        version int
+        err     error
+    }{
+        {"create", func() (*Template, error) { return s.Create("brief", "Be brief.") }, 1, nil},
+        {"create existing", func() (*Template, error) { return s.Create("brief", "Be briefer.") }, 0, ErrExists},
+        {"create invalid name", func() (*Template, error) { return s.Create("Brief!", "Be brief.") }, 0, ErrInvalidName},
+        {"create empty body", func() (*Template, error) { return s.Create("empty", " \n") }, 0, ErrInvalidTemplate},
+        {"create unparsable body", func() (*Template, error) { return s.Create("broken", "{{.Title") }, 0, ErrInvalidTemplate},
+        {"update", func() (*Template, error) { return s.Update("brief", "Be very brief.") }, 2, nil},
+        {"update again", func() (*Template, error) { return s.Update("brief", "Be very, very brief.") }, 3, nil},
+        {"update unknown", func() (*Template, error) { return s.Update("nope", "Hello.") }, 0, ErrNotFound},
+        {"update unparsable body", func() (*Template, error) { return s.Update("brief", "{{end}}") }, 0, ErrInvalidTemplate},
+    }
+    for _, tt := range tests {
+        tmpl, err := tt.op()
+        if !errors.Is(err, tt.err) {
+            t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+            continue
+        }
+        if err == nil && tmpl.Version != tt.version {
+            t.Errorf("%s: version %d, want %d", tt.name, tmpl.Version, tt.version)
+        }
+    }
+
+    // A failed update does not add a version
+    versions, err := s.Versions("brief")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(versions) != 3 || versions[0].Body != "Be brief." || versions[2].Body != "Be very, very brief." {
+        t.Errorf("Versions = %+v, want the three bodies oldest first", versions)
+    }
+    latest, err := s.Get("brief", 0)
+    if err != nil || latest.Version != 3 {
+        t.Errorf("Get latest = %+v, %v, want version 3", latest, err)
+    }
+    first, err := s.Get("brief", 1)
+    if err != nil || first.Body != "Be brief." {
+        t.Errorf("Get version 1 = %+v, %v", first, err)
+    }
+    if _, err := s.Get("brief", 4); err != ErrNotFound {
+        t.Errorf("Get of a missing version: err = %v, want ErrNotFound", err)
+    }
+
+    templates, err := s.List()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(templates) != 2 || templates[0].Name != "brief" || templates[0].Version != 3 || templates[1].Name != DefaultTemplate {
+        t.Errorf("List = %+v, want the latest brief and the default template", templates)
+    }
+}
+
+func TestDelete(t *testing.T) {
+    s := newStore(t)
+    if _, err := s.Create("brief", "Be brief about {{.Title}}."); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Update("brief", "Be very brief."); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name     string
+        template string
+        err      error
+    }{
+        {"default", DefaultTemplate, ErrProtected},
+        {"invalid name", "../brief", ErrInvalidName},
+        {"unknown", "nope", ErrNotFound},
+        {"template", "brief", nil},
+        {"deleted", "brief", ErrNotFound},
+    }
+    for _, tt := range tests {
+        if err := s.Delete(tt.template); err != tt.err {
+            t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+        }
+    }
+    if _, err := s.Get(DefaultTemplate, 0); err != nil {
+        t.Errorf("the default template is gone: %v", err)
+    }
+
+    // Deleted templates are hidden but their versions still render for pinned summaries
+    if _, err := s.Get("brief", 0); err != ErrNotFound {
+        t.Errorf("Get of a deleted template: err = %v, want ErrNotFound", err)
+    }
+    if _, err := s.Versions("brief"); err != ErrNotFound {
+        t.Errorf("Versions of a deleted template: err = %v, want ErrNotFound", err)
+    }
+    if _, err := s.Update("brief", "Be brief."); err != ErrNotFound {
+        t.Errorf("Update of a deleted template: err = %v, want ErrNotFound", err)
+    }
+    templates, err := s.List()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(templates) != 1 || templates[0].Name != DefaultTemplate {
+        t.Errorf("List = %+v, want only the default template", templates)
+    }
+    prompt, tmpl, err := s.Render("brief", 1, types.Meeting{Title: "Planning"})
+    if err != nil || prompt != "Be brief about Planning." || tmpl.Version != 1 {
+        t.Errorf("Render of a pinned version = %q, %+v, %v", prompt, tmpl, err)
+    }
+    if _, _, err := s.Render("brief", 0, types.Meeting{}); err != ErrNotFound {
+        t.Errorf("Render of the latest deleted version: err = %v, want ErrNotFound", err)
+    }
+}
+
+func TestRestoreDeleted(t *testing.T) {
+    s := newStore(t)
+    if _, err := s.Create("brief", "Be brief."); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Update("brief", "Be very brief."); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Delete("brief"); err != nil {
+        t.Fatal(err)
+    }
+
+    // Creating it again restores the name, numbering continues after the old versions
+    tmpl, err := s.Create("brief", "Be short.")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tmpl.Version != 3 {
+        t.Errorf("restored template has version %d, want 3", tmpl.Version)
+    }
+    versions, err := s.Versions("brief")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(versions) != 3 || versions[0].Body != "Be brief." || versions[2].Body != "Be short." {
+        t.Errorf("Versions = %+v, want the old versions and the new one", versions)
+    }
+    if latest, err := s.Get("brief", 0); err != nil || latest.Body != "Be short." {
+        t.Errorf("Get latest = %+v, %v", latest, err)
+    }
+    if _, err := s.Create("brief", "Be short."); err != ErrExists {
+        t.Errorf("creating the restored template again: err = %v, want ErrExists", err)
+    }
+    if err := s.Delete("brief"); err != nil {
+        t.Errorf("deleting the restored template: %v", err)
+    }
+}
+
+func TestRender(t *testing.T) {
+    s := newStore(t)
+    meeting := types.Meeting{Title: "Planning", Attendees: []string{"Ana", "Bob"}}
+
+    tests := []struct {
+        name   string
+        body   string
+        prompt string
+        err    error
+    }{
+        {"fields", "Summarize {{.Title}}.", "Summarize Planning.", nil},
+        {"join", "With {{join .Attendees \", \"}}.", "With Ana, Bob.", nil},
+        {"unknown field", "Summarize {{.Nope}}.", "", ErrInvalidTemplate},
+    }
+    for _, tt := range tests {
+        if _, err := s.Update(DefaultTemplate, tt.body); err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        prompt, _, err := s.Render(DefaultTemplate, 0, meeting)
+        if !errors.Is(err, tt.err) || prompt != tt.prompt {
+            t.Errorf("%s: Render = %q, %v, want %q, %v", tt.name, prompt, err, tt.prompt, tt.err)
+        }
+    }
+}
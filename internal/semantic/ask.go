@@ -0,0 +1,57 @@
+package semantic
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+)
+
+// Chatter generates chat completions, implemented by processing.LLM
+type Chatter interface {
+    Chat(ctx context.Context, messages []processing.ChatMessage, maxTokens int) (string, error)
+}
+
+// Answer to a question over the archive, Sources are the excerpts given to the LLM in citation order
+type Answer struct {
+    Question string
+    Answer   string
+    Sources  []Passage
+}
+
+const askSystemPrompt = "You answer questions about past work meetings using only the numbered transcript excerpts provided. " +
+    "Cite the excerpts every statement is based on in square brackets with the excerpt number and timestamp, " +
+    "for example [2 @ 00:14:05]. If the excerpts do not contain the answer, say that you could not find it in the meetings."
+
+// Ask retrieves the transcript chunks most relevant to the question across the indexed
+// meetings selected by filter and asks the LLM to answer from them
+func Ask(ctx context.Context, idx *Index, llm Chatter, question string, limit int, filter archive.Filter) (*Answer, error) {
+    passages, err := idx.Search(ctx, question, limit, filter)
+    if err != nil {
+        return nil, err
+    }
+    if len(passages) == 0 {
+        return &Answer{Question: question, Answer: "There are no indexed meetings to search yet.", Sources: passages}, nil
+    }
+
+    var excerpts strings.Builder
+    for i, passage := range passages {
+        title := passage.Title
+        if title == "" {
+            title = "untitled"
+        }
+        fmt.Fprintf(&excerpts, "[%d] Meeting %q (task %s) at %s:\n%s\n\n", i+1, title, passage.TaskID, passage.Timestamp, passage.Text)
+    }
+
+    messages := []processing.ChatMessage{
+        {Role: "system", Content: askSystemPrompt},
+        {Role: "user", Content: excerpts.String() + "Question: " + question},
+    }
+    text, err := llm.Chat(ctx, messages, 2048)
+    if err != nil {
+        return nil, err
+    }
+    return &Answer{Question: question, Answer: strings.TrimSpace(text), Sources: passages}, nil
+}
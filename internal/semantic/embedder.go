@@ -0,0 +1,76 @@
+package semantic
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// Embedder turns texts into embedding vectors
+type Embedder interface {
+    Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// HTTPEmbedder calls an OpenAI-compatible /v1/embeddings endpoint
+type HTTPEmbedder struct {
+    baseURL string
+    model   string
+    client  *http.Client
+}
+
+// NewHTTPEmbedder creates an embedder for the API at baseURL (e.g. http://127.0.0.1:8001/v1)
+func NewHTTPEmbedder(baseURL string, model string) *HTTPEmbedder {
+    return &HTTPEmbedder{
+        baseURL: strings.TrimSuffix(baseURL, "/"),
+        model:   model,
+        client:  &http.Client{Timeout: 5 * time.Minute},
+    }
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+    body, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+    if err != nil {
+        return nil, err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL + "/embeddings", bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := e.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+        return nil, fmt.Errorf("embeddings request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+    }
+
+    var parsed struct {
+        Data []struct {
+            Index     int       `json:"index"`
+            Embedding []float64 `json:"embedding"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+        return nil, err
+    }
+    if len(parsed.Data) != len(texts) {
+        return nil, fmt.Errorf("embeddings request returned %d vectors for %d inputs", len(parsed.Data), len(texts))
+    }
+    vectors := make([][]float64, len(texts))
+    for _, d := range parsed.Data {
+        if d.Index < 0 || d.Index >= len(texts) {
+            return nil, fmt.Errorf("embeddings request returned invalid index %d", d.Index)
+        }
+        vectors[d.Index] = d.Embedding
+    }
+    return vectors, nil
+}
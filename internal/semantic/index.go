@@ -0,0 +1,268 @@
+package semantic
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// Transcript chunks are built from consecutive cues up to about this many words
+const chunkWords = 150
+
+// Number of texts sent per embeddings request
+const embedBatchSize = 32
+
+// How long StartIndexing waits before retrying meetings that failed to embed
+const retryInterval = time.Minute
+
+// Chunk is an embedded piece of a meeting transcript
+type Chunk struct {
+    Start  float64
+    End    float64
+    Text   string
+    Vector []float64
+}
+
+// indexFile is the on-disk format of one meeting's chunks (<dir>/<task id>.json)
+type indexFile struct {
+    TaskID string
+    Owner  string // OwnerID of the task
+    Team   string // TeamID of the task
+    Title  string
+    Hash   string // of the transcript, to detect changes (e.g. renamed speakers)
+    Chunks []Chunk
+}
+
+// Passage is a transcript chunk retrieved for a question
+type Passage struct {
+    TaskID    string
+    Title     string
+    Link      string
+    Start     float64
+    End       float64
+    Timestamp string
+    Text      string
+    Score     float64
+}
+
+// Index keeps embeddings of archived meeting transcripts for semantic retrieval
+type Index struct {
+    dir      string
+    meetings *archive.Archive
+    embedder Embedder
+    mu       sync.Mutex // guards files, not held while embedding
+    syncMu   sync.Mutex // one Sync at a time
+    files    map[string]*indexFile
+}
+
+// OpenIndex loads stored embeddings from dir, meetings are indexed from the archive on Sync
+func OpenIndex(dir string, meetings *archive.Archive, embedder Embedder) (*Index, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    idx := &Index{dir: dir, meetings: meetings, embedder: embedder, files: make(map[string]*indexFile)}
+
+    paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    for _, path := range paths {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        var file indexFile
+        if err := json.Unmarshal(data, &file); err != nil {
+            return nil, err
+        }
+        idx.files[file.TaskID] = &file
+    }
+    return idx, nil
+}
+
+// StartIndexing keeps the index in sync with the archive, runs in infinite loop. Meetings are
+// indexed when they are archived or changed, failed ones are retried after retryInterval.
+func (idx *Index) StartIndexing() {
+    for {
+        changed := idx.meetings.Changed()
+        var retry <-chan time.Time
+        if err := idx.Sync(context.Background()); err != nil {
+            slog.Error("Failed to index archived meetings", "err", err)
+            retry = time.After(retryInterval)
+        }
+        select {
+        case <-changed:
+        case <-retry:
+        }
+    }
+}
+
+// Sync embeds archived meetings that are new or changed since they were last indexed
+// and drops meetings no longer in the archive. A meeting that fails to embed does not
+// stop the others, the returned error joins all failures.
+func (idx *Index) Sync(ctx context.Context) error {
+    idx.syncMu.Lock()
+    defer idx.syncMu.Unlock()
+
+    // Find the work under the lock, embed without it so that searches are not blocked
+    var pending []*types.Task
+    archived := make(map[string]bool)
+    idx.mu.Lock()
+    for _, meeting := range idx.meetings.List() {
+        archived[meeting.TaskID] = true
+        task, err := idx.meetings.Get(meeting.TaskID)
+        if err != nil {
+            continue
+        }
+        if file, ok := idx.files[task.ID]; ok && file.Hash == transcriptHash(task.Result.Transcript) && file.Title == task.Meeting.Title && file.Owner == task.OwnerID && file.Team == task.TeamID {
+            continue
+        }
+        pending = append(pending, task)
+    }
+    for taskID := range idx.files {
+        if !archived[taskID] {
+            delete(idx.files, taskID)
+            os.Remove(filepath.Join(idx.dir, taskID + ".json"))
+        }
+    }
+    idx.mu.Unlock()
+
+    var errs []error
+    for _, task := range pending {
+        if err := idx.add(ctx, task); err != nil {
+            errs = append(errs, fmt.Errorf("meeting %s: %w", task.ID, err))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// Search returns the limit transcript chunks of meetings selected by filter most similar to the question
+func (idx *Index) Search(ctx context.Context, question string, limit int, filter archive.Filter) ([]Passage, error) {
+    vectors, err := idx.embedder.Embed(ctx, []string{question})
+    if err != nil {
+        return nil, err
+    }
+
+    idx.mu.Lock()
+    defer idx.mu.Unlock()
+
+    passages := []Passage{}
+    for _, file := range idx.files {
+        if !filter(archive.Meeting{TaskID: file.TaskID, OwnerID: file.Owner, TeamID: file.Team, Title: file.Title}) {
+            continue
+        }
+        for _, chunk := range file.Chunks {
+            passages = append(passages, Passage{
+                TaskID:    file.TaskID,
+                Title:     file.Title,
+                Link:      "/archive/" + file.TaskID,
+                Start:     chunk.Start,
+                End:       chunk.End,
+                Timestamp: processing.FormatTimestamp(chunk.Start),
+                Text:      chunk.Text,
+                Score:     speakers.CosineSimilarity(vectors[0], chunk.Vector),
+            })
+        }
+    }
+    sort.SliceStable(passages, func(i, j int) bool { return passages[i].Score > passages[j].Score })
+    if len(passages) > limit {
+        passages = passages[:limit]
+    }
+    return passages, nil
+}
+
+// add chunks and embeds a meeting transcript and stores it, caller must hold idx.syncMu
+func (idx *Index) add(ctx context.Context, task *types.Task) error {
+    chunks := ChunkTranscript(task.Result.Transcript)
+    for start := 0; start < len(chunks); start += embedBatchSize {
+        end := start + embedBatchSize
+        if end > len(chunks) {
+            end = len(chunks)
+        }
+        texts := make([]string, 0, end-start)
+        for _, chunk := range chunks[start:end] {
+            texts = append(texts, chunk.Text)
+        }
+        vectors, err := idx.embedder.Embed(ctx, texts)
+        if err != nil {
+            return err
+        }
+        for i, vector := range vectors {
+            chunks[start+i].Vector = vector
+        }
+    }
+
+    file := &indexFile{TaskID: task.ID, Owner: task.OwnerID, Team: task.TeamID, Title: task.Meeting.Title,
+        Hash: transcriptHash(task.Result.Transcript), Chunks: chunks}
+    data, err := json.Marshal(file)
+    if err != nil {
+        return err
+    }
+    path := filepath.Join(idx.dir, task.ID + ".json")
+    if err := os.WriteFile(path + ".tmp", data, 0644); err != nil {
+        return err
+    }
+
+    idx.mu.Lock()
+    defer idx.mu.Unlock()
+    if _, err := idx.meetings.Get(task.ID); err != nil {
+        os.Remove(path + ".tmp")
+        return nil // deleted from the archive while embedding
+    }
+    if err := os.Rename(path + ".tmp", path); err != nil {
+        return err
+    }
+    idx.files[task.ID] = file
+    return nil
+}
+
+// ChunkTranscript groups consecutive cues into chunks of about chunkWords words,
+// each line prefixed with its speaker
+func ChunkTranscript(transcript string) []Chunk {
+    var chunks []Chunk
+    var lines []string
+    var current Chunk
+    words := 0
+    for _, cue := range processing.ParseCues(transcript) {
+        if len(lines) == 0 {
+            current.Start = cue.Start
+        }
+        line := cue.Text
+        if cue.Speaker != "" {
+            line = cue.Speaker + ": " + cue.Text
+        }
+        lines = append(lines, line)
+        current.End = cue.End
+        words += len(strings.Fields(cue.Text))
+        if words >= chunkWords {
+            current.Text = strings.Join(lines, "\n")
+            chunks = append(chunks, current)
+            current, lines, words = Chunk{}, nil, 0
+        }
+    }
+    if len(lines) > 0 {
+        current.Text = strings.Join(lines, "\n")
+        chunks = append(chunks, current)
+    }
+    return chunks
+}
+
+func transcriptHash(transcript string) string {
+    sum := sha256.Sum256([]byte(transcript))
+    return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,233 @@
+package semantic
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// Words the stub embeddings server counts, one dimension each
+var vocabulary = []string{"rollout", "budget", "hiring"}
+
+// stubEmbeddings is an OpenAI-compatible embeddings server embedding texts as word counts
+// over vocabulary. Texts containing "unembeddable" fail the request.
+type stubEmbeddings struct {
+    mu     sync.Mutex
+    inputs []string
+}
+
+func (s *stubEmbeddings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Input []string `json:"input"`
+    }
+    if r.URL.Path != "/v1/embeddings" || json.NewDecoder(r.Body).Decode(&req) != nil {
+        http.Error(w, "bad request", http.StatusBadRequest)
+        return
+    }
+    s.mu.Lock()
+    s.inputs = append(s.inputs, req.Input...)
+    s.mu.Unlock()
+
+    type embedding struct {
+        Index     int       `json:"index"`
+        Embedding []float64 `json:"embedding"`
+    }
+    var data []embedding
+    for i, text := range req.Input {
+        if strings.Contains(text, "unembeddable") {
+            http.Error(w, "cannot embed", http.StatusInternalServerError)
+            return
+        }
+        vector := make([]float64, len(vocabulary))
+        for _, word := range strings.Fields(strings.ToLower(text)) {
+            for d, v := range vocabulary {
+                if strings.Trim(word, ".,?!:") == v {
+                    vector[d]++
+                }
+            }
+        }
+        // Texts without any known word still need a non-zero vector
+        vector = append(vector, 0.1)
+        data = append(data, embedding{Index: i, Embedding: vector})
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (s *stubEmbeddings) embedded() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return len(s.inputs)
+}
+
+// stubChatter answers with a fixed text and keeps the messages it was sent
+type stubChatter struct {
+    messages []processing.ChatMessage
+}
+
+func (c *stubChatter) Chat(ctx context.Context, messages []processing.ChatMessage, maxTokens int) (string, error) {
+    c.messages = messages
+    return " The rollout is in March [1 @ 00:00:00]. ", nil
+}
+
+func saveMeeting(t *testing.T, meetings *archive.Archive, id string, owner string, title string, text string) {
+    t.Helper()
+    task := types.Task{ID: id, OwnerID: owner, Status: "completed", Meeting: types.Meeting{Title: title},
+        Result: types.Result{Transcript: "WEBVTT\n\n00:00.000 --> 00:05.000\n[Ana]: " + text + "\n\n"}}
+    if err := meetings.Save(task); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func newIndex(t *testing.T) (*Index, *archive.Archive, *stubEmbeddings) {
+    t.Helper()
+    stub := &stubEmbeddings{}
+    server := httptest.NewServer(stub)
+    t.Cleanup(server.Close)
+
+    meetings, err := archive.Open(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    idx, err := OpenIndex(t.TempDir(), meetings, NewHTTPEmbedder(server.URL + "/v1", "stub"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return idx, meetings, stub
+}
+
+func all(archive.Meeting) bool { return true }
+
+func TestSyncAndSearch(t *testing.T) {
+    idx, meetings, stub := newIndex(t)
+    ctx := context.Background()
+    saveMeeting(t, meetings, "planning", "ana", "Planning", "The rollout moves to March, rollout first.")
+    saveMeeting(t, meetings, "finance", "bob", "Finance", "The budget for hiring is approved.")
+
+    if err := idx.Sync(ctx); err != nil {
+        t.Fatal(err)
+    }
+    passages, err := idx.Search(ctx, "When is the rollout?", 1, all)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(passages) != 1 || passages[0].TaskID != "planning" || passages[0].Link != "/archive/planning" {
+        t.Fatalf("Search = %+v, want the planning meeting", passages)
+    }
+    if !strings.HasPrefix(passages[0].Text, "Ana: ") {
+        t.Errorf("passage text %q should start with the speaker", passages[0].Text)
+    }
+
+    passages, err = idx.Search(ctx, "budget", 5, func(m archive.Meeting) bool { return m.OwnerID == "ana" })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(passages) != 1 || passages[0].TaskID != "planning" {
+        t.Errorf("filtered Search = %+v, want only Ana's meeting", passages)
+    }
+
+    // Unchanged meetings are not embedded again
+    before := stub.embedded()
+    if err := idx.Sync(ctx); err != nil {
+        t.Fatal(err)
+    }
+    if stub.embedded() != before {
+        t.Errorf("Sync embedded %d texts again", stub.embedded() - before)
+    }
+
+    // Changed and deleted meetings are picked up
+    saveMeeting(t, meetings, "planning", "ana", "Planning", "Hiring starts next week.")
+    if err := meetings.Delete("finance"); err != nil {
+        t.Fatal(err)
+    }
+    if err := idx.Sync(ctx); err != nil {
+        t.Fatal(err)
+    }
+    passages, err = idx.Search(ctx, "hiring", 5, all)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(passages) != 1 || passages[0].TaskID != "planning" || !strings.Contains(passages[0].Text, "Hiring") {
+        t.Errorf("Search after changes = %+v, want the updated planning meeting only", passages)
+    }
+}
+
+func TestSyncFailureKeepsOtherMeetings(t *testing.T) {
+    idx, meetings, _ := newIndex(t)
+    ctx := context.Background()
+    saveMeeting(t, meetings, "broken", "ana", "Broken", "This one is unembeddable.")
+    saveMeeting(t, meetings, "planning", "ana", "Planning", "The rollout moves to March.")
+
+    err := idx.Sync(ctx)
+    if err == nil || !strings.Contains(err.Error(), "broken") {
+        t.Errorf("Sync error = %v, want the broken meeting's failure", err)
+    }
+    passages, err := idx.Search(ctx, "rollout", 5, all)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(passages) != 1 || passages[0].TaskID != "planning" {
+        t.Errorf("Search = %+v, want the planning meeting indexed despite the failure", passages)
+    }
+}
+
+func TestReopenIndex(t *testing.T) {
+    idx, meetings, stub := newIndex(t)
+    ctx := context.Background()
+    saveMeeting(t, meetings, "planning", "ana", "Planning", "The rollout moves to March.")
+    if err := idx.Sync(ctx); err != nil {
+        t.Fatal(err)
+    }
+
+    reopened, err := OpenIndex(idx.dir, meetings, idx.embedder)
+    if err != nil {
+        t.Fatal(err)
+    }
+    before := stub.embedded()
+    if err := reopened.Sync(ctx); err != nil {
+        t.Fatal(err)
+    }
+    if stub.embedded() != before {
+        t.Error("stored embeddings were computed again after reopening")
+    }
+}
+
+func TestAsk(t *testing.T) {
+    idx, meetings, _ := newIndex(t)
+    ctx := context.Background()
+    llm := &stubChatter{}
+
+    answer, err := Ask(ctx, idx, llm, "When is the rollout?", 5, all)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(answer.Sources) != 0 || llm.messages != nil {
+        t.Errorf("Ask without indexed meetings = %+v, should not call the LLM", answer)
+    }
+
+    saveMeeting(t, meetings, "planning", "ana", "Planning", "The rollout moves to March.")
+    if err := idx.Sync(ctx); err != nil {
+        t.Fatal(err)
+    }
+    answer, err = Ask(ctx, idx, llm, "When is the rollout?", 5, all)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if answer.Answer != "The rollout is in March [1 @ 00:00:00]." || len(answer.Sources) != 1 {
+        t.Errorf("Ask = %+v", answer)
+    }
+    if len(llm.messages) != 2 || llm.messages[0].Role != "system" {
+        t.Fatalf("LLM messages = %+v, want a system and a user message", llm.messages)
+    }
+    prompt := llm.messages[1].Content
+    if !strings.Contains(prompt, `[1] Meeting "Planning" (task planning) at 00:00:00:`) || !strings.HasSuffix(prompt, "Question: When is the rollout?") {
+        t.Errorf("user message = %q", prompt)
+    }
+}
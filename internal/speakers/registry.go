@@ -0,0 +1,258 @@
+package speakers
+
+import (
+    "encoding/json"
+    "errors"
+    "math"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Similarity above which a diarized speaker is labeled with an enrolled name
+const DefaultThreshold = 0.7
+
+var (
+    ErrNotFound         = errors.New("speaker not found")
+    ErrInvalidName      = errors.New("invalid speaker name")
+    ErrInvalidEmbedding = errors.New("invalid embedding")
+)
+
+// Speaker is an enrolled voice, every enrollment adds one embedding (voice print)
+type Speaker struct {
+    Name       string
+    Embeddings [][]float64
+    UpdatedAt  time.Time
+}
+
+// Registry keeps enrolled speakers in a local JSON file
+type Registry struct {
+    path      string
+    threshold float64
+    mu        sync.Mutex
+    speakers  map[string]*Speaker
+}
+
+// NewRegistry loads the registry from path (created on first enrollment)
+func NewRegistry(path string, threshold float64) (*Registry, error) {
+    r := &Registry{path: path, threshold: threshold, speakers: make(map[string]*Speaker)}
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return r, nil
+        }
+        return nil, err
+    }
+    var speakers []*Speaker
+    if err := json.Unmarshal(data, &speakers); err != nil {
+        return nil, err
+    }
+    for _, speaker := range speakers {
+        r.speakers[speaker.Name] = speaker
+    }
+    return r, nil
+}
+
+// List returns all enrolled speakers sorted by name
+func (r *Registry) List() []Speaker {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    speakers := make([]Speaker, 0, len(r.speakers))
+    for _, speaker := range r.speakers {
+        speakers = append(speakers, *speaker)
+    }
+    sort.Slice(speakers, func(i, j int) bool { return speakers[i].Name < speakers[j].Name })
+    return speakers
+}
+
+// ValidName reports whether name can be used as a speaker label in a transcript,
+// where lines look like "[LABEL]: text"
+func ValidName(name string) bool {
+    return strings.TrimSpace(name) != "" && !strings.ContainsAny(name, "[]\r\n") && !strings.Contains(name, ": ")
+}
+
+// Enroll adds a voice print for name, creating the speaker if needed
+func (r *Registry) Enroll(name string, embedding []float64) (*Speaker, error) {
+    name = strings.TrimSpace(name)
+    if !ValidName(name) {
+        return nil, ErrInvalidName
+    }
+    if len(embedding) == 0 || norm(embedding) == 0 {
+        return nil, ErrInvalidEmbedding
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    previous, ok := r.speakers[name]
+    speaker := &Speaker{Name: name, UpdatedAt: time.Now()}
+    if ok {
+        if len(previous.Embeddings) > 0 && len(previous.Embeddings[0]) != len(embedding) {
+            return nil, ErrInvalidEmbedding
+        }
+        speaker.Embeddings = append(speaker.Embeddings, previous.Embeddings...)
+    }
+    speaker.Embeddings = append(speaker.Embeddings, embedding)
+
+    r.speakers[name] = speaker
+    if err := r.save(); err != nil {
+        r.restore(name, previous)
+        return nil, err
+    }
+    enrolled := *speaker
+    return &enrolled, nil
+}
+
+// Delete removes an enrolled speaker with all its voice prints
+func (r *Registry) Delete(name string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    previous, ok := r.speakers[name]
+    if !ok {
+        return ErrNotFound
+    }
+    delete(r.speakers, name)
+    if err := r.save(); err != nil {
+        r.restore(name, previous)
+        return err
+    }
+    return nil
+}
+
+// restore puts back the speaker saved before a failed change, nil if there was none.
+// Caller must hold r.mu.
+func (r *Registry) restore(name string, speaker *Speaker) {
+    if speaker == nil {
+        delete(r.speakers, name)
+    } else {
+        r.speakers[name] = speaker
+    }
+}
+
+// Identify maps diarized speaker labels to enrolled names using the registry threshold
+func (r *Registry) Identify(embeddings map[string][]float64) map[string]string {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    enrolled := make(map[string][]float64, len(r.speakers))
+    for name, speaker := range r.speakers {
+        if centroid := Centroid(speaker.Embeddings); centroid != nil {
+            enrolled[name] = centroid
+        }
+    }
+    return Match(embeddings, enrolled, r.threshold)
+}
+
+// save writes the registry file, caller must hold r.mu
+func (r *Registry) save() error {
+    speakers := make([]*Speaker, 0, len(r.speakers))
+    for _, speaker := range r.speakers {
+        speakers = append(speakers, speaker)
+    }
+    sort.Slice(speakers, func(i, j int) bool { return speakers[i].Name < speakers[j].Name })
+
+    data, err := json.Marshal(speakers)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+        return err
+    }
+    // Write to a temp file first so a crash never leaves a truncated registry
+    tmpPath := r.path + ".tmp"
+    if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, r.path)
+}
+
+// Match assigns enrolled names (name -> voice print) to diarized labels (label -> embedding).
+// Pairs are assigned greedily from the most similar down, each name and label at most once,
+// and only when their cosine similarity exceeds threshold.
+func Match(labels map[string][]float64, enrolled map[string][]float64, threshold float64) map[string]string {
+    type candidate struct {
+        label      string
+        name       string
+        similarity float64
+    }
+    var candidates []candidate
+    for label, embedding := range labels {
+        for name, voicePrint := range enrolled {
+            similarity := CosineSimilarity(embedding, voicePrint)
+            if similarity > threshold {
+                candidates = append(candidates, candidate{label, name, similarity})
+            }
+        }
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        if candidates[i].similarity != candidates[j].similarity {
+            return candidates[i].similarity > candidates[j].similarity
+        }
+        // Deterministic order for ties
+        if candidates[i].label != candidates[j].label {
+            return candidates[i].label < candidates[j].label
+        }
+        return candidates[i].name < candidates[j].name
+    })
+
+    matches := make(map[string]string)
+    usedNames := make(map[string]bool)
+    for _, c := range candidates {
+        if _, ok := matches[c.label]; ok || usedNames[c.name] {
+            continue
+        }
+        matches[c.label] = c.name
+        usedNames[c.name] = true
+    }
+    return matches
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, 0 if they cannot be compared
+func CosineSimilarity(a []float64, b []float64) float64 {
+    if len(a) != len(b) || len(a) == 0 {
+        return 0
+    }
+    var dot float64
+    for i := range a {
+        dot += a[i] * b[i]
+    }
+    normA, normB := norm(a), norm(b)
+    if normA == 0 || normB == 0 {
+        return 0
+    }
+    return dot / (normA * normB)
+}
+
+// Centroid returns the mean of the L2-normalized embeddings, nil if there are none
+func Centroid(embeddings [][]float64) []float64 {
+    if len(embeddings) == 0 {
+        return nil
+    }
+    centroid := make([]float64, len(embeddings[0]))
+    for _, embedding := range embeddings {
+        n := norm(embedding)
+        if len(embedding) != len(centroid) || n == 0 {
+            continue
+        }
+        for i, v := range embedding {
+            centroid[i] += v / n
+        }
+    }
+    for i := range centroid {
+        centroid[i] /= float64(len(embeddings))
+    }
+    return centroid
+}
+
+func norm(v []float64) float64 {
+    var sum float64
+    for _, x := range v {
+        sum += x * x
+    }
+    return math.Sqrt(sum)
+}
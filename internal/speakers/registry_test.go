@@ -0,0 +1,146 @@
+package speakers
+
+import (
+    "errors"
+    "math"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// at returns a unit vector whose cosine similarity with (1, 0) is similarity
+func at(similarity float64) []float64 {
+    return []float64{similarity, math.Sqrt(1 - similarity*similarity)}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+    tests := []struct {
+        name string
+        a, b []float64
+        want float64
+    }{
+        {"same direction", []float64{1, 2, 3}, []float64{2, 4, 6}, 1},
+        {"opposite", []float64{1, 0}, []float64{-3, 0}, -1},
+        {"orthogonal", []float64{1, 0}, []float64{0, 5}, 0},
+        {"dimension mismatch", []float64{1, 0}, []float64{1, 0, 0}, 0},
+        {"empty", nil, nil, 0},
+        {"zero vector", []float64{0, 0}, []float64{1, 0}, 0},
+    }
+    for _, tt := range tests {
+        if got := CosineSimilarity(tt.a, tt.b); math.Abs(got - tt.want) > 1e-9 {
+            t.Errorf("%s: CosineSimilarity = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCentroid(t *testing.T) {
+    if Centroid(nil) != nil {
+        t.Error("Centroid(nil) should be nil")
+    }
+    // Embeddings are normalized first, so their length does not weigh in
+    got := Centroid([][]float64{{10, 0}, {0, 1}})
+    if want := []float64{0.5, 0.5}; !reflect.DeepEqual(got, want) {
+        t.Errorf("Centroid = %v, want %v", got, want)
+    }
+}
+
+func TestMatchThreshold(t *testing.T) {
+    enrolled := map[string][]float64{"Ana": {1, 0}}
+    tests := []struct {
+        similarity float64
+        matched    bool
+    }{
+        {0.95, true},
+        {0.71, true},
+        {0.69, false},
+        {0, false},
+    }
+    for _, tt := range tests {
+        matches := Match(map[string][]float64{"SPEAKER_00": at(tt.similarity)}, enrolled, DefaultThreshold)
+        if got := matches["SPEAKER_00"] == "Ana"; got != tt.matched {
+            t.Errorf("similarity %v: matched = %v, want %v", tt.similarity, got, tt.matched)
+        }
+    }
+}
+
+func TestMatchGreedyOneToOne(t *testing.T) {
+    enrolled := map[string][]float64{"Ana": {1, 0}, "Bob": at(0.8)}
+    labels := map[string][]float64{
+        "SPEAKER_00": at(0.9),  // 0.98 to Bob
+        "SPEAKER_01": at(0.99), // the best pair overall, takes Ana
+        // Above the threshold for both, but both are taken by closer labels
+        "SPEAKER_02": at(0.95),
+    }
+    got := Match(labels, enrolled, DefaultThreshold)
+    want := map[string]string{"SPEAKER_01": "Ana", "SPEAKER_00": "Bob"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("Match = %v, want %v", got, want)
+    }
+}
+
+func TestMatchDimensionMismatch(t *testing.T) {
+    got := Match(map[string][]float64{"SPEAKER_00": {1, 0, 0}}, map[string][]float64{"Ana": {1, 0}}, DefaultThreshold)
+    if len(got) != 0 {
+        t.Errorf("Match = %v, want no matches", got)
+    }
+}
+
+func TestEnrollAndIdentify(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "speakers.json")
+    r, err := NewRegistry(path, DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := r.Enroll("Ana", []float64{1, 0}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := r.Enroll("Ana", []float64{1, 0, 0}); !errors.Is(err, ErrInvalidEmbedding) {
+        t.Errorf("enrolling another dimension: err = %v, want ErrInvalidEmbedding", err)
+    }
+    if _, err := r.Enroll("Bob", []float64{0, 0}); !errors.Is(err, ErrInvalidEmbedding) {
+        t.Errorf("enrolling a zero vector: err = %v, want ErrInvalidEmbedding", err)
+    }
+
+    // Reloaded from disk
+    r, err = NewRegistry(path, DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if speakers := r.List(); len(speakers) != 1 || len(speakers[0].Embeddings) != 1 {
+        t.Fatalf("List = %+v, want Ana with one voice print", speakers)
+    }
+    if got := r.Identify(map[string][]float64{"SPEAKER_00": at(0.9)}); got["SPEAKER_00"] != "Ana" {
+        t.Errorf("Identify = %v, want SPEAKER_00 -> Ana", got)
+    }
+}
+
+func TestEnrollInvalidName(t *testing.T) {
+    r, err := NewRegistry(filepath.Join(t.TempDir(), "speakers.json"), DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, name := range []string{"", "  ", "[Ana]", "Ana\nBob", "Ana: Bob"} {
+        if _, err := r.Enroll(name, []float64{1, 0}); !errors.Is(err, ErrInvalidName) {
+            t.Errorf("Enroll(%q): err = %v, want ErrInvalidName", name, err)
+        }
+    }
+}
+
+func TestEnrollFailedSaveKeepsRegistry(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "registry")
+    r, err := NewRegistry(filepath.Join(dir, "speakers.json"), DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The registry's directory is a file, so saving fails
+    if err := os.WriteFile(dir, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := r.Enroll("Ana", []float64{1, 0}); err == nil {
+        t.Fatal("Enroll should fail")
+    }
+    if speakers := r.List(); len(speakers) != 0 {
+        t.Errorf("List = %+v after a failed save, want none", speakers)
+    }
+}
@@ -0,0 +1,165 @@
+// Package tasklog keeps a log file per task, with the lines logged while processing it and
+// the output of the commands it ran
+package tasklog
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+var ErrNotFound = errors.New("task log not found")
+
+// Store keeps the task logs as <task ID>.log files in a directory
+type Store struct {
+    dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(taskID string) string {
+    return filepath.Join(s.dir, taskID + ".log")
+}
+
+// Log is the log of one task, lines logged with its Logger carry the task ID and go both to
+// the default logger and the task's file
+type Log struct {
+    *slog.Logger
+    file *os.File // nil without a store or if the file could not be opened
+}
+
+// Open opens the log of a task for appending, a nil Store only logs to the default logger.
+// The task's file gets debug lines too, whatever the level of the default logger.
+func (s *Store) Open(taskID string) *Log {
+    l := &Log{}
+    handler := slog.Default().Handler()
+    if s != nil {
+        file, err := os.OpenFile(s.path(taskID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+        if err != nil {
+            slog.Error("Failed to open task log", "task_id", taskID, "err", err)
+        } else {
+            l.file = file
+            handler = teeHandler{handler, slog.NewTextHandler(file, &slog.HandlerOptions{Level: slog.LevelDebug})}
+        }
+    }
+    l.Logger = slog.New(handler).With("task_id", taskID)
+    return l
+}
+
+// Close closes the task's file
+func (l *Log) Close() error {
+    if l.file == nil {
+        return nil
+    }
+    return l.file.Close()
+}
+
+// Output returns a writer for the output of a command, every line goes to the task's file
+// prefixed with name. Close it once the command has exited to write an unterminated last line.
+func (l *Log) Output(name string) io.WriteCloser {
+    return &outputWriter{file: l.file, prefix: []byte(name + ": ")}
+}
+
+// Read returns the log of a task
+func (s *Store) Read(taskID string) ([]byte, error) {
+    if s == nil {
+        return nil, ErrNotFound
+    }
+    data, err := os.ReadFile(s.path(taskID))
+    if os.IsNotExist(err) {
+        return nil, ErrNotFound
+    }
+    return data, err
+}
+
+// Delete removes the log of a task
+func (s *Store) Delete(taskID string) error {
+    if s == nil {
+        return nil
+    }
+    if err := os.Remove(s.path(taskID)); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    return nil
+}
+
+// outputWriter writes whole prefixed lines, so that they do not interleave with log lines
+type outputWriter struct {
+    mu     sync.Mutex // stdout and stderr of a command are copied concurrently
+    file   *os.File
+    prefix []byte
+    buf    []byte // unterminated line
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.file == nil {
+        return len(p), nil
+    }
+    w.buf = append(w.buf, p...)
+    for {
+        // Progress bars redraw their line with \r, each redraw counts as a line
+        i := bytes.IndexAny(w.buf, "\r\n")
+        if i < 0 {
+            break
+        }
+        if i > 0 {
+            w.writeLine(w.buf[:i])
+        }
+        w.buf = w.buf[i+1:]
+    }
+    return len(p), nil
+}
+
+func (w *outputWriter) Close() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.file != nil && len(w.buf) > 0 {
+        w.writeLine(w.buf)
+    }
+    w.buf = nil
+    return nil
+}
+
+// writeLine writes one line in a single write, caller must hold w.mu
+func (w *outputWriter) writeLine(line []byte) {
+    out := make([]byte, 0, len(w.prefix) + len(line) + 1)
+    out = append(append(append(out, w.prefix...), line...), '\n')
+    w.file.Write(out)
+}
+
+// teeHandler sends records to both handlers
+type teeHandler [2]slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+    return t[0].Enabled(ctx, level) || t[1].Enabled(ctx, level)
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+    var errs []error
+    for _, h := range t {
+        if h.Enabled(ctx, r.Level) {
+            errs = append(errs, h.Handle(ctx, r.Clone()))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return teeHandler{t[0].WithAttrs(attrs), t[1].WithAttrs(attrs)}
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+    return teeHandler{t[0].WithGroup(name), t[1].WithGroup(name)}
+}
@@ -0,0 +1,190 @@
+package tasklog
+
+import (
+    "bytes"
+    "fmt"
+    "log/slog"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// captureDefault sends the default logger's lines at level and above to the returned buffer
+// until the test ends
+func captureDefault(t *testing.T, level slog.Level) *bytes.Buffer {
+    var buf bytes.Buffer
+    previous := slog.Default()
+    slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: level})))
+    t.Cleanup(func() { slog.SetDefault(previous) })
+    return &buf
+}
+
+func newStore(t *testing.T) *Store {
+    t.Helper()
+    s, err := NewStore(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return s
+}
+
+func TestOutput(t *testing.T) {
+    tests := []struct {
+        name   string
+        writes []string
+        want   string
+    }{
+        {"lines", []string{"one\ntwo\n"}, "whisperx: one\nwhisperx: two\n"},
+        {"partial lines", []string{"wh", "ole line\nsecond ", "half\n"}, "whisperx: whole line\nwhisperx: second half\n"},
+        {"unterminated last line", []string{"done\nexit ", "code 0"}, "whisperx: done\nwhisperx: exit code 0\n"},
+        {"progress bar", []string{" 10%\r 50%", "\r100%\n"}, "whisperx:  10%\nwhisperx:  50%\nwhisperx: 100%\n"},
+        {"blank lines", []string{"\n\nline\r\n\n"}, "whisperx: line\n"},
+        {"nothing", nil, ""},
+    }
+    captureDefault(t, slog.LevelInfo)
+    for _, tt := range tests {
+        s := newStore(t)
+        l := s.Open("t1")
+        output := l.Output("whisperx")
+        for _, write := range tt.writes {
+            if n, err := output.Write([]byte(write)); n != len(write) || err != nil {
+                t.Errorf("%s: Write = %d, %v", tt.name, n, err)
+            }
+        }
+        if err := output.Close(); err != nil {
+            t.Errorf("%s: Close: %v", tt.name, err)
+        }
+        l.Close()
+        data, err := s.Read("t1")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if string(data) != tt.want {
+            t.Errorf("%s: log is %q, want %q", tt.name, data, tt.want)
+        }
+    }
+}
+
+func TestOutputConcurrent(t *testing.T) {
+    captureDefault(t, slog.LevelInfo)
+    s := newStore(t)
+    l := s.Open("t1")
+    output := l.Output("ffmpeg")
+
+    // stdout and stderr are copied by their own goroutines, lines stay whole
+    var wg sync.WaitGroup
+    for _, stream := range []string{"stdout", "stderr"} {
+        wg.Add(1)
+        go func(stream string) {
+            defer wg.Done()
+            for i := 0; i < 100; i++ {
+                output.Write([]byte(stream + " line " + fmt.Sprint(i) + "\n"))
+            }
+        }(stream)
+    }
+    wg.Wait()
+    output.Close()
+    l.Close()
+
+    data, err := s.Read("t1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+    if len(lines) != 200 {
+        t.Fatalf("got %d lines, want 200", len(lines))
+    }
+    for _, line := range lines {
+        if !strings.HasPrefix(line, "ffmpeg: stdout line ") && !strings.HasPrefix(line, "ffmpeg: stderr line ") {
+            t.Errorf("mangled line %q", line)
+        }
+    }
+}
+
+func TestTee(t *testing.T) {
+    global := captureDefault(t, slog.LevelInfo)
+    s := newStore(t)
+    l := s.Open("t1")
+    l.Debug("Reading header")
+    l.Info("Processing task", "template", "default")
+    l.With("stage", "summarize").WithGroup("llm").Warn("Slow response", "seconds", 30)
+    l.Close()
+
+    data, err := s.Read("t1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    file := string(data)
+    tests := []struct {
+        line     string
+        inFile   bool
+        inGlobal bool
+    }{
+        // Debug lines only go to the task's file
+        {`level=DEBUG msg="Reading header" task_id=t1`, true, false},
+        {`level=INFO msg="Processing task" task_id=t1 template=default`, true, true},
+        {`level=WARN msg="Slow response" task_id=t1 stage=summarize llm.seconds=30`, true, true},
+    }
+    for _, tt := range tests {
+        if strings.Contains(file, tt.line) != tt.inFile {
+            t.Errorf("%q in the task's file: %v, want %v\n%s", tt.line, !tt.inFile, tt.inFile, file)
+        }
+        if strings.Contains(global.String(), tt.line) != tt.inGlobal {
+            t.Errorf("%q in the default log: %v, want %v\n%s", tt.line, !tt.inGlobal, tt.inGlobal, global)
+        }
+    }
+
+    // The default logger's level still applies to its own lines
+    debug := captureDefault(t, slog.LevelDebug)
+    l = s.Open("t2")
+    l.Debug("Reading header")
+    l.Close()
+    if !strings.Contains(debug.String(), `msg="Reading header" task_id=t2`) {
+        t.Errorf("debug line missing from a debug default log: %q", debug)
+    }
+}
+
+func TestNilStore(t *testing.T) {
+    global := captureDefault(t, slog.LevelInfo)
+    var s *Store
+    l := s.Open("t1")
+    l.Info("Processing task")
+    if !strings.Contains(global.String(), `msg="Processing task" task_id=t1`) {
+        t.Errorf("line missing from the default log: %q", global)
+    }
+
+    output := l.Output("whisperx")
+    if n, err := output.Write([]byte("partial")); n != 7 || err != nil {
+        t.Errorf("Write = %d, %v", n, err)
+    }
+    if err := output.Close(); err != nil {
+        t.Errorf("output Close: %v", err)
+    }
+    if strings.Contains(global.String(), "partial") {
+        t.Error("command output went to the default log")
+    }
+    if err := l.Close(); err != nil {
+        t.Errorf("Close: %v", err)
+    }
+    if _, err := s.Read("t1"); err != ErrNotFound {
+        t.Errorf("Read: err = %v, want ErrNotFound", err)
+    }
+    if err := s.Delete("t1"); err != nil {
+        t.Errorf("Delete: %v", err)
+    }
+}
+
+func TestDelete(t *testing.T) {
+    s := newStore(t)
+    l := s.Open("t1")
+    l.Close()
+    if err := s.Delete("t1"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Read("t1"); err != ErrNotFound {
+        t.Errorf("Read of a deleted log: err = %v, want ErrNotFound", err)
+    }
+    if err := s.Delete("t1"); err != nil {
+        t.Errorf("deleting a missing log: %v", err)
+    }
+}
@@ -0,0 +1,284 @@
+package teams
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Role of a team member, each role includes the permissions of the ones before it
+type Role string
+
+const (
+    Viewer Role = "viewer" // reads shared meetings
+    Editor Role = "editor" // also shares meetings with the team, renames speakers and re-summarizes
+    Admin  Role = "admin"  // also deletes meetings and manages members
+)
+
+// Action on a meeting that needs permission
+type Action int
+
+const (
+    Read Action = iota
+    Edit
+    Delete
+)
+
+var (
+    ErrNotFound    = errors.New("team not found")
+    ErrInvalidName = errors.New("invalid team name")
+    ErrInvalidRole = errors.New("invalid role")
+    ErrLastAdmin   = errors.New("team needs at least one admin")
+    ErrNotMember   = errors.New("user is not a member of the team")
+)
+
+// Team is a group of users sharing a library of meetings
+type Team struct {
+    ID        string
+    Name      string
+    Members   map[string]Role // user ID -> role
+    CreatedAt time.Time
+}
+
+// Teams keeps teams in a local JSON file
+type Teams struct {
+    path  string
+    mu    sync.Mutex
+    teams map[string]*Team
+}
+
+// NewTeams loads teams from path (created with the first team)
+func NewTeams(path string) (*Teams, error) {
+    t := &Teams{path: path, teams: make(map[string]*Team)}
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return t, nil
+        }
+        return nil, err
+    }
+    var teams []*Team
+    if err := json.Unmarshal(data, &teams); err != nil {
+        return nil, err
+    }
+    for _, team := range teams {
+        t.teams[team.ID] = team
+    }
+    return t, nil
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role Role) bool {
+    return role == Viewer || role == Editor || role == Admin
+}
+
+// Allows reports whether a role permits an action
+func (r Role) Allows(action Action) bool {
+    switch action {
+    case Read:
+        return r == Viewer || r == Editor || r == Admin
+    case Edit:
+        return r == Editor || r == Admin
+    case Delete:
+        return r == Admin
+    }
+    return false
+}
+
+// Allowed reports whether a user (admin if a site admin) may perform an action on a meeting
+// owned by ownerID and shared with teamID (empty if not shared). Owners may do anything, team
+// members are limited by their role. Meetings from before accounts (no owner) can be read by
+// every user, only admins may change or delete them.
+func (t *Teams) Allowed(ownerID string, teamID string, userID string, admin bool, action Action) bool {
+    if userID == "" {
+        return false
+    }
+    if ownerID == userID {
+        return true
+    }
+    if ownerID == "" && (action == Read || admin) {
+        return true
+    }
+    if teamID == "" {
+        return false
+    }
+    return t.Role(teamID, userID).Allows(action)
+}
+
+// Role returns a user's role in a team, empty if not a member
+func (t *Teams) Role(teamID string, userID string) Role {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    team, ok := t.teams[teamID]
+    if !ok {
+        return ""
+    }
+    return team.Members[userID]
+}
+
+// List returns the teams a user is a member of, sorted by name
+func (t *Teams) List(userID string) []Team {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    teams := []Team{}
+    for _, team := range t.teams {
+        if _, ok := team.Members[userID]; ok {
+            teams = append(teams, copyTeam(team))
+        }
+    }
+    sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+    return teams
+}
+
+// Get returns a team
+func (t *Teams) Get(teamID string) (*Team, error) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    team, ok := t.teams[teamID]
+    if !ok {
+        return nil, ErrNotFound
+    }
+    c := copyTeam(team)
+    return &c, nil
+}
+
+// Create adds a team with the creator as its admin
+func (t *Teams) Create(name string, creatorID string) (*Team, error) {
+    name = strings.TrimSpace(name)
+    if name == "" || len(name) > 100 {
+        return nil, ErrInvalidName
+    }
+    b := make([]byte, 12)
+    if _, err := rand.Read(b); err != nil {
+        return nil, err
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    team := &Team{ID: hex.EncodeToString(b), Name: name, Members: map[string]Role{creatorID: Admin}, CreatedAt: time.Now()}
+    t.teams[team.ID] = team
+    if err := t.save(); err != nil {
+        delete(t.teams, team.ID)
+        return nil, err
+    }
+    c := copyTeam(team)
+    return &c, nil
+}
+
+// Delete removes a team, meetings shared with it stay with their owners
+func (t *Teams) Delete(teamID string) error {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if _, ok := t.teams[teamID]; !ok {
+        return ErrNotFound
+    }
+    delete(t.teams, teamID)
+    return t.save()
+}
+
+// SetMember adds a user to a team or changes their role
+func (t *Teams) SetMember(teamID string, userID string, role Role) error {
+    if !ValidRole(role) {
+        return ErrInvalidRole
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    team, ok := t.teams[teamID]
+    if !ok {
+        return ErrNotFound
+    }
+    previous, wasMember := team.Members[userID]
+    if previous == Admin && role != Admin && countAdmins(team) == 1 {
+        return ErrLastAdmin
+    }
+    team.Members[userID] = role
+    if err := t.save(); err != nil {
+        if wasMember {
+            team.Members[userID] = previous
+        } else {
+            delete(team.Members, userID)
+        }
+        return err
+    }
+    return nil
+}
+
+// RemoveMember removes a user from a team
+func (t *Teams) RemoveMember(teamID string, userID string) error {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    team, ok := t.teams[teamID]
+    if !ok {
+        return ErrNotFound
+    }
+    role, ok := team.Members[userID]
+    if !ok {
+        return ErrNotMember
+    }
+    if role == Admin && countAdmins(team) == 1 {
+        return ErrLastAdmin
+    }
+    delete(team.Members, userID)
+    if err := t.save(); err != nil {
+        team.Members[userID] = role
+        return err
+    }
+    return nil
+}
+
+func countAdmins(team *Team) int {
+    admins := 0
+    for _, role := range team.Members {
+        if role == Admin {
+            admins++
+        }
+    }
+    return admins
+}
+
+func copyTeam(team *Team) Team {
+    c := *team
+    c.Members = make(map[string]Role, len(team.Members))
+    for userID, role := range team.Members {
+        c.Members[userID] = role
+    }
+    return c
+}
+
+// save writes all teams to the JSON file, caller must hold t.mu
+func (t *Teams) save() error {
+    teams := make([]*Team, 0, len(t.teams))
+    for _, team := range t.teams {
+        teams = append(teams, team)
+    }
+    sort.Slice(teams, func(i, j int) bool { return teams[i].ID < teams[j].ID })
+
+    data, err := json.Marshal(teams)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+        return err
+    }
+    // Write to a temp file first so a crash never leaves a truncated file
+    tmpPath := t.path + ".tmp"
+    if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, t.path)
+}
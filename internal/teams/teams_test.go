@@ -0,0 +1,120 @@
+package teams
+
+import (
+    "path/filepath"
+    "testing"
+)
+
+func TestAllowed(t *testing.T) {
+    teams, err := NewTeams(filepath.Join(t.TempDir(), "teams.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    team, err := teams.Create("Engineering", "ana")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for user, role := range map[string]Role{"vic": Viewer, "eve": Editor, "adam": Admin} {
+        if err := teams.SetMember(team.ID, user, role); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    // What each user may do as read, edit, delete
+    type permissions [3]bool
+    tests := []struct {
+        name   string
+        owner  string
+        team   string
+        user   string
+        admin  bool
+        allows permissions
+    }{
+        {"owner", "ana", "", "ana", false, permissions{true, true, true}},
+        {"owner of a shared meeting", "ana", team.ID, "ana", false, permissions{true, true, true}},
+        {"other user", "ana", "", "bob", false, permissions{false, false, false}},
+        {"site admin on another's meeting", "ana", "", "bob", true, permissions{false, false, false}},
+        {"anonymous", "ana", team.ID, "", false, permissions{false, false, false}},
+        {"viewer", "ana", team.ID, "vic", false, permissions{true, false, false}},
+        {"editor", "ana", team.ID, "eve", false, permissions{true, true, false}},
+        {"team admin", "ana", team.ID, "adam", false, permissions{true, true, true}},
+        {"not a member", "ana", team.ID, "bob", false, permissions{false, false, false}},
+        {"unknown team", "ana", "nope", "vic", false, permissions{false, false, false}},
+        // Meetings from before accounts
+        {"no owner", "", "", "bob", false, permissions{true, false, false}},
+        {"no owner, site admin", "", "", "bob", true, permissions{true, true, true}},
+        {"no owner, anonymous", "", "", "", false, permissions{false, false, false}},
+        {"no owner, team member", "", team.ID, "eve", false, permissions{true, true, false}},
+    }
+    for _, tt := range tests {
+        for action, want := range tt.allows {
+            if got := teams.Allowed(tt.owner, tt.team, tt.user, tt.admin, Action(action)); got != want {
+                t.Errorf("%s: Allowed(action %d) = %v, want %v", tt.name, action, got, want)
+            }
+        }
+    }
+}
+
+func TestRoleAllows(t *testing.T) {
+    tests := []struct {
+        role   Role
+        allows [3]bool
+    }{
+        {Viewer, [3]bool{true, false, false}},
+        {Editor, [3]bool{true, true, false}},
+        {Admin, [3]bool{true, true, true}},
+        {"", [3]bool{false, false, false}},
+        {"owner", [3]bool{false, false, false}},
+    }
+    for _, tt := range tests {
+        for action, want := range tt.allows {
+            if got := tt.role.Allows(Action(action)); got != want {
+                t.Errorf("%q.Allows(%d) = %v, want %v", tt.role, action, got, want)
+            }
+        }
+    }
+}
+
+func TestMembers(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "teams.json")
+    teams, err := NewTeams(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    team, err := teams.Create("Engineering", "ana")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name string
+        op   func() error
+        err  error
+    }{
+        {"invalid role", func() error { return teams.SetMember(team.ID, "bob", "owner") }, ErrInvalidRole},
+        {"unknown team", func() error { return teams.SetMember("nope", "bob", Viewer) }, ErrNotFound},
+        {"demote the last admin", func() error { return teams.SetMember(team.ID, "ana", Editor) }, ErrLastAdmin},
+        {"remove the last admin", func() error { return teams.RemoveMember(team.ID, "ana") }, ErrLastAdmin},
+        {"add a second admin", func() error { return teams.SetMember(team.ID, "bob", Admin) }, nil},
+        {"demote the first admin", func() error { return teams.SetMember(team.ID, "ana", Viewer) }, nil},
+        {"remove a non-member", func() error { return teams.RemoveMember(team.ID, "cid") }, ErrNotMember},
+        {"remove a member", func() error { return teams.RemoveMember(team.ID, "ana") }, nil},
+    }
+    for _, tt := range tests {
+        if err := tt.op(); err != tt.err {
+            t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+        }
+    }
+
+    // Changes are saved
+    reloaded, err := NewTeams(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if reloaded.Role(team.ID, "bob") != Admin || reloaded.Role(team.ID, "ana") != "" {
+        t.Errorf("reloaded members = %v", reloaded.teams[team.ID].Members)
+    }
+    if list := reloaded.List("bob"); len(list) != 1 || list[0].Name != "Engineering" {
+        t.Errorf("List(bob) = %+v", list)
+    }
+}
@@ -0,0 +1,53 @@
+// Package tracing sets up OpenTelemetry tracing. Spans are created with the global tracer
+// provider, which drops them until Setup installs one with an exporter.
+package tracing
+
+import (
+    "context"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// Service name of the exported spans unless OTEL_SERVICE_NAME is set
+const serviceName = "go-ai-summarizer"
+
+// NewOTLPExporter returns an exporter sending spans to a collector over OTLP/HTTP, configured
+// by the standard OTEL_EXPORTER_OTLP_* environment variables
+func NewOTLPExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+    return otlptracehttp.New(ctx)
+}
+
+// Setup makes the global tracer provider export spans to exporter in batches, and accepts
+// and propagates W3C trace context headers. Shut the returned provider down on exit to
+// flush the last spans. Tests can pass an in-memory exporter (sdk/trace/tracetest).
+func Setup(ctx context.Context, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+    res, err := resource.New(ctx,
+        resource.WithSchemaURL(semconv.SchemaURL),
+        resource.WithAttributes(semconv.ServiceName(serviceName)),
+        resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the above
+        resource.WithTelemetrySDK(),
+    )
+    if err != nil {
+        return nil, err
+    }
+    provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+    otel.SetTracerProvider(provider)
+    otel.SetTextMapPropagator(propagation.TraceContext{})
+    return provider, nil
+}
+
+// End ends span, marking it failed with err if set
+func End(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+)
+
+// taskToken returns the task access token of a request, sent in the X-Task-Token header or
+// as the token query parameter. Bearer tokens are credentials of accounts, never task tokens.
+func taskToken(r *http.Request) string {
+    if token := r.Header.Get("X-Task-Token"); token != "" {
+        return token
+    }
+    return r.URL.Query().Get("token")
+}
+
+// userID returns the ID of the authenticated user, empty for anonymous requests
+func userID(r *http.Request) string {
+    if user := auth.UserFromContext(r.Context()); user != nil {
+        return user.ID
+    }
+    return ""
+}
+
+// isAdmin reports whether a user (empty if anonymous) is a site admin
+func (h *HTTPHandler) isAdmin(user string) bool {
+    if user == "" {
+        return false
+    }
+    account, err := h.Users.Get(user)
+    return err == nil && account.Admin
+}
+
+// authorizeTask checks that the request may perform action on a task - as its owner, as a
+// member of the team it is shared with, or (for reading only) with the task's access token.
+// Responds with 404 if the task cannot even be read, so task IDs cannot be probed, and with
+// 403 if it can be read but the action is not allowed.
+func (h *HTTPHandler) authorizeTask(w http.ResponseWriter, r *http.Request, taskID string, action teams.Action) bool {
+    switch h.checkTask(userID(r), taskID, taskToken(r), action) {
+    case http.StatusOK:
+        return true
+    case http.StatusForbidden:
+        writeError(w, http.StatusForbidden, "forbidden", "Not allowed for your role")
+    default:
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+    }
+    return false
+}
+
+// checkTask returns http.StatusOK if the user (empty if anonymous, with the given task access
+// token) may perform action on a task, otherwise the status authorizeTask responds with
+func (h *HTTPHandler) checkTask(user string, taskID string, token string, action teams.Action) int {
+    task, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        return http.StatusNotFound
+    }
+    admin := h.isAdmin(user)
+    if h.Teams.Allowed(task.OwnerID, task.TeamID, user, admin, action) {
+        return http.StatusOK
+    }
+    if !h.Teams.Allowed(task.OwnerID, task.TeamID, user, admin, teams.Read) && !queue.TokenMatches(task, token) {
+        return http.StatusNotFound
+    }
+    if action != teams.Read {
+        return http.StatusForbidden
+    }
+    return http.StatusOK
+}
+
+// readable selects the archived meetings the user of a request may read
+func (h *HTTPHandler) readable(r *http.Request) archive.Filter {
+    user := userID(r)
+    admin := h.isAdmin(user)
+    return func(meeting archive.Meeting) bool {
+        return h.Teams.Allowed(meeting.OwnerID, meeting.TeamID, user, admin, teams.Read)
+    }
+}
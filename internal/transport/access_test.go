@@ -0,0 +1,70 @@
+package transport
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+func TestTaskToken(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+    _, bobKey := a.user("bob")
+    var uploaded uploadResponse
+    json.Unmarshal(a.call("POST /tasks", "/tasks", anaKey, newUpload(t, "meeting.wav", nil), http.StatusAccepted), &uploaded)
+    path := "/tasks/" + uploaded.TaskID
+
+    tests := []struct {
+        name    string
+        method  string
+        target  string
+        headers map[string]string
+        want    int
+    }{
+        {"no token", "GET", path, nil, http.StatusNotFound},
+        {"token header", "GET", path, map[string]string{"X-Task-Token": uploaded.Token}, http.StatusOK},
+        {"token parameter", "GET", path + "?token=" + uploaded.Token, nil, http.StatusOK},
+        {"wrong token", "GET", path, map[string]string{"X-Task-Token": "wrong"}, http.StatusNotFound},
+        {"wrong token parameter", "GET", path + "?token=wrong", nil, http.StatusNotFound},
+        {"token of an unknown task", "GET", "/tasks/nope", map[string]string{"X-Task-Token": uploaded.Token}, http.StatusNotFound},
+        // Bearer tokens are API keys, a task token sent that way does not count
+        {"bearer token", "GET", path, map[string]string{"Authorization": "Bearer " + uploaded.Token}, http.StatusNotFound},
+        // The token only reads
+        {"delete with the token", "DELETE", path, map[string]string{"X-Task-Token": uploaded.Token}, http.StatusForbidden},
+        {"delete with the token as another user", "DELETE", path, map[string]string{"X-Task-Token": uploaded.Token, "X-API-Key": bobKey}, http.StatusForbidden},
+        {"read as another user", "GET", path, map[string]string{"X-API-Key": bobKey}, http.StatusNotFound},
+        {"read as another user with the token", "GET", path, map[string]string{"X-API-Key": bobKey, "X-Task-Token": uploaded.Token}, http.StatusOK},
+        {"delete as the owner", "DELETE", path, map[string]string{"X-API-Key": anaKey}, http.StatusNoContent},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest(tt.method, tt.target, nil)
+        for name, value := range tt.headers {
+            r.Header.Set(name, value)
+        }
+        w := httptest.NewRecorder()
+        a.server.ServeHTTP(w, r)
+        if w.Code != tt.want {
+            t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+        }
+    }
+}
+
+func TestOwnerlessMeeting(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana") // the first account, an admin
+    _, bobKey := a.user("bob")
+    // Archived before accounts existed
+    if err := a.h.Archive.Save(types.Task{ID: "old", Status: "completed", Result: types.Result{Transcript: "WEBVTT\n"}}); err != nil {
+        t.Fatal(err)
+    }
+
+    a.call("GET /tasks/{id}", "/tasks/old", bobKey, nil, http.StatusOK)
+    a.call("PUT /tasks/{id}/team", "/tasks/old/team", bobKey, map[string]string{"team_id": ""}, http.StatusForbidden)
+    a.call("DELETE /tasks/{id}", "/tasks/old", bobKey, nil, http.StatusForbidden)
+    a.call("GET /tasks/{id}", "/tasks/old", "", nil, http.StatusNotFound)
+    a.call("PUT /tasks/{id}/team", "/tasks/old/team", anaKey, map[string]string{"team_id": ""}, http.StatusOK)
+    a.call("DELETE /tasks/{id}", "/tasks/old", anaKey, nil, http.StatusNoContent)
+}
@@ -0,0 +1,173 @@
+package transport
+
+import (
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// newTaskInfo leaves out the result, which is served by /tasks/{id}/result
+func newTaskInfo(task *types.Task) types.TaskInfo {
+    info := types.TaskInfo{ID: task.ID, OwnerID: task.OwnerID, TeamID: task.TeamID, Status: task.Status,
+        Meeting: task.Meeting, ExpiresAt: task.ExpiresAt}
+    if !task.CreatedAt.IsZero() {
+        createdAt := task.CreatedAt
+        info.CreatedAt = &createdAt
+    }
+    return info
+}
+
+// APIv1 returns the versioned REST API, mounted under /api/v1
+func (h *HTTPHandler) APIv1() *Router {
+    rt := NewRouter()
+    rt.Handle("/tasks", auth.RequireUser(h.listTasks), "GET")
+    rt.Handle("/tasks", auth.RequireUser(h.HandleFileUpload), "POST")
+    rt.Handle("/tasks/{id}", h.getTask, "GET")
+    rt.Handle("/tasks/{id}", h.deleteTask, "DELETE")
+    rt.Handle("/tasks/{id}/result", h.getTaskResult, "GET")
+    rt.Handle("/tasks/{id}/logs", h.getTaskLogs, "GET")
+    rt.Handle("/queue", h.HandleTasksInQueue, "GET")
+    rt.Handle("/testimonials", h.GetTestimonials, "GET")
+    rt.Handle("/testimonials", h.SubmitTestimonial, "POST")
+    h.sharedRoutes(rt)
+    return rt
+}
+
+// Legacy returns the original unversioned paths, kept as aliases of the API for the web page
+// and existing scripts
+func (h *HTTPHandler) Legacy() *Router {
+    rt := NewRouter()
+    rt.Handle("/upload", auth.RequireUser(h.HandleFileUpload), "POST")
+    rt.Handle("/status", h.HandleStatus, "GET")
+    rt.Handle("/counter", h.HandleCounter, "GET")
+    rt.Handle("/tasksInQueue", h.HandleTasksInQueue, "GET")
+    rt.Handle("/tasks/{id}", h.getTaskWithResult, "GET")
+    rt.Handle("/tasks/{id}", h.deleteTask, "DELETE")
+    rt.Handle("/get-testimonials", h.GetTestimonials, "GET")
+    rt.Handle("/submit-testimonial", h.SubmitTestimonial, "POST")
+    h.sharedRoutes(rt)
+    return rt
+}
+
+// sharedRoutes registers the paths that are the same in the API and the legacy aliases
+func (h *HTTPHandler) sharedRoutes(rt *Router) {
+    rt.Handle("/tasks/{id}/summaries", h.listSummaries, "GET")
+    rt.Handle("/tasks/{id}/summaries", h.createSummary, "POST")
+    rt.Handle("/tasks/{id}/summaries/{summary}", h.getSummary, "GET")
+    rt.Handle("/tasks/{id}/speakers", h.getSpeakers, "GET")
+    rt.Handle("/tasks/{id}/speakers", h.renameSpeakers, "PUT")
+    rt.Handle("/tasks/{id}/analytics", h.getAnalytics, "GET")
+    rt.Handle("/tasks/{id}/chat", h.chatAboutTask, "POST")
+    rt.Handle("/tasks/{id}/team", h.setTaskTeam, "PUT")
+    // Templates and the speaker registry are shared by all users, only admins change them
+    rt.Handle("/templates", auth.RequireUser(h.listTemplates), "GET")
+    rt.Handle("/templates", auth.RequireAdmin(h.createTemplate), "POST")
+    rt.Handle("/templates/{name}", auth.RequireUser(h.getTemplate), "GET")
+    rt.Handle("/templates/{name}", auth.RequireAdmin(h.updateTemplate), "PUT")
+    rt.Handle("/templates/{name}", auth.RequireAdmin(h.deleteTemplate), "DELETE")
+    rt.Handle("/templates/{name}/versions", auth.RequireUser(h.listTemplateVersions), "GET")
+    rt.Handle("/speakers", auth.RequireUser(h.listSpeakers), "GET")
+    rt.Handle("/speakers", auth.RequireAdmin(h.enrollSpeaker), "POST")
+    rt.Handle("/speakers/{name}", auth.RequireAdmin(h.HandleEnrolledSpeaker), "DELETE")
+    rt.Handle("/search", auth.RequireUser(h.HandleSearch), "GET")
+    rt.Handle("/ask", auth.RequireUser(h.HandleAsk), "POST")
+    rt.Handle("/archive", auth.RequireUser(h.HandleArchive), "GET")
+    rt.Handle("/archive/{id}", h.HandleArchivedMeeting, "GET")
+    rt.Handle("/auth/signup", h.HandleSignup, "POST")
+    rt.Handle("/auth/login", h.HandleLogin, "POST")
+    rt.Handle("/auth/logout", h.HandleLogout, "POST")
+    rt.Handle("/auth/me", auth.RequireUser(h.HandleMe), "GET")
+    rt.Handle("/auth/keys", auth.RequireUser(h.listAPIKeys), "GET")
+    rt.Handle("/auth/keys", auth.RequireUser(h.createAPIKey), "POST")
+    rt.Handle("/auth/keys/{id}", auth.RequireUser(h.HandleAPIKey), "DELETE")
+    rt.Handle("/teams", auth.RequireUser(h.listTeams), "GET")
+    rt.Handle("/teams", auth.RequireUser(h.createTeam), "POST")
+    rt.Handle("/teams/{id}", auth.RequireUser(h.getTeam), "GET")
+    rt.Handle("/teams/{id}", auth.RequireUser(h.deleteTeam), "DELETE")
+    rt.Handle("/teams/{id}/members/{username}", auth.RequireUser(h.setTeamMember), "PUT")
+    rt.Handle("/teams/{id}/members/{username}", auth.RequireUser(h.removeTeamMember), "DELETE")
+}
+
+// listTasks serves GET /tasks
+func (h *HTTPHandler) listTasks(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, h.readableTasks(userID(r)))
+}
+
+// readableTasks returns the tasks a user may read, queued and in-memory ones first, then
+// meetings only left in the archive
+func (h *HTTPHandler) readableTasks(user string) []types.TaskInfo {
+    tasks := []types.TaskInfo{}
+    seen := make(map[string]bool)
+    admin := h.isAdmin(user)
+    for _, task := range h.Queue.List() {
+        seen[task.ID] = true
+        if task.Transcription {
+            continue // only lives as long as its /v1/audio/transcriptions request
+        }
+        if h.Teams.Allowed(task.OwnerID, task.TeamID, user, admin, teams.Read) {
+            tasks = append(tasks, newTaskInfo(task))
+        }
+    }
+    for _, meeting := range h.Archive.List() {
+        if seen[meeting.TaskID] || !h.Teams.Allowed(meeting.OwnerID, meeting.TeamID, user, admin, teams.Read) {
+            continue
+        }
+        tasks = append(tasks, types.TaskInfo{ID: meeting.TaskID, OwnerID: meeting.OwnerID, TeamID: meeting.TeamID,
+            Status: "completed", Meeting: types.Meeting{Title: meeting.Title, Date: meeting.Date}, ExpiresAt: meeting.ExpiresAt})
+    }
+    return tasks
+}
+
+// getTask serves GET /tasks/{id}: status and meeting details without the result
+func (h *HTTPHandler) getTask(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    task, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    writeJSON(w, http.StatusOK, newTaskInfo(task))
+}
+
+// getTaskResult serves GET /tasks/{id}/result, 409 until processing has finished
+func (h *HTTPHandler) getTaskResult(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    task, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    if task.Status != "completed" && task.Status != "failed" {
+        writeError(w, http.StatusConflict, "task_not_finished", "Task is still "+task.Status)
+        return
+    }
+    writeJSON(w, http.StatusOK, task.Result)
+}
+
+// getTaskLogs serves GET /tasks/{id}/logs: the task's processing log as plain text
+func (h *HTTPHandler) getTaskLogs(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    data, err := h.Queue.TaskLog(taskID)
+    if err == queue.ErrTaskNotFound {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Failed to read task log")
+        return
+    }
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Write(data)
+}
@@ -0,0 +1,69 @@
+package transport
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+)
+
+// Default and maximum number of search hits returned
+const (
+    defaultSearchLimit = 20
+    maxSearchLimit     = 200
+)
+
+// HandleSearch serves GET /search?q=...&limit=N - full-text search over the user's archived transcripts and summaries
+func (h *HTTPHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+    query := strings.TrimSpace(r.URL.Query().Get("q"))
+    if query == "" {
+        writeError(w, http.StatusBadRequest, "missing_query", "Missing search query")
+        return
+    }
+    limit := defaultSearchLimit
+    if l := r.URL.Query().Get("limit"); l != "" {
+        var err error
+        if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+            writeError(w, http.StatusBadRequest, "invalid_limit", "Invalid limit")
+            return
+        }
+        if limit > maxSearchLimit {
+            limit = maxSearchLimit
+        }
+    }
+    writeJSON(w, http.StatusOK, h.Archive.Search(query, limit, h.readable(r)))
+}
+
+// HandleArchive serves GET /archive: archived meetings the user may read, only those shared
+// with a team with ?team=<id>
+func (h *HTTPHandler) HandleArchive(w http.ResponseWriter, r *http.Request) {
+    readable := h.readable(r)
+    team := r.URL.Query().Get("team")
+    meetings := []archive.Meeting{}
+    for _, meeting := range h.Archive.List() {
+        if readable(meeting) && (team == "" || meeting.TeamID == team) {
+            meetings = append(meetings, meeting)
+        }
+    }
+    writeJSON(w, http.StatusOK, meetings)
+}
+
+// HandleArchivedMeeting serves GET /archive/{id}: one archived meeting
+func (h *HTTPHandler) HandleArchivedMeeting(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    task, err := h.Archive.Get(taskID)
+    if err == archive.ErrNotFound {
+        writeError(w, http.StatusNotFound, "meeting_not_found", "Meeting not found")
+        return
+    }
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    writeJSON(w, http.StatusOK, task)
+}
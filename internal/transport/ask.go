@@ -0,0 +1,52 @@
+package transport
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/semantic"
+)
+
+// Default and maximum number of transcript excerpts given to the LLM for a question
+const (
+    defaultAskLimit = 8
+    maxAskLimit     = 30
+)
+
+type askRequest struct {
+    Question string `json:"question"`
+    Limit    int    `json:"limit"`
+}
+
+// HandleAsk serves POST /ask - answers a natural-language question across the archived meetings the user may read
+func (h *HTTPHandler) HandleAsk(w http.ResponseWriter, r *http.Request) {
+    if h.Semantic == nil {
+        writeError(w, http.StatusServiceUnavailable, "semantic_search_unavailable", "Semantic search is not configured (set EMBEDDINGS_URL)")
+        return
+    }
+
+    var req askRequest
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1 << 20)).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body")
+        return
+    }
+    req.Question = strings.TrimSpace(req.Question)
+    if req.Question == "" {
+        writeError(w, http.StatusBadRequest, "missing_question", "Missing question")
+        return
+    }
+    if req.Limit <= 0 {
+        req.Limit = defaultAskLimit
+    }
+    if req.Limit > maxAskLimit {
+        req.Limit = maxAskLimit
+    }
+
+    answer, err := semantic.Ask(r.Context(), h.Semantic, h.LLM, req.Question, req.Limit, h.readable(r))
+    if err != nil {
+        writeError(w, http.StatusBadGateway, "llm_error", "Failed to answer question: " + err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, answer)
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+)
+
+type credentials struct {
+    Username string `json:"username"`
+    Password string `json:"password"`
+}
+
+// HandleSignup serves POST /auth/signup - creates an account and logs it in. Only the first
+// account can be created this way unless AllowSignup is set.
+func (h *HTTPHandler) HandleSignup(w http.ResponseWriter, r *http.Request) {
+    var req credentials
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    user, err := h.Users.Create(req.Username, req.Password, !h.AllowSignup)
+    switch err {
+    case nil:
+    case auth.ErrSignupClosed:
+        writeError(w, http.StatusForbidden, "signup_closed", "Signup is closed")
+        return
+    case auth.ErrUserExists:
+        writeError(w, http.StatusConflict, "username_taken", "Username is taken")
+        return
+    case auth.ErrInvalidUsername:
+        writeError(w, http.StatusBadRequest, "invalid_username", "Invalid username")
+        return
+    case auth.ErrWeakPassword:
+        writeError(w, http.StatusBadRequest, "invalid_password", "Password must have at least 8 characters")
+        return
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    if !h.startSession(w, r, user) {
+        return
+    }
+    writeJSON(w, http.StatusCreated, user)
+}
+
+// HandleLogin serves POST /auth/login - checks username and password and sets the session cookie
+func (h *HTTPHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+    var req credentials
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    user, err := h.Users.Login(req.Username, req.Password)
+    if err != nil {
+        writeError(w, http.StatusUnauthorized, "invalid_credentials", "Invalid username or password")
+        return
+    }
+    if !h.startSession(w, r, user) {
+        return
+    }
+    writeJSON(w, http.StatusOK, user)
+}
+
+// HandleLogout serves POST /auth/logout - ends the session
+func (h *HTTPHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+    if cookie, err := r.Cookie(auth.SessionCookie); err == nil {
+        h.Sessions.Delete(cookie.Value)
+    }
+    http.SetCookie(w, h.Sessions.Cookie("", r.TLS != nil))
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleMe serves GET /auth/me - the logged in user
+func (h *HTTPHandler) HandleMe(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, auth.UserFromContext(r.Context()))
+}
+
+type apiKeyRequest struct {
+    Name string `json:"name"`
+}
+
+// createdAPIKey is the only response that contains the key itself
+type createdAPIKey struct {
+    Key    string       `json:"key"`
+    APIKey *auth.APIKey `json:"api_key"`
+}
+
+// listAPIKeys serves GET /auth/keys: the user's API keys, without the keys themselves
+func (h *HTTPHandler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, auth.UserFromContext(r.Context()).APIKeys)
+}
+
+// createAPIKey serves POST /auth/keys: creates an API key, the key itself is only returned here
+func (h *HTTPHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+    user := auth.UserFromContext(r.Context())
+    var req apiKeyRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    secret, key, err := h.Users.CreateAPIKey(user.ID, req.Name)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    writeJSON(w, http.StatusCreated, createdAPIKey{Key: secret, APIKey: key})
+}
+
+// HandleAPIKey serves DELETE /auth/keys/{id} - revokes an API key
+func (h *HTTPHandler) HandleAPIKey(w http.ResponseWriter, r *http.Request) {
+    user := auth.UserFromContext(r.Context())
+    switch err := h.Users.DeleteAPIKey(user.ID, pathParam(r, "id")); err {
+    case nil:
+        w.WriteHeader(http.StatusNoContent)
+    case auth.ErrKeyNotFound:
+        writeError(w, http.StatusNotFound, "api_key_not_found", "API key not found")
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+    }
+}
+
+// startSession logs a user in by setting the session cookie
+func (h *HTTPHandler) startSession(w http.ResponseWriter, r *http.Request, user *auth.User) bool {
+    secret, err := h.Sessions.Create(user.ID)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return false
+    }
+    http.SetCookie(w, h.Sessions.Cookie(secret, r.TLS != nil))
+    return true
+}
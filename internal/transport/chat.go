@@ -0,0 +1,87 @@
+package transport
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/chat"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+)
+
+type chatRequest struct {
+    ConversationID string `json:"conversation_id"` // empty to start a new conversation
+    Message        string `json:"message"`
+}
+
+// chatAboutTask serves POST /tasks/{id}/chat: sends a message in a conversation about the task's
+// transcript and streams the reply as server-sent events: a "conversation" event with the
+// conversation ID, one "token" event per generated piece of text and a final "done" (or "error")
+// event. Chatting only needs read access.
+func (h *HTTPHandler) chatAboutTask(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    var req chatRequest
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1 << 20)).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    req.Message = strings.TrimSpace(req.Message)
+    if req.Message == "" {
+        writeError(w, http.StatusBadRequest, "missing_message", "Missing message")
+        return
+    }
+
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    if taskInfo.Result.Transcript == "" {
+        writeError(w, http.StatusConflict, "transcript_not_ready", "Task has no transcript yet")
+        return
+    }
+
+    conv, err := h.Chats.Conversation(taskID, req.ConversationID)
+    if err == chat.ErrNotFound {
+        writeError(w, http.StatusNotFound, "conversation_not_found", "Conversation not found")
+        return
+    } else if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error processing request")
+        return
+    }
+
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Streaming not supported")
+        return
+    }
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.WriteHeader(http.StatusOK)
+
+    writeEvent := func(event string, data interface{}) error {
+        payload, err := json.Marshal(data)
+        if err != nil {
+            return err
+        }
+        if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+            return err
+        }
+        flusher.Flush()
+        return nil
+    }
+
+    writeEvent("conversation", map[string]string{"conversation_id": conv.ID})
+    reply, err := h.Chats.Reply(r.Context(), conv, taskInfo.Result.Transcript, req.Message, func(token string) error {
+        return writeEvent("token", map[string]string{"token": token})
+    })
+    if err != nil {
+        writeEvent("error", map[string]string{"error": err.Error()})
+        return
+    }
+    writeEvent("done", map[string]string{"conversation_id": conv.ID, "reply": reply})
+}
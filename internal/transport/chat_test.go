@@ -0,0 +1,188 @@
+package transport
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/chat"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// serveLLM points the chats of a at a llama server API answering completions, started by
+// a python on PATH that only idles
+func (a *apiTest) serveLLM(completions http.HandlerFunc) {
+    a.t.Helper()
+    bin := a.t.TempDir()
+    if err := os.WriteFile(filepath.Join(bin, "python"), []byte("#!/bin/sh\nexec sleep 600\n"), 0755); err != nil {
+        a.t.Fatal(err)
+    }
+    a.t.Setenv("PATH", bin + string(os.PathListSeparator) + os.Getenv("PATH"))
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte(`{"data": []}`))
+    })
+    mux.HandleFunc("/v1/chat/completions", completions)
+    server := httptest.NewServer(mux)
+    a.t.Cleanup(server.Close)
+
+    llm := processing.NewLLM(time.Minute, server.Listener.Addr().(*net.TCPAddr).Port)
+    a.t.Cleanup(llm.Close)
+    a.h.Chats = chat.New(llm)
+}
+
+// sseEvent is one server-sent event
+type sseEvent struct {
+    name string
+    data map[string]string
+}
+
+// readEvent reads the next event, checking its framing: an event line, a data line and a blank line
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+    t.Helper()
+    var lines [3]string
+    for i := range lines {
+        line, err := reader.ReadString('\n')
+        if err != nil {
+            t.Fatalf("reading an event: %v after %q", err, lines[:i])
+        }
+        lines[i] = line
+    }
+    name, ok := strings.CutPrefix(lines[0], "event: ")
+    data, ok2 := strings.CutPrefix(lines[1], "data: ")
+    if !ok || !ok2 || lines[2] != "\n" {
+        t.Fatalf("badly framed event: %q", lines)
+    }
+    event := sseEvent{name: strings.TrimSuffix(name, "\n")}
+    if err := json.Unmarshal([]byte(data), &event.data); err != nil {
+        t.Fatalf("event data %q: %v", data, err)
+    }
+    return event
+}
+
+// postChat sends a chat message about a task as the owner of apiKey
+func postChat(t *testing.T, ctx context.Context, server *httptest.Server, taskID string, apiKey string, body string) *http.Response {
+    t.Helper()
+    req, err := http.NewRequestWithContext(ctx, "POST", server.URL + "/tasks/" + taskID + "/chat", strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-API-Key", apiKey)
+    resp, err := server.Client().Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return resp
+}
+
+func TestChatEvents(t *testing.T) {
+    a := newAPITest(t)
+    ana, anaKey := a.user("ana")
+    a.serveLLM(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/event-stream")
+        for _, token := range []string{"In ", "March."} {
+            fmt.Fprintf(w, "data: {\"choices\": [{\"delta\": {\"content\": %q}}]}\n\n", token)
+        }
+        fmt.Fprint(w, "data: [DONE]\n\n")
+    })
+    task := types.Task{ID: "done", OwnerID: ana.ID, Status: "completed", Result: types.Result{
+        Transcript: "WEBVTT\n\n00:00.000 --> 00:05.000\n[SPEAKER_00]: The rollout moves to March.\n\n",
+    }}
+    if err := a.h.Archive.Save(task); err != nil {
+        t.Fatal(err)
+    }
+    server := httptest.NewServer(a.server)
+    defer server.Close()
+
+    resp := postChat(t, context.Background(), server, "done", anaKey, `{"message": "When is the rollout?"}`)
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+        t.Fatalf("status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+    }
+    reader := bufio.NewReader(resp.Body)
+    conversation := readEvent(t, reader)
+    id := conversation.data["conversation_id"]
+    if conversation.name != "conversation" || id == "" {
+        t.Errorf("first event = %+v, want the conversation", conversation)
+    }
+    for _, token := range []string{"In ", "March."} {
+        if event := readEvent(t, reader); event.name != "token" || event.data["token"] != token {
+            t.Errorf("event = %+v, want token %q", event, token)
+        }
+    }
+    if done := readEvent(t, reader); done.name != "done" || done.data["reply"] != "In March." || done.data["conversation_id"] != id {
+        t.Errorf("last event = %+v", done)
+    }
+    if rest, _ := reader.ReadString(0); rest != "" {
+        t.Errorf("unexpected data after the done event: %q", rest)
+    }
+
+    // The conversation continues by ID, other IDs are unknown
+    followUp := postChat(t, context.Background(), server, "done", anaKey, `{"conversation_id": "` + id + `", "message": "Why?"}`)
+    followUp.Body.Close()
+    if followUp.StatusCode != http.StatusOK {
+        t.Errorf("follow-up: status %d", followUp.StatusCode)
+    }
+    unknown := postChat(t, context.Background(), server, "done", anaKey, `{"conversation_id": "nope", "message": "Why?"}`)
+    unknown.Body.Close()
+    if unknown.StatusCode != http.StatusNotFound {
+        t.Errorf("unknown conversation: status %d, want 404", unknown.StatusCode)
+    }
+}
+
+func TestChatClosesWhenCancelled(t *testing.T) {
+    a := newAPITest(t)
+    ana, anaKey := a.user("ana")
+    aborted := make(chan struct{})
+    a.serveLLM(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/event-stream")
+        fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"In \"}}]}\n\n")
+        w.(http.Flusher).Flush()
+        <-r.Context().Done()
+        close(aborted)
+    })
+    task := types.Task{ID: "done", OwnerID: ana.ID, Status: "completed", Result: types.Result{
+        Transcript: "WEBVTT\n\n00:00.000 --> 00:05.000\n[SPEAKER_00]: The rollout moves to March.\n\n",
+    }}
+    if err := a.h.Archive.Save(task); err != nil {
+        t.Fatal(err)
+    }
+    finished := make(chan struct{})
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        a.server.ServeHTTP(w, r)
+        close(finished)
+    }))
+    defer server.Close()
+
+    // The client goes away after the first token
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    resp := postChat(t, ctx, server, "done", anaKey, `{"message": "When is the rollout?"}`)
+    defer resp.Body.Close()
+    reader := bufio.NewReader(resp.Body)
+    readEvent(t, reader) // conversation
+    if event := readEvent(t, reader); event.name != "token" {
+        t.Fatalf("event = %+v, want a token", event)
+    }
+    cancel()
+
+    for what, done := range map[string]chan struct{}{"the completion request": aborted, "the stream": finished} {
+        select {
+        case <-done:
+        case <-time.After(5 * time.Second):
+            t.Fatalf("%s was not closed after the client went away", what)
+        }
+    }
+}
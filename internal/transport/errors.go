@@ -0,0 +1,13 @@
+package transport
+
+import (
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// writeError responds with a JSON error envelope, code is one of the machine-readable
+// codes documented in the README (task_not_found, invalid_request, ...)
+func writeError(w http.ResponseWriter, status int, code, message string) {
+    writeJSON(w, status, types.ErrorResponse{Error: types.APIError{Code: code, Message: message}})
+}
@@ -0,0 +1,331 @@
+package transport
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+
+    "go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/types/known/timestamppb"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+    "github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/summarizerpb"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// grpcService implements the gRPC Summarizer service with the same queue, access rules and
+// credentials as the HTTP API
+type grpcService struct {
+    summarizerpb.UnimplementedSummarizerServer
+    h             *HTTPHandler
+    authenticator auth.Authenticator
+}
+
+// GRPCServer returns a gRPC server with the Summarizer service. Calls are authenticated by
+// authenticator from their metadata, which is read like HTTP headers.
+func (h *HTTPHandler) GRPCServer(authenticator auth.Authenticator) *grpc.Server {
+    service := &grpcService{h: h, authenticator: authenticator}
+    server := grpc.NewServer(
+        grpc.StatsHandler(otelgrpc.NewServerHandler()), // a span per call
+        grpc.UnaryInterceptor(service.authenticateUnary),
+        grpc.StreamInterceptor(service.authenticateStream),
+    )
+    summarizerpb.RegisterSummarizerServer(server, service)
+    return server
+}
+
+// authenticate stores the user of a call in its context, calls without credentials stay anonymous
+func (s *grpcService) authenticate(ctx context.Context) (context.Context, error) {
+    md, _ := metadata.FromIncomingContext(ctx)
+    r := &http.Request{Header: http.Header{}}
+    for key, values := range md {
+        for _, value := range values {
+            r.Header.Add(key, value)
+        }
+    }
+    user, err := s.authenticator.Authenticate(r)
+    switch err {
+    case nil:
+        return auth.WithUser(ctx, user), nil
+    case auth.ErrNoCredentials:
+        return ctx, nil
+    }
+    return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+}
+
+func (s *grpcService) authenticateUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    ctx, err := s.authenticate(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return handler(ctx, req)
+}
+
+func (s *grpcService) authenticateStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    ctx, err := s.authenticate(stream.Context())
+    if err != nil {
+        return err
+    }
+    return handler(srv, authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+type authenticatedStream struct {
+    grpc.ServerStream
+    ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+    return s.ctx
+}
+
+func contextUserID(ctx context.Context) string {
+    if user := auth.UserFromContext(ctx); user != nil {
+        return user.ID
+    }
+    return ""
+}
+
+// authorize is authorizeTask for gRPC calls
+func (s *grpcService) authorize(ctx context.Context, taskID string, token string, action teams.Action) error {
+    switch s.h.checkTask(contextUserID(ctx), taskID, token, action) {
+    case http.StatusOK:
+        return nil
+    case http.StatusForbidden:
+        return status.Error(codes.PermissionDenied, "not allowed for your role")
+    }
+    return status.Error(codes.NotFound, "task not found")
+}
+
+// queueError converts an error of the queue into a gRPC status
+func queueError(err error) error {
+    switch err {
+    case queue.ErrTaskNotFound:
+        return status.Error(codes.NotFound, "task not found")
+    case queue.ErrTaskBusy:
+        return status.Error(codes.FailedPrecondition, "task is still being processed")
+    case queue.ErrInvalidTTL, queue.ErrUnknownTemplate, queue.ErrUnknownMode:
+        return status.Error(codes.InvalidArgument, err.Error())
+    case queue.ErrShuttingDown:
+        return status.Error(codes.Unavailable, err.Error())
+    }
+    return status.Error(codes.Internal, "internal error")
+}
+
+func (s *grpcService) Submit(stream summarizerpb.Summarizer_SubmitServer) error {
+    user := contextUserID(stream.Context())
+    if user == "" {
+        return status.Error(codes.Unauthenticated, "authentication required")
+    }
+    first, err := stream.Recv()
+    if err != nil {
+        return err
+    }
+    meta := first.GetMetadata()
+    if meta == nil {
+        return status.Error(codes.InvalidArgument, "the first message must carry the metadata")
+    }
+
+    suffix := recordingSuffix(meta.FileName)
+    if suffix == "" {
+        return status.Error(codes.InvalidArgument, "file must be a .wav or .mp4")
+    }
+    var ttl time.Duration
+    if meta.Ttl != nil {
+        if ttl = meta.Ttl.AsDuration(); ttl <= 0 {
+            return status.Error(codes.InvalidArgument, "invalid ttl")
+        }
+    }
+    if meta.TeamId != "" && !s.h.Teams.Role(meta.TeamId, user).Allows(teams.Edit) {
+        return status.Error(codes.PermissionDenied, "you are not an editor of this team")
+    }
+
+    filePath, err := receiveRecording(stream, suffix)
+    if err != nil {
+        return err
+    }
+
+    template := meta.Template
+    if template == "" {
+        template = prompts.DefaultTemplate
+    }
+    meeting := types.Meeting{Title: strings.TrimSpace(meta.Title), Date: strings.TrimSpace(meta.Date), Attendees: meta.Attendees}
+    taskID, token, err := s.h.Queue.Enqueue(stream.Context(), user, meta.TeamId, filePath, meeting, template, meta.Mode, ttl)
+    if err != nil {
+        os.Remove(filePath)
+        return queueError(err)
+    }
+    return stream.SendAndClose(&summarizerpb.SubmitResponse{TaskId: taskID, Token: token})
+}
+
+// receiveRecording writes the chunks of a Submit stream to a temp file and returns its path
+func receiveRecording(stream summarizerpb.Summarizer_SubmitServer, suffix string) (string, error) {
+    file, err := os.CreateTemp("", "upload-*." + suffix)
+    if err != nil {
+        return "", status.Error(codes.Internal, "failed to create temp file")
+    }
+    defer file.Close()
+
+    var size int64
+    for {
+        req, err := stream.Recv()
+        if err == io.EOF {
+            metrics.UploadBytes.WithLabelValues("grpc").Add(float64(size))
+            return file.Name(), nil
+        }
+        if err == nil && req.GetMetadata() != nil {
+            err = status.Error(codes.InvalidArgument, "metadata must only be sent in the first message")
+        }
+        if err == nil {
+            if size += int64(len(req.GetChunk())); size > maxUploadSize {
+                err = status.Error(codes.ResourceExhausted, "the uploaded file is too large")
+            }
+        }
+        if err == nil {
+            if _, err = file.Write(req.GetChunk()); err != nil {
+                err = status.Error(codes.Internal, "failed to save file")
+            }
+        }
+        if err != nil {
+            file.Close()
+            os.Remove(file.Name())
+            return "", err
+        }
+    }
+}
+
+func (s *grpcService) GetTask(ctx context.Context, req *summarizerpb.GetTaskRequest) (*summarizerpb.Task, error) {
+    if err := s.authorize(ctx, req.TaskId, req.Token, teams.Read); err != nil {
+        return nil, err
+    }
+    task, err := s.h.Queue.GetTaskInfo(req.TaskId)
+    if err != nil {
+        return nil, queueError(err)
+    }
+    return taskMessage(task, req.IncludeResult), nil
+}
+
+func (s *grpcService) WatchTask(req *summarizerpb.GetTaskRequest, stream summarizerpb.Summarizer_WatchTaskServer) error {
+    if err := s.authorize(stream.Context(), req.TaskId, req.Token, teams.Read); err != nil {
+        return err
+    }
+    sent := ""
+    for {
+        changed := s.h.Queue.Changed()
+        task, err := s.h.Queue.GetTaskInfo(req.TaskId)
+        if err != nil {
+            return queueError(err) // deleted or cancelled
+        }
+        if progress := progressOf(task); progress != sent {
+            if err := stream.Send(taskMessage(task, req.IncludeResult)); err != nil {
+                return err
+            }
+            sent = progress
+        }
+        if queue.IsFinished(task) {
+            return nil
+        }
+        select {
+        case <-stream.Context().Done():
+            return status.FromContextError(stream.Context().Err()).Err()
+        case <-changed:
+        }
+    }
+}
+
+// progressOf sums up the status of a task and its summaries, to send only actual changes
+func progressOf(task *types.Task) string {
+    progress := task.Status
+    for _, summary := range task.Result.Summaries {
+        progress += " " + summary.ID + ":" + summary.Status
+    }
+    return progress
+}
+
+func (s *grpcService) Cancel(ctx context.Context, req *summarizerpb.CancelRequest) (*summarizerpb.CancelResponse, error) {
+    if err := s.authorize(ctx, req.TaskId, req.Token, teams.Delete); err != nil {
+        return nil, err
+    }
+    if err := s.h.Queue.Delete(req.TaskId); err != nil {
+        return nil, queueError(err)
+    }
+    return &summarizerpb.CancelResponse{}, nil
+}
+
+func (s *grpcService) ListTasks(ctx context.Context, req *summarizerpb.ListTasksRequest) (*summarizerpb.ListTasksResponse, error) {
+    user := contextUserID(ctx)
+    if user == "" {
+        return nil, status.Error(codes.Unauthenticated, "authentication required")
+    }
+    resp := &summarizerpb.ListTasksResponse{}
+    for _, info := range s.h.readableTasks(user) {
+        resp.Tasks = append(resp.Tasks, taskInfoMessage(info))
+    }
+    return resp, nil
+}
+
+func taskMessage(task *types.Task, includeResult bool) *summarizerpb.Task {
+    message := taskInfoMessage(newTaskInfo(task))
+    if includeResult {
+        message.Result = resultMessage(task.Result)
+    }
+    return message
+}
+
+func taskInfoMessage(info types.TaskInfo) *summarizerpb.Task {
+    message := &summarizerpb.Task{
+        Id:      info.ID,
+        OwnerId: info.OwnerID,
+        TeamId:  info.TeamID,
+        Status:  info.Status,
+        Meeting: &summarizerpb.Meeting{Title: info.Meeting.Title, Date: info.Meeting.Date, Attendees: info.Meeting.Attendees},
+    }
+    if info.CreatedAt != nil {
+        message.CreatedAt = timestamppb.New(*info.CreatedAt)
+    }
+    if info.ExpiresAt != nil {
+        message.ExpiresAt = timestamppb.New(*info.ExpiresAt)
+    }
+    return message
+}
+
+func resultMessage(result types.Result) *summarizerpb.Result {
+    message := &summarizerpb.Result{
+        Transcript:         result.Transcript,
+        Summary:            result.Summary,
+        RecognizedSpeakers: result.RecognizedSpeakers,
+        ErrorMsg:           result.ErrorMsg,
+    }
+    for _, summary := range result.Summaries {
+        message.Summaries = append(message.Summaries, &summarizerpb.Summary{
+            Id:              summary.ID,
+            Template:        summary.Template,
+            TemplateVersion: int32(summary.TemplateVersion),
+            Mode:            summary.Mode,
+            Status:          summary.Status,
+            Text:            summary.Text,
+            ChunkSummaries:  summary.ChunkSummaries,
+            ErrorMsg:        summary.ErrorMsg,
+        })
+    }
+    for _, item := range result.ActionItems {
+        message.ActionItems = append(message.ActionItems, &summarizerpb.ActionItem{Owner: item.Owner, Task: item.Task, DueDate: item.DueDate, Timestamp: item.Timestamp})
+    }
+    for _, decision := range result.Decisions {
+        message.Decisions = append(message.Decisions, &summarizerpb.Decision{Text: decision.Text, Timestamp: decision.Timestamp})
+    }
+    for _, question := range result.OpenQuestions {
+        message.OpenQuestions = append(message.OpenQuestions, &summarizerpb.OpenQuestion{Text: question.Text, Timestamp: question.Timestamp})
+    }
+    return message
+}
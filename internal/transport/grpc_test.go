@@ -0,0 +1,247 @@
+package transport
+
+import (
+    "context"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/grpc/test/bufconn"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/internal/tasklog"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/summarizerpb"
+)
+
+// fakeWhisperx transcribes a recording into the working directory once the release file
+// exists, every recording has the transcript in transcript.vtt
+const fakeWhisperx = `#!/bin/sh
+while [ ! -e release ]; do
+    sleep 0.05
+done
+name=$(basename "$1")
+cp transcript.vtt "${name%.*}.vtt"
+`
+
+// fakeSummarizer idles as the llama server and prints the summary as the summarizer script
+const fakeSummarizer = `#!/bin/sh
+if [ "$1" = "-m" ]; then
+    exec sleep 600
+fi
+echo "The rollout moves to March."
+`
+
+// processTasks replaces the queue of a with one that processes uploads with fake tools into
+// transcript, transcribing is held back until release is called
+func (a *apiTest) processTasks(transcript string) (release func()) {
+    a.t.Helper()
+    if err := os.WriteFile("transcript.vtt", []byte(transcript), 0644); err != nil {
+        a.t.Fatal(err)
+    }
+    bin := a.t.TempDir()
+    for name, script := range map[string]string{"whisperx": fakeWhisperx, "python": fakeSummarizer} {
+        if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+            a.t.Fatal(err)
+        }
+    }
+    a.t.Setenv("PATH", bin + string(os.PathListSeparator) + os.Getenv("PATH"))
+
+    llamaAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte(`{"data": []}`))
+    }))
+    a.t.Cleanup(llamaAPI.Close)
+    llm := processing.NewLLM(time.Minute, llamaAPI.Listener.Addr().(*net.TCPAddr).Port)
+    a.t.Cleanup(llm.Close)
+    logs, err := tasklog.NewStore(a.t.TempDir())
+    if err != nil {
+        a.t.Fatal(err)
+    }
+    a.h.Queue = queue.NewQueue(processing.NewProcessor(a.h.Speakers, llm), a.h.Templates, a.h.Archive, queue.RetentionPolicy{}, logs)
+    go a.h.Queue.StartProcessing()
+    a.t.Cleanup(a.h.Queue.Close)
+
+    return func() {
+        if err := os.WriteFile("release", nil, 0644); err != nil {
+            a.t.Fatal(err)
+        }
+    }
+}
+
+// grpcClient serves the gRPC API of a over an in-memory connection
+func (a *apiTest) grpcClient() summarizerpb.SummarizerClient {
+    a.t.Helper()
+    listener := bufconn.Listen(1 << 20)
+    server := a.h.GRPCServer(auth.Chain{a.h.Sessions, auth.APIKeyAuthenticator{Users: a.h.Users}})
+    go server.Serve(listener)
+    a.t.Cleanup(server.Stop)
+
+    conn, err := grpc.DialContext(context.Background(), "bufnet",
+        grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+        grpc.WithTransportCredentials(insecure.NewCredentials()))
+    if err != nil {
+        a.t.Fatal(err)
+    }
+    a.t.Cleanup(func() { conn.Close() })
+    return summarizerpb.NewSummarizerClient(conn)
+}
+
+// withKey returns a context that sends apiKey with its calls, and is cancelled after a while
+func withKey(t *testing.T, apiKey string) context.Context {
+    ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
+    t.Cleanup(cancel)
+    return metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
+}
+
+// submit uploads a recording in two chunks and returns the new task's ID
+func submit(t *testing.T, ctx context.Context, client summarizerpb.SummarizerClient) string {
+    t.Helper()
+    stream, err := client.Submit(ctx)
+    if err != nil {
+        t.Fatal(err)
+    }
+    requests := []*summarizerpb.SubmitRequest{
+        {Payload: &summarizerpb.SubmitRequest_Metadata{Metadata: &summarizerpb.SubmitMetadata{FileName: "meeting.wav", Title: "Planning"}}},
+        {Payload: &summarizerpb.SubmitRequest_Chunk{Chunk: []byte("RIFF")}},
+        {Payload: &summarizerpb.SubmitRequest_Chunk{Chunk: []byte("WAVE")}},
+    }
+    for _, req := range requests {
+        if err := stream.Send(req); err != nil {
+            t.Fatal(err)
+        }
+    }
+    resp, err := stream.CloseAndRecv()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.TaskId == "" || resp.Token == "" {
+        t.Fatalf("Submit = %+v, want a task ID and a token", resp)
+    }
+    return resp.TaskId
+}
+
+// watchUntil reads updates of a WatchTask stream until the task has status
+func watchUntil(t *testing.T, watch summarizerpb.Summarizer_WatchTaskClient, status string) *summarizerpb.Task {
+    t.Helper()
+    for {
+        task, err := watch.Recv()
+        if err != nil {
+            t.Fatalf("waiting for %s: %v", status, err)
+        }
+        if task.Status == status {
+            return task
+        }
+    }
+}
+
+const rolloutTranscript = "WEBVTT\n\n00:00.000 --> 00:05.000\nThe rollout moves to March.\n\n"
+
+func TestGRPCWatchTask(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+    _, bobKey := a.user("bob")
+    release := a.processTasks(rolloutTranscript)
+    client := a.grpcClient()
+    ctx := withKey(t, anaKey)
+
+    taskID := submit(t, ctx, client)
+    other, err := client.WatchTask(withKey(t, bobKey), &summarizerpb.GetTaskRequest{TaskId: taskID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := other.Recv(); status.Code(err) != codes.NotFound {
+        t.Errorf("watching the task of another user: err = %v, want NotFound", err)
+    }
+
+    watch, err := client.WatchTask(ctx, &summarizerpb.GetTaskRequest{TaskId: taskID, IncludeResult: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    first, err := watch.Recv()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if first.Status != "waiting" && first.Status != "processing" {
+        t.Errorf("first update has status %s, want the current one", first.Status)
+    }
+    release()
+
+    // Every update is a change, the last one is the finished task with its result
+    updates := []*summarizerpb.Task{first}
+    for {
+        task, err := watch.Recv()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatal(err)
+        }
+        updates = append(updates, task)
+    }
+    last := updates[len(updates)-1]
+    if last.Status != "completed" || last.Result.GetSummary() != "The rollout moves to March.\n" {
+        t.Fatalf("last update = %v, want the completed task with its summary", last)
+    }
+    if summaries := last.Result.Summaries; len(summaries) != 1 || summaries[0].Status != "completed" {
+        t.Errorf("summaries = %v, want the completed initial summary", summaries)
+    }
+    progress := func(task *summarizerpb.Task) string {
+        return task.Status + " " + task.Result.Summaries[0].Status
+    }
+    for i := 1; i < len(updates); i++ {
+        if progress(updates[i]) == progress(updates[i-1]) {
+            t.Errorf("update %d repeats %s", i, progress(updates[i]))
+        }
+    }
+}
+
+func TestGRPCCancelBusy(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+    release := a.processTasks(rolloutTranscript)
+    client := a.grpcClient()
+    ctx := withKey(t, anaKey)
+
+    busy := submit(t, ctx, client)
+    waiting := submit(t, ctx, client)
+    watch, err := client.WatchTask(ctx, &summarizerpb.GetTaskRequest{TaskId: busy})
+    if err != nil {
+        t.Fatal(err)
+    }
+    watchUntil(t, watch, "processing")
+
+    // A task being processed cannot be cancelled, a waiting one is removed
+    if _, err := client.Cancel(ctx, &summarizerpb.CancelRequest{TaskId: busy}); status.Code(err) != codes.FailedPrecondition {
+        t.Errorf("cancelling a busy task: err = %v, want FailedPrecondition", err)
+    }
+    if task, err := client.GetTask(ctx, &summarizerpb.GetTaskRequest{TaskId: busy}); err != nil || task.Status != "processing" {
+        t.Errorf("busy task after Cancel = %v, %v, want it still processing", task, err)
+    }
+    if _, err := client.Cancel(ctx, &summarizerpb.CancelRequest{TaskId: waiting}); err != nil {
+        t.Errorf("cancelling a waiting task: %v", err)
+    }
+    if _, err := client.GetTask(ctx, &summarizerpb.GetTaskRequest{TaskId: waiting}); status.Code(err) != codes.NotFound {
+        t.Errorf("cancelled task: err = %v, want NotFound", err)
+    }
+
+    // Once finished it can be deleted
+    release()
+    watchUntil(t, watch, "completed")
+    if _, err := client.Cancel(ctx, &summarizerpb.CancelRequest{TaskId: busy}); err != nil {
+        t.Errorf("deleting a finished task: %v", err)
+    }
+    if _, err := client.GetTask(ctx, &summarizerpb.GetTaskRequest{TaskId: busy}); status.Code(err) != codes.NotFound {
+        t.Errorf("deleted task: err = %v, want NotFound", err)
+    }
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+    "context"
+    "net/http"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+)
+
+// How long /readyz waits for the LLM server to answer
+const readyzTimeout = 2 * time.Second
+
+// readiness is the response of /readyz
+type readiness struct {
+    Status string                            `json:"status"` // "ready" or "not_ready"
+    Checks map[string]processing.CheckResult `json:"checks"`
+}
+
+// Health returns the probe routes for container orchestrators, mounted at the root
+func (h *HTTPHandler) Health() *Router {
+    rt := NewRouter()
+    rt.Handle("/healthz", h.HandleHealthz, "GET")
+    rt.Handle("/readyz", h.HandleReadyz, "GET")
+    return rt
+}
+
+// HandleHealthz serves /healthz: the process is up and serving requests
+func (h *HTTPHandler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// HandleReadyz serves /readyz: 200 if uploads can be processed, 503 with the failed checks
+// otherwise. The commands, the model file, free disk space, the LLM server and the queue
+// (closed on shutdown) are checked.
+func (h *HTTPHandler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), readyzTimeout)
+    defer cancel()
+
+    checks := processing.CheckCommands()
+    checks["model"] = processing.CheckModel()
+    checks["disk"] = processing.CheckDiskSpace(h.MinFreeDisk)
+    checks["llm"] = h.LLM.Check(ctx)
+    if h.Queue.Closed() {
+        checks["queue"] = processing.CheckResult{Detail: "shutting down"}
+    } else {
+        checks["queue"] = processing.CheckResult{OK: true}
+    }
+
+    response := readiness{Status: "ready", Checks: checks}
+    status := http.StatusOK
+    for _, check := range checks {
+        if !check.OK {
+            response.Status = "not_ready"
+            status = http.StatusServiceUnavailable
+        }
+    }
+    writeJSON(w, status, response)
+}
@@ -10,8 +10,19 @@ import (
     "strings"
     "strconv"
     "sync"
+    "time"
     "net/http"
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/chat"
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+    "github.com/stanek-michal/go-ai-summarizer/internal/semantic"
+    "github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
     "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
 )
 
 // Mutex for counter.txt (visitor counter)
@@ -21,21 +32,38 @@ var testimonialsMutex sync.Mutex
 
 const counterPath = "web/counter.txt"
 
+// Maximum allowed size of an uploaded recording
+const maxUploadSize = 10 << 30 // 10 GB
+
 type HTTPHandler struct {
-    Queue *queue.Queue
+    Queue       *queue.Queue
+    Templates   *prompts.Store
+    Speakers    *speakers.Registry
+    Archive     *archive.Archive
+    LLM         *processing.LLM
+    Semantic    *semantic.Index // nil when no embeddings server is configured
+    Chats       *chat.Chats
+    Users       *auth.Users
+    Sessions    *auth.Sessions
+    Teams       *teams.Teams
+    AllowSignup bool            // anyone may create an account, otherwise only the first one
+    MinFreeDisk int64           // bytes that must be free in the temp directory for /readyz
 }
 
-func NewHTTPHandler(q *queue.Queue) *HTTPHandler {
-    return &HTTPHandler{Queue: q}
+func NewHTTPHandler(q *queue.Queue, templates *prompts.Store, registry *speakers.Registry, meetings *archive.Archive, llm *processing.LLM, index *semantic.Index) *HTTPHandler {
+    return &HTTPHandler{Queue: q, Templates: templates, Speakers: registry, Archive: meetings, LLM: llm, Semantic: index, Chats: chat.New(llm)}
 }
 
-func (h *HTTPHandler) HandleFileUpload(w http.ResponseWriter, r *http.Request) {
-    // Set the maximum allowed file size to 10GB
-    const maxUploadSize = 10 << 30 // 10 GB
+// uploadResponse identifies a new task, Token is needed to read it without an account
+type uploadResponse struct {
+    TaskID string `json:"task_id"`
+    Token  string `json:"token"`
+}
 
+func (h *HTTPHandler) HandleFileUpload(w http.ResponseWriter, r *http.Request) {
     // Check the size of the request body (optional but recommended)
     if r.ContentLength > maxUploadSize {
-        http.Error(w, "The uploaded file is too large", http.StatusRequestEntityTooLarge)
+        writeError(w, http.StatusRequestEntityTooLarge, "file_too_large", "The uploaded file is too large")
         return
     }
 
@@ -46,85 +74,136 @@ func (h *HTTPHandler) HandleFileUpload(w http.ResponseWriter, r *http.Request) {
     if err := r.ParseMultipartForm(maxUploadSize); err != nil {
         // Handle the case where the file is too large to fit in memory
         if err == http.ErrHandlerTimeout { // TODO this is probably wrong
-            http.Error(w, "The uploaded file is too large", http.StatusRequestEntityTooLarge)
+            writeError(w, http.StatusRequestEntityTooLarge, "file_too_large", "The uploaded file is too large")
         } else {
-            http.Error(w, "Error parsing multipart form", http.StatusInternalServerError)
+            writeError(w, http.StatusInternalServerError, "internal_error", "Error parsing multipart form")
         }
         return
     }
 
     file, header, err := r.FormFile("file")
     if err != nil {
-        http.Error(w, "Invalid file", http.StatusBadRequest)
+        writeError(w, http.StatusBadRequest, "invalid_file", "Invalid file")
         return
     }
     defer file.Close()
 
-    var suffix string
     // Check file type (must be .wav or .mp4)
-    if strings.HasSuffix(header.Filename, ".wav") {
-       suffix = "wav"
-    } else if strings.HasSuffix(header.Filename, ".mp4") {
-       suffix = "mp4"
-    } else {
-        http.Error(w, "File must be a .wav or .mp4", http.StatusBadRequest)
+    suffix := recordingSuffix(header.Filename)
+    if suffix == "" {
+        writeError(w, http.StatusBadRequest, "unsupported_file_type", "File must be a .wav or .mp4")
         return
     }
 
     // Create a temporary file on the disk to save the uploaded content
     tempFile, err := os.CreateTemp("", "upload-*." + suffix)
     if err != nil {
-        http.Error(w, "Failed to create temp file", http.StatusInternalServerError)
+        writeError(w, http.StatusInternalServerError, "internal_error", "Failed to create temp file")
         return
     }
     defer tempFile.Close()
 
     // Copy the contents of the uploaded file to the temp file
-    _, err = io.Copy(tempFile, file)
+    written, err := io.Copy(tempFile, file)
+    metrics.UploadBytes.WithLabelValues("rest").Add(float64(written))
     if err != nil {
-        http.Error(w, "Failed to save file", http.StatusInternalServerError)
+        writeError(w, http.StatusInternalServerError, "internal_error", "Failed to save file")
         return
     }
 
     // The path to the temp file
     filePath := tempFile.Name()
 
+    // Optional summary template and meeting details for the template
+    template := r.FormValue("template")
+    if template == "" {
+        template = prompts.DefaultTemplate
+    }
+    meeting := types.Meeting{
+        Title:     strings.TrimSpace(r.FormValue("title")),
+        Date:      strings.TrimSpace(r.FormValue("date")),
+        Attendees: parseAttendees(r.FormValue("attendees")),
+    }
+
+    // Optional lifetime of the result, e.g. "24h"
+    var ttl time.Duration
+    if value := r.FormValue("ttl"); value != "" {
+        if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 {
+            os.Remove(filePath)
+            writeError(w, http.StatusBadRequest, "invalid_ttl", "Invalid ttl")
+            return
+        }
+    }
+
+    // Optional team to share the meeting with, the uploader has to be an editor there
+    team := r.FormValue("team")
+    if team != "" && !h.Teams.Role(team, userID(r)).Allows(teams.Edit) {
+        os.Remove(filePath)
+        writeError(w, http.StatusForbidden, "forbidden", "You are not an editor of this team")
+        return
+    }
+
     // Enqueue the file path for processing
-    taskID, err := h.Queue.Enqueue(filePath)
+    taskID, token, err := h.Queue.Enqueue(r.Context(), userID(r), team, filePath, meeting, template, r.FormValue("mode"), ttl)
     if err != nil {
-        http.Error(w, "Error processing file", http.StatusInternalServerError)
+        os.Remove(filePath)
+        if err == queue.ErrInvalidTTL {
+            writeError(w, http.StatusBadRequest, "invalid_ttl", "ttl exceeds the maximum result lifetime")
+        } else if err == queue.ErrUnknownTemplate {
+            writeError(w, http.StatusBadRequest, "unknown_template", "Unknown summary template")
+        } else if err == queue.ErrUnknownMode {
+            writeError(w, http.StatusBadRequest, "unknown_mode", "Unknown summary mode")
+        } else if err == queue.ErrShuttingDown {
+            writeError(w, http.StatusServiceUnavailable, "shutting_down", "The server is shutting down")
+        } else {
+            writeError(w, http.StatusInternalServerError, "internal_error", "Error processing file")
+        }
         return
     }
 
-    // Respond with the task ID
-    w.WriteHeader(http.StatusAccepted)
-    json.NewEncoder(w).Encode(map[string]string{"task_id": strconv.Itoa(taskID)})
+    // Respond with the task ID and the token needed to read its results
+    writeJSON(w, http.StatusAccepted, uploadResponse{TaskID: taskID, Token: token})
+}
+
+// recordingSuffix returns the extension of a supported recording file name without the dot,
+// or "" if the file is not a .wav or .mp4
+func recordingSuffix(fileName string) string {
+    if strings.HasSuffix(fileName, ".wav") {
+        return "wav"
+    } else if strings.HasSuffix(fileName, ".mp4") {
+        return "mp4"
+    }
+    return ""
+}
+
+// parseAttendees splits a comma or newline separated list of attendee names
+func parseAttendees(value string) []string {
+    var attendees []string
+    for _, name := range strings.FieldsFunc(value, func(c rune) bool { return c == ',' || c == '\n' }) {
+        if name = strings.TrimSpace(name); name != "" {
+            attendees = append(attendees, name)
+        }
+    }
+    return attendees
 }
 
 func (h *HTTPHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
     // Extract task ID from query parameters
     taskID := r.URL.Query().Get("id")
     if taskID == "" {
-        http.Error(w, "Missing task ID", http.StatusBadRequest)
+        writeError(w, http.StatusBadRequest, "missing_task_id", "Missing task ID")
         return
     }
 
-    // Get the task status
-    idNum, err := strconv.Atoi(taskID)
-    if err != nil {
-        http.Error(w, "Invalid task ID", http.StatusBadRequest)
+    // Get the task status, readable by its owner, its team or with the access token from upload
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
         return
     }
-    taskInfo, err := h.Queue.GetTaskInfo(idNum)
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
     if err != nil {
-        http.Error(w, "Invalid task ID", http.StatusBadRequest)
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
         return
     }
-    if taskInfo.Status == "completed" || taskInfo.Status == "failed" {
-	// Can be removed from the queue now - client is getting result
-        h.Queue.Cleanup(idNum)
-    }
-
     // Respond with the task status
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(taskInfo)
@@ -179,22 +258,26 @@ func incrementCounter() (int, error) {
 func (h *HTTPHandler) HandleCounter(w http.ResponseWriter, r *http.Request) {
     count, err := incrementCounter()
     if err != nil {
-        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
         return
     }
     fmt.Fprintf(w, "%d", count)
 }
 
+type queueStatus struct {
+    TasksInQueue int `json:"tasks_in_queue"`
+}
+
 func (h *HTTPHandler) HandleTasksInQueue(w http.ResponseWriter, r *http.Request) {
     queueLength, err := h.Queue.GetQueueLength()
     if err != nil {
-        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
         return
     }
 
     // Respond with the number of tasks in the queue
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]int{"tasks_in_queue": queueLength})
+    json.NewEncoder(w).Encode(queueStatus{TasksInQueue: queueLength})
 }
 
 func (h *HTTPHandler) GetTestimonials(w http.ResponseWriter, r *http.Request) {
@@ -204,7 +287,7 @@ func (h *HTTPHandler) GetTestimonials(w http.ResponseWriter, r *http.Request) {
     // Read the JSON file
     data, err := ioutil.ReadFile("web/testimonials.json")
     if err != nil {
-        http.Error(w, "File reading error", 500)
+        writeError(w, http.StatusInternalServerError, "internal_error", "File reading error")
         return
     }
 
@@ -217,20 +300,15 @@ func (h *HTTPHandler) SubmitTestimonial(w http.ResponseWriter, r *http.Request)
     testimonialsMutex.Lock()
     defer testimonialsMutex.Unlock()
 
-    if r.Method != "POST" {
-        http.Error(w, "Invalid request method", 405)
-        return
-    }
-
     testimonial, err := ioutil.ReadAll(r.Body)
     if err != nil {
-        http.Error(w, "Invalid request", 400)
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
         return
     }
 
     trimmedTestimonial := strings.TrimSpace(string(testimonial))
     if len(trimmedTestimonial) == 0 {
-        http.Error(w, "Testimonial cannot be empty", 400)
+        writeError(w, http.StatusBadRequest, "invalid_request", "Testimonial cannot be empty")
         return
     }
 
@@ -238,13 +316,13 @@ func (h *HTTPHandler) SubmitTestimonial(w http.ResponseWriter, r *http.Request)
     var testimonials []string
     data, err := ioutil.ReadFile("web/testimonials.json")
     if err != nil {
-        http.Error(w, "File reading error", 500)
+        writeError(w, http.StatusInternalServerError, "internal_error", "File reading error")
         return
     }
 
     err = json.Unmarshal(data, &testimonials)
     if err != nil {
-        http.Error(w, "Error unmarshalling testimonials", 500)
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error unmarshalling testimonials")
         return
     }
 
@@ -254,15 +332,247 @@ func (h *HTTPHandler) SubmitTestimonial(w http.ResponseWriter, r *http.Request)
     // Marshal the updated testimonials back to JSON
     updatedData, err := json.Marshal(testimonials)
     if err != nil {
-        http.Error(w, "Error marshalling testimonials", 500)
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error marshalling testimonials")
         return
     }
 
     // Write the updated JSON data back to the file
     err = ioutil.WriteFile("web/testimonials.json", updatedData, 0666)
     if err != nil {
-        http.Error(w, "File writing error", 500)
+        writeError(w, http.StatusInternalServerError, "internal_error", "File writing error")
+        return
+    }
+}
+
+
+// getTaskWithResult serves the legacy GET /tasks/{id}: the task status and result like /status
+func (h *HTTPHandler) getTaskWithResult(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(taskInfo)
+}
+
+// deleteTask serves DELETE /tasks/{id}: removes the task and its archived meeting or cancels
+// it if it is still waiting
+func (h *HTTPHandler) deleteTask(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Delete) {
+        return
+    }
+    switch err := h.Queue.Delete(taskID); err {
+    case nil:
+        w.WriteHeader(http.StatusNoContent)
+    case queue.ErrTaskNotFound:
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+    case queue.ErrTaskBusy:
+        writeError(w, http.StatusConflict, "task_busy", "Task is still being processed")
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error processing request")
+    }
+}
+
+type summaryRequest struct {
+    Template string `json:"template"`
+    Version  int    `json:"version"` // 0 for the latest version of the template
+    Mode     string `json:"mode"`
+}
+
+// listSummaries serves GET /tasks/{id}/summaries: all summaries of a task
+func (h *HTTPHandler) listSummaries(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
         return
     }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(taskInfo.Result.Summaries)
 }
 
+// createSummary serves POST /tasks/{id}/summaries: re-summarizes the transcript with a chosen template
+func (h *HTTPHandler) createSummary(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Edit) {
+        return
+    }
+    var req summaryRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+
+    summary, err := h.Queue.EnqueueSummary(r.Context(), taskID, req.Template, req.Version, req.Mode)
+    switch err {
+    case nil:
+    case queue.ErrTaskNotFound:
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    case queue.ErrUnknownTemplate:
+        writeError(w, http.StatusBadRequest, "unknown_template", "Unknown summary template")
+        return
+    case queue.ErrUnknownMode:
+        writeError(w, http.StatusBadRequest, "unknown_mode", "Unknown summary mode")
+        return
+    case queue.ErrNoTranscript:
+        writeError(w, http.StatusConflict, "transcript_not_ready", "Task has no transcript to summarize yet")
+        return
+    case queue.ErrShuttingDown:
+        writeError(w, http.StatusServiceUnavailable, "shutting_down", "The server is shutting down")
+        return
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error processing request")
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(summary)
+}
+
+// getSummary serves GET /tasks/{id}/summaries/{summary}: a single summary of a task, including
+// its chunk-level summaries
+func (h *HTTPHandler) getSummary(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    for _, summary := range taskInfo.Result.Summaries {
+        if summary.ID == pathParam(r, "summary") {
+            w.Header().Set("Content-Type", "application/json")
+            json.NewEncoder(w).Encode(summary)
+            return
+        }
+    }
+    writeError(w, http.StatusNotFound, "summary_not_found", "Summary not found")
+}
+
+// speakerLabels are the labels in a task's transcript and the attendees given at upload
+type speakerLabels struct {
+    Speakers  []string `json:"speakers"`
+    Attendees []string `json:"attendees"`
+}
+
+// renameRequest maps speaker labels to names, optionally re-summarizing with them
+type renameRequest struct {
+    Names       map[string]string `json:"names"`
+    Resummarize bool              `json:"resummarize"`
+    Template    string            `json:"template"`
+    Mode        string            `json:"mode"`
+}
+
+type renameResponse struct {
+    Speakers []string       `json:"speakers"`
+    Summary  *types.Summary `json:"summary,omitempty"` // set if re-summarization was queued
+}
+
+// getSpeakers serves GET /tasks/{id}/speakers: the speaker labels of a task's transcript with
+// the attendees given at upload
+func (h *HTTPHandler) getSpeakers(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(speakerLabels{
+        Speakers:  processing.SpeakerLabels(taskInfo.Result.Transcript),
+        Attendees: taskInfo.Meeting.Attendees,
+    })
+}
+
+// renameSpeakers serves PUT /tasks/{id}/speakers: assigns names to speaker labels and optionally
+// re-summarizes the renamed transcript
+func (h *HTTPHandler) renameSpeakers(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Edit) {
+        return
+    }
+    var req renameRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Names) == 0 {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+
+    err := h.Queue.RenameSpeakers(taskID, req.Names)
+    switch err {
+    case nil:
+    case queue.ErrTaskNotFound:
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    case queue.ErrNoTranscript:
+        writeError(w, http.StatusConflict, "transcript_not_ready", "Task has no transcript yet")
+        return
+    case queue.ErrUnknownSpeaker:
+        writeError(w, http.StatusBadRequest, "unknown_speaker", "Unknown speaker label")
+        return
+    case queue.ErrInvalidSpeaker:
+        writeError(w, http.StatusBadRequest, "invalid_speaker_name", "Invalid speaker name")
+        return
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error processing request")
+        return
+    }
+
+    var response renameResponse
+    if req.Resummarize {
+        template := req.Template
+        if template == "" {
+            template = prompts.DefaultTemplate
+        }
+        summary, err := h.Queue.EnqueueSummary(r.Context(), taskID, template, 0, req.Mode)
+        if err != nil {
+            writeError(w, http.StatusBadRequest, "resummarize_failed", "Speakers renamed, but re-summarization failed: " + err.Error())
+            return
+        }
+        response.Summary = summary
+    }
+
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    response.Speakers = processing.SpeakerLabels(taskInfo.Result.Transcript)
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// getAnalytics serves GET /tasks/{id}/analytics: per-speaker talk time and participation
+// statistics computed from the transcript
+func (h *HTTPHandler) getAnalytics(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Read) {
+        return
+    }
+    taskInfo, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    if taskInfo.Result.Transcript == "" {
+        writeError(w, http.StatusConflict, "transcript_not_ready", "Task has no transcript yet")
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(processing.ComputeAnalytics(taskInfo.Result.Transcript))
+}
@@ -0,0 +1,92 @@
+package transport
+
+import (
+    "encoding/binary"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+)
+
+// silence returns a .wav recording of 16-bit mono silence at 8 kHz
+func silence(seconds int) []byte {
+    size := uint32(seconds * 16000)
+    header := []byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00")
+    binary.LittleEndian.PutUint32(header[4:8], 36 + size)
+    format := make([]byte, 16)
+    binary.LittleEndian.PutUint16(format[0:2], 1) // PCM
+    binary.LittleEndian.PutUint16(format[2:4], 1)
+    binary.LittleEndian.PutUint32(format[4:8], 8000)
+    binary.LittleEndian.PutUint32(format[8:12], 16000)
+    binary.LittleEndian.PutUint16(format[12:14], 2)
+    binary.LittleEndian.PutUint16(format[14:16], 16)
+    data := binary.LittleEndian.AppendUint32([]byte("data"), size)
+    return append(append(append(header, format...), data...), make([]byte, size)...)
+}
+
+// scrapeMetrics returns the samples served at /metrics by series, e.g.
+// `summarizer_upload_bytes_total{api="rest"}`
+func scrapeMetrics(t *testing.T) map[string]float64 {
+    t.Helper()
+    w := httptest.NewRecorder()
+    metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+    if w.Code != http.StatusOK {
+        t.Fatalf("GET /metrics: status %d", w.Code)
+    }
+    samples := make(map[string]float64)
+    for _, line := range strings.Split(w.Body.String(), "\n") {
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        i := strings.LastIndex(line, " ")
+        value, err := strconv.ParseFloat(line[i+1:], 64)
+        if err != nil {
+            t.Fatalf("bad sample %q: %v", line, err)
+        }
+        samples[line[:i]] = value
+    }
+    return samples
+}
+
+func TestMetricsAfterTask(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+    a.processTasks(rolloutTranscript)()
+    recording := silence(2)
+
+    before := scrapeMetrics(t)
+    a.call("POST /tasks", "/tasks", anaKey, newFileUpload(t, "meeting.wav", recording, nil), http.StatusAccepted)
+    a.waitForQueue("completed")
+    after := scrapeMetrics(t)
+
+    tests := []struct {
+        series string
+        delta  float64
+    }{
+        {`summarizer_upload_bytes_total{api="rest"}`, float64(len(recording))},
+        {`summarizer_audio_seconds_total`, 2},
+        {`summarizer_queue_wait_seconds_count`, 1},
+        {`summarizer_task_duration_seconds_count{kind="meeting",status="completed"}`, 1},
+        {`summarizer_stage_duration_seconds_count{stage="transcribe"}`, 1},
+        {`summarizer_stage_duration_seconds_count{stage="summarize"}`, 1},
+        {`summarizer_subprocess_starts_total{process="whisperx"}`, 1},
+        {`summarizer_subprocess_starts_total{process="generate_ai_summary.py"}`, 1},
+        {`summarizer_subprocess_starts_total{process="llama-server"}`, 1},
+    }
+    for _, tt := range tests {
+        value, ok := after[tt.series]
+        if !ok {
+            t.Errorf("%s is not served", tt.series)
+        } else if delta := value - before[tt.series]; delta != tt.delta {
+            t.Errorf("%s went up by %v, want %v", tt.series, delta, tt.delta)
+        }
+    }
+    for series, value := range after {
+        if strings.HasPrefix(series, "summarizer_failures_total") && value != before[series] {
+            t.Errorf("%s went up by %v for a successful task", series, value - before[series])
+        }
+    }
+}
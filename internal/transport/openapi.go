@@ -0,0 +1,388 @@
+package transport
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "reflect"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+    "github.com/stanek-michal/go-ai-summarizer/internal/semantic"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// Who may call an operation, empty for everyone
+const (
+    authUser  = "user"  // session cookie or API key
+    authAdmin = "admin" // like authUser, and the user must be an admin
+    authTask  = "task"  // like authUser, or the task's access token for reading
+)
+
+// Request and response bodies that are not JSON
+type (
+    multipartUpload struct{}
+    plainText       struct{}
+    eventStream     struct{}
+)
+
+// operation documents one method of an API route. Request and Response are values of the
+// types the handler decodes and encodes, their schemas are derived from the Go types.
+type operation struct {
+    Summary  string
+    Auth     string
+    Query    map[string]string // query parameter -> JSON schema type
+    Request  interface{}       // nil for no body
+    Status   int               // success status
+    Response interface{}       // nil for no body
+    Errors   []int
+}
+
+// apiOperations documents every route of APIv1 by "METHOD /path", buildOpenAPI fails if a
+// route is missing here or an entry has no route
+var apiOperations = map[string]operation{
+    "GET /tasks":     {Summary: "List the tasks and archived meetings the user may read", Auth: authUser, Status: http.StatusOK, Response: []types.TaskInfo{}, Errors: []int{401}},
+    "POST /tasks":    {Summary: "Upload a meeting recording for transcription and summarization", Auth: authUser, Request: multipartUpload{}, Status: http.StatusAccepted, Response: uploadResponse{}, Errors: []int{400, 401, 403, 413}},
+    "GET /tasks/{id}": {Summary: "Get the status and meeting details of a task", Auth: authTask, Status: http.StatusOK, Response: types.TaskInfo{}, Errors: []int{404}},
+    "DELETE /tasks/{id}": {Summary: "Delete a task and its archived meeting", Auth: authTask, Status: http.StatusNoContent, Errors: []int{403, 404, 409}},
+    "GET /tasks/{id}/result": {Summary: "Get the transcript, summaries and action items of a finished task", Auth: authTask, Status: http.StatusOK, Response: types.Result{}, Errors: []int{404, 409}},
+    "GET /tasks/{id}/logs": {Summary: "Get the processing log of a task, including the output of whisperx, ffmpeg and the summarizer", Auth: authTask, Status: http.StatusOK, Response: plainText{}, Errors: []int{404}},
+    "GET /tasks/{id}/summaries": {Summary: "List the summaries of a task", Auth: authTask, Status: http.StatusOK, Response: []types.Summary{}, Errors: []int{404}},
+    "POST /tasks/{id}/summaries": {Summary: "Summarize the transcript again with another template or mode", Auth: authTask, Request: summaryRequest{}, Status: http.StatusAccepted, Response: types.Summary{}, Errors: []int{400, 403, 404, 409}},
+    "GET /tasks/{id}/summaries/{summary}": {Summary: "Get one summary including its chunk summaries", Auth: authTask, Status: http.StatusOK, Response: types.Summary{}, Errors: []int{404}},
+    "GET /tasks/{id}/speakers": {Summary: "List the speaker labels of the transcript and the attendees", Auth: authTask, Status: http.StatusOK, Response: speakerLabels{}, Errors: []int{404}},
+    "PUT /tasks/{id}/speakers": {Summary: "Name speaker labels, optionally re-summarizing", Auth: authTask, Request: renameRequest{}, Status: http.StatusOK, Response: renameResponse{}, Errors: []int{400, 403, 404, 409}},
+    "GET /tasks/{id}/analytics": {Summary: "Get participation statistics of the meeting", Auth: authTask, Status: http.StatusOK, Response: types.Analytics{}, Errors: []int{404, 409}},
+    "POST /tasks/{id}/chat": {Summary: "Chat about the meeting, the reply is streamed as server-sent events", Auth: authTask, Request: chatRequest{}, Status: http.StatusOK, Response: eventStream{}, Errors: []int{400, 404, 409}},
+    "PUT /tasks/{id}/team": {Summary: "Share the task with a team or unshare it", Auth: authTask, Request: taskTeamRequest{}, Status: http.StatusOK, Response: taskTeamRequest{}, Errors: []int{400, 403, 404}},
+    "GET /queue": {Summary: "Get the number of tasks waiting or processing", Status: http.StatusOK, Response: queueStatus{}},
+    "GET /testimonials": {Summary: "List testimonials", Status: http.StatusOK, Response: []string{}},
+    "POST /testimonials": {Summary: "Add a testimonial", Request: plainText{}, Status: http.StatusOK, Errors: []int{400}},
+    "GET /templates": {Summary: "List the latest version of every summary template", Auth: authUser, Status: http.StatusOK, Response: []prompts.Template{}, Errors: []int{401}},
+    "POST /templates": {Summary: "Create a summary template", Auth: authAdmin, Request: templateRequest{}, Status: http.StatusCreated, Response: prompts.Template{}, Errors: []int{400, 401, 403, 409}},
+    "GET /templates/{name}": {Summary: "Get a summary template", Auth: authUser, Query: map[string]string{"version": "integer"}, Status: http.StatusOK, Response: prompts.Template{}, Errors: []int{400, 401, 404}},
+    "PUT /templates/{name}": {Summary: "Store a new version of a summary template", Auth: authAdmin, Request: templateRequest{}, Status: http.StatusOK, Response: prompts.Template{}, Errors: []int{400, 401, 403, 404}},
+    "DELETE /templates/{name}": {Summary: "Delete a summary template, summaries already queued with it are still generated", Auth: authAdmin, Status: http.StatusNoContent, Errors: []int{401, 403, 404, 409}},
+    "GET /templates/{name}/versions": {Summary: "List all versions of a summary template", Auth: authUser, Status: http.StatusOK, Response: []prompts.Template{}, Errors: []int{401, 404}},
+    "GET /speakers": {Summary: "List enrolled speakers", Auth: authUser, Status: http.StatusOK, Response: []speakerInfo{}, Errors: []int{401}},
+    "POST /speakers": {Summary: "Enroll a speaker from a task's speaker label or a voice embedding", Auth: authAdmin, Request: enrollRequest{}, Status: http.StatusCreated, Response: speakerInfo{}, Errors: []int{400, 401, 403, 404}},
+    "DELETE /speakers/{name}": {Summary: "Remove an enrolled speaker", Auth: authAdmin, Status: http.StatusNoContent, Errors: []int{401, 403, 404}},
+    "GET /search": {Summary: "Search transcripts and summaries of archived meetings", Auth: authUser, Query: map[string]string{"q": "string", "limit": "integer"}, Status: http.StatusOK, Response: []archive.Hit{}, Errors: []int{400, 401}},
+    "POST /ask": {Summary: "Answer a question from the archived meetings", Auth: authUser, Request: askRequest{}, Status: http.StatusOK, Response: semantic.Answer{}, Errors: []int{400, 401, 502, 503}},
+    "GET /archive": {Summary: "List archived meetings the user may read", Auth: authUser, Query: map[string]string{"team": "string"}, Status: http.StatusOK, Response: []archive.Meeting{}, Errors: []int{401}},
+    "GET /archive/{id}": {Summary: "Get an archived meeting", Auth: authTask, Status: http.StatusOK, Response: types.Task{}, Errors: []int{404}},
+    "POST /auth/signup": {Summary: "Create an account and log in", Request: credentials{}, Status: http.StatusCreated, Response: auth.User{}, Errors: []int{400, 403, 409}},
+    "POST /auth/login": {Summary: "Log in, setting the session cookie", Request: credentials{}, Status: http.StatusOK, Response: auth.User{}, Errors: []int{400, 401}},
+    "POST /auth/logout": {Summary: "Log out, ending the session", Status: http.StatusNoContent},
+    "GET /auth/me": {Summary: "Get the logged in user", Auth: authUser, Status: http.StatusOK, Response: auth.User{}, Errors: []int{401}},
+    "GET /auth/keys": {Summary: "List the user's API keys", Auth: authUser, Status: http.StatusOK, Response: []auth.APIKey{}, Errors: []int{401}},
+    "POST /auth/keys": {Summary: "Create an API key, the key is only returned here", Auth: authUser, Request: apiKeyRequest{}, Status: http.StatusCreated, Response: createdAPIKey{}, Errors: []int{400, 401}},
+    "DELETE /auth/keys/{id}": {Summary: "Revoke an API key", Auth: authUser, Status: http.StatusNoContent, Errors: []int{401, 404}},
+    "GET /teams": {Summary: "List the user's teams", Auth: authUser, Status: http.StatusOK, Response: []teamInfo{}, Errors: []int{401}},
+    "POST /teams": {Summary: "Create a team with the user as admin", Auth: authUser, Request: teamRequest{}, Status: http.StatusCreated, Response: teamInfo{}, Errors: []int{400, 401}},
+    "GET /teams/{id}": {Summary: "Get a team and its members", Auth: authUser, Status: http.StatusOK, Response: teamInfo{}, Errors: []int{401, 404}},
+    "DELETE /teams/{id}": {Summary: "Delete a team", Auth: authUser, Status: http.StatusNoContent, Errors: []int{401, 403, 404}},
+    "PUT /teams/{id}/members/{username}": {Summary: "Add a member or change their role", Auth: authUser, Request: memberRequest{}, Status: http.StatusOK, Response: teamInfo{}, Errors: []int{400, 401, 403, 404}},
+    "DELETE /teams/{id}/members/{username}": {Summary: "Remove a member, members may remove themselves", Auth: authUser, Status: http.StatusNoContent, Errors: []int{401, 403, 404, 409}},
+}
+
+// Endpoints served next to APIv1 that the description leaves out, listed in its info
+var otherEndpoints = []string{
+    "POST /v1/audio/transcriptions: OpenAI-compatible transcription API, see OpenAI's API reference",
+    "GET /healthz and GET /readyz: liveness and readiness probes",
+    "GET /metrics: Prometheus metrics",
+    "GET /api/openapi.json: this description",
+}
+
+// legacyAliases maps the unversioned routes that differ from APIv1 to the operations replacing
+// them, empty if there is none. All other unversioned routes are APIv1 routes without the
+// /api/v1 prefix.
+var legacyAliases = map[string]string{
+    "POST /upload":             "POST /tasks",
+    "GET /status":              "GET /tasks/{id} and GET /tasks/{id}/result",
+    "GET /tasks/{id}":          "GET /tasks/{id} and GET /tasks/{id}/result",
+    "GET /tasksInQueue":        "GET /queue",
+    "GET /get-testimonials":    "GET /testimonials",
+    "POST /submit-testimonial": "POST /testimonials",
+    "GET /counter":             "", // visitor counter of the web page
+}
+
+// OpenAPI returns a handler serving the OpenAPI 3 description of APIv1, or an error if the
+// documented operations, the legacy aliases and the routes have drifted apart
+func (h *HTTPHandler) OpenAPI() (http.HandlerFunc, error) {
+    if err := checkLegacy(h.Legacy(), h.APIv1(), legacyAliases); err != nil {
+        return nil, err
+    }
+    spec, err := buildOpenAPI(h.APIv1(), apiOperations)
+    if err != nil {
+        return nil, err
+    }
+    data, err := json.MarshalIndent(spec, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "GET" && r.Method != "HEAD" {
+            w.Header().Set("Allow", "GET")
+            writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Invalid request method")
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.Write(data)
+    }, nil
+}
+
+type object = map[string]interface{}
+
+// routeKeys returns the "METHOD /path" of every route
+func routeKeys(rt *Router) map[string]bool {
+    keys := make(map[string]bool)
+    for _, route := range rt.routes {
+        for _, method := range strings.Split(route.allow(), ", ") {
+            keys[method + " " + route.pattern] = true
+        }
+    }
+    return keys
+}
+
+// checkLegacy fails if an unversioned route is neither an APIv1 route nor in aliases, or an
+// alias has no route
+func checkLegacy(legacy *Router, api *Router, aliases map[string]string) error {
+    apiRoutes := routeKeys(api)
+    legacyRoutes := routeKeys(legacy)
+    for key := range legacyRoutes {
+        if _, ok := aliases[key]; !ok && !apiRoutes[key] {
+            return fmt.Errorf("openapi: legacy route %s is neither an APIv1 route nor a documented alias", key)
+        }
+    }
+    for key := range aliases {
+        if !legacyRoutes[key] {
+            return fmt.Errorf("openapi: legacy alias %s has no route", key)
+        }
+    }
+    return nil
+}
+
+// describeOthers lists what the description leaves out, for its info
+func describeOthers() string {
+    var b strings.Builder
+    b.WriteString("Not described here:\n")
+    for _, endpoint := range otherEndpoints {
+        b.WriteString("\n- " + endpoint)
+    }
+    b.WriteString("\n- the unversioned paths used by the web page, APIv1 routes without the /api/v1 prefix and these aliases:")
+    keys := make([]string, 0, len(legacyAliases))
+    for key := range legacyAliases {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        if legacyAliases[key] == "" {
+            b.WriteString("\n  - " + key + " (no APIv1 equivalent)")
+        } else {
+            b.WriteString("\n  - " + key + ", see " + legacyAliases[key])
+        }
+    }
+    return b.String()
+}
+
+func buildOpenAPI(rt *Router, operations map[string]operation) (object, error) {
+    schemas := newSchemaSet()
+    paths := object{}
+    documented := make(map[string]bool)
+    for _, route := range rt.routes {
+        path := "/" + strings.Join(route.segments, "/")
+        item := object{}
+        for _, method := range strings.Split(route.allow(), ", ") {
+            key := method + " " + path
+            op, ok := operations[key]
+            if !ok {
+                return nil, fmt.Errorf("openapi: route %s is not documented", key)
+            }
+            documented[key] = true
+            item[strings.ToLower(method)] = op.describe(route.segments, schemas)
+        }
+        paths[path] = item
+    }
+    for key := range operations {
+        if !documented[key] {
+            return nil, fmt.Errorf("openapi: documented operation %s has no route", key)
+        }
+    }
+
+    schemas.ref(reflect.TypeOf(types.ErrorResponse{}))
+    return object{
+        "openapi": "3.0.3",
+        "info": object{
+            "title":       "AI Meeting Summarizer API",
+            "version":     "1",
+            "description": describeOthers(),
+        },
+        "servers": []object{{"url": "/api/v1"}},
+        "paths":   paths,
+        "components": object{
+            "schemas": schemas.components,
+            "securitySchemes": object{
+                "session":   object{"type": "apiKey", "in": "cookie", "name": auth.SessionCookie},
+                "apiKey":    object{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+                "bearer":    object{"type": "http", "scheme": "bearer"},
+                "taskToken": object{"type": "apiKey", "in": "header", "name": "X-Task-Token"},
+            },
+        },
+    }, nil
+}
+
+func (op operation) describe(segments []string, schemas *schemaSet) object {
+    parameters := []object{}
+    for _, segment := range segments {
+        if strings.HasPrefix(segment, "{") {
+            parameters = append(parameters, object{"name": strings.Trim(segment, "{}"), "in": "path",
+                "required": true, "schema": object{"type": "string"}})
+        }
+    }
+    names := make([]string, 0, len(op.Query))
+    for name := range op.Query {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        parameters = append(parameters, object{"name": name, "in": "query", "schema": object{"type": op.Query[name]}})
+    }
+
+    success := object{"description": http.StatusText(op.Status)}
+    if op.Response != nil {
+        success["content"] = content(op.Response, schemas)
+    }
+    responses := object{fmt.Sprint(op.Status): success}
+    errorContent := object{"application/json": object{"schema": schemas.ref(reflect.TypeOf(types.ErrorResponse{}))}}
+    // The router answers methods a path does not support with 405
+    for _, status := range append(op.Errors, http.StatusMethodNotAllowed) {
+        responses[fmt.Sprint(status)] = object{"description": http.StatusText(status), "content": errorContent}
+    }
+
+    described := object{"summary": op.Summary, "parameters": parameters, "responses": responses}
+    if op.Request != nil {
+        described["requestBody"] = object{"required": true, "content": content(op.Request, schemas)}
+    }
+    switch op.Auth {
+    case authUser, authAdmin:
+        described["security"] = []object{{"session": []string{}}, {"apiKey": []string{}}, {"bearer": []string{}}}
+    case authTask:
+        described["security"] = []object{{"session": []string{}}, {"apiKey": []string{}}, {"bearer": []string{}}, {"taskToken": []string{}}}
+    }
+    return described
+}
+
+// content describes a request or response body of the type of v
+func content(v interface{}, schemas *schemaSet) object {
+    switch v.(type) {
+    case multipartUpload:
+        properties := object{"file": object{"type": "string", "format": "binary", "description": ".wav or .mp4 recording"}}
+        for _, field := range []string{"template", "mode", "title", "date", "attendees", "ttl", "team"} {
+            properties[field] = object{"type": "string"}
+        }
+        return object{"multipart/form-data": object{"schema": object{"type": "object", "properties": properties, "required": []string{"file"}}}}
+    case plainText:
+        return object{"text/plain": object{"schema": object{"type": "string"}}}
+    case eventStream:
+        return object{"text/event-stream": object{"schema": object{"type": "string",
+            "description": "events: conversation (ID), token (generated text), done (full reply) or error"}}}
+    }
+    return object{"application/json": object{"schema": schemas.ref(reflect.TypeOf(v))}}
+}
+
+// schemaSet derives JSON schemas from Go types the way encoding/json encodes them, named
+// struct types become shared components
+type schemaSet struct {
+    components object
+    names      map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+    return &schemaSet{components: object{}, names: make(map[reflect.Type]string)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ref returns the schema of t, a reference for named structs
+func (s *schemaSet) ref(t reflect.Type) object {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    switch {
+    case t == timeType:
+        return object{"type": "string", "format": "date-time"}
+    case t.Kind() == reflect.Struct && t.Name() != "":
+        name, ok := s.names[t]
+        if !ok {
+            name = s.componentName(t)
+            s.names[t] = name
+            s.components[name] = object{} // placeholder for recursive types
+            s.components[name] = s.structSchema(t)
+        }
+        return object{"$ref": "#/components/schemas/" + name}
+    case t.Kind() == reflect.Struct:
+        return s.structSchema(t)
+    }
+
+    switch t.Kind() {
+    case reflect.Bool:
+        return object{"type": "boolean"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return object{"type": "integer"}
+    case reflect.Float32, reflect.Float64:
+        return object{"type": "number"}
+    case reflect.String:
+        return object{"type": "string"}
+    case reflect.Slice: // nil slices and maps are encoded as null
+        return object{"type": "array", "items": s.ref(t.Elem()), "nullable": true}
+    case reflect.Array:
+        return object{"type": "array", "items": s.ref(t.Elem())}
+    case reflect.Map:
+        return object{"type": "object", "additionalProperties": s.ref(t.Elem()), "nullable": true}
+    }
+    return object{}
+}
+
+// componentName is the type's name, prefixed with its package if another package already took it
+func (s *schemaSet) componentName(t reflect.Type) string {
+    name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+    if _, taken := s.components[name]; !taken {
+        return name
+    }
+    pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+    return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+func (s *schemaSet) structSchema(t reflect.Type) object {
+    properties := object{}
+    required := []string{}
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if field.PkgPath != "" {
+            continue // unexported
+        }
+        tag := field.Tag.Get("json")
+        if tag == "-" {
+            continue
+        }
+        name, options, _ := strings.Cut(tag, ",")
+        if name == "" {
+            name = field.Name
+        }
+        properties[name] = s.ref(field.Type)
+        if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+            required = append(required, name)
+        }
+    }
+    schema := object{"type": "object", "properties": properties}
+    if len(required) > 0 {
+        schema["required"] = required
+    }
+    return schema
+}
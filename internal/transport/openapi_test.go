@@ -0,0 +1,484 @@
+package transport
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/archive"
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+    "github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+    "github.com/stanek-michal/go-ai-summarizer/internal/tasklog"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// apiTest serves APIv1 from temporary directories and checks every response against the
+// OpenAPI description
+type apiTest struct {
+    t      *testing.T
+    h      *HTTPHandler
+    server http.Handler
+    spec   map[string]interface{}
+    called map[string]bool // "METHOD /pattern" of the operations called
+}
+
+func newAPITest(t *testing.T) *apiTest {
+    t.Helper()
+    dir := t.TempDir()
+    t.Setenv("TMPDIR", dir) // uploads
+
+    // The testimonials are read from the working directory
+    if err := os.MkdirAll(filepath.Join(dir, "web"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "web", "testimonials.json"), []byte(`["Nice"]`), 0644); err != nil {
+        t.Fatal(err)
+    }
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.Chdir(wd) })
+
+    templates, err := prompts.NewStore(filepath.Join(dir, "templates"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := templates.Create(prompts.DefaultTemplate, "Summarize the meeting."); err != nil {
+        t.Fatal(err)
+    }
+    registry, err := speakers.NewRegistry(filepath.Join(dir, "speakers", "registry.json"), 0.7)
+    if err != nil {
+        t.Fatal(err)
+    }
+    meetings, err := archive.Open(filepath.Join(dir, "archive"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    logs, err := tasklog.NewStore(filepath.Join(dir, "logs"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    llm := processing.NewLLM(0, processing.DefaultLlamaPort)
+    // Nothing is processed, uploads stay waiting
+    q := queue.NewQueue(processing.NewProcessor(registry, llm), templates, meetings, queue.RetentionPolicy{}, logs)
+
+    h := NewHTTPHandler(q, templates, registry, meetings, llm, nil)
+    if h.Users, err = auth.NewUsers(filepath.Join(dir, "users", "users.json")); err != nil {
+        t.Fatal(err)
+    }
+    if h.Teams, err = teams.NewTeams(filepath.Join(dir, "users", "teams.json")); err != nil {
+        t.Fatal(err)
+    }
+    h.Sessions = auth.NewSessions(h.Users)
+    h.AllowSignup = true
+
+    spec, err := buildOpenAPI(h.APIv1(), apiOperations)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Compared in its JSON form, like clients see it
+    data, err := json.Marshal(spec)
+    if err != nil {
+        t.Fatal(err)
+    }
+    a := &apiTest{t: t, h: h, called: make(map[string]bool)}
+    if err := json.Unmarshal(data, &a.spec); err != nil {
+        t.Fatal(err)
+    }
+    a.server = auth.Middleware(auth.Chain{h.Sessions, auth.APIKeyAuthenticator{Users: h.Users}}, h.APIv1())
+    return a
+}
+
+// user creates an account and returns an API key of it
+func (a *apiTest) user(name string) (*auth.User, string) {
+    a.t.Helper()
+    user, err := a.h.Users.Create(name, "correct horse", false)
+    if err != nil {
+        a.t.Fatal(err)
+    }
+    key, _, err := a.h.Users.CreateAPIKey(user.ID, "test")
+    if err != nil {
+        a.t.Fatal(err)
+    }
+    return user, key
+}
+
+// call sends a request for the operation "METHOD /pattern" to path, as the owner of apiKey
+// (anonymous if empty), and checks the status and the response against the description.
+// body is JSON encoded unless it is a string (plain text) or an upload.
+func (a *apiTest) call(operation string, path string, apiKey string, body interface{}, want int) []byte {
+    a.t.Helper()
+    method, pattern, _ := strings.Cut(operation, " ")
+    var reader io.Reader
+    contentType := "application/json"
+    switch body := body.(type) {
+    case nil:
+    case string:
+        reader, contentType = strings.NewReader(body), "text/plain"
+    case upload:
+        reader, contentType = bytes.NewReader(body.data), body.contentType
+    default:
+        data, err := json.Marshal(body)
+        if err != nil {
+            a.t.Fatal(err)
+        }
+        reader = bytes.NewReader(data)
+    }
+    r := httptest.NewRequest(method, path, reader)
+    if reader != nil {
+        r.Header.Set("Content-Type", contentType)
+    }
+    if apiKey != "" {
+        r.Header.Set("X-API-Key", apiKey)
+    }
+    w := httptest.NewRecorder()
+    a.server.ServeHTTP(w, r)
+
+    a.called[operation] = true
+    if w.Code != want {
+        a.t.Errorf("%s %s: status %d, want %d: %s", method, path, w.Code, want, w.Body.String())
+    }
+    if err := a.checkResponse(method, pattern, w); err != nil {
+        a.t.Errorf("%s %s: %v", method, path, err)
+    }
+    return w.Body.Bytes()
+}
+
+// checkResponse checks that the status is documented for the operation and the body fits its schema
+func (a *apiTest) checkResponse(method string, pattern string, w *httptest.ResponseRecorder) error {
+    item, _ := a.spec["paths"].(map[string]interface{})[pattern].(map[string]interface{})
+    op, ok := item[strings.ToLower(method)].(map[string]interface{})
+    if !ok {
+        return fmt.Errorf("operation %s %s is not described", method, pattern)
+    }
+    response, ok := op["responses"].(map[string]interface{})[strconv.Itoa(w.Code)].(map[string]interface{})
+    if !ok {
+        return fmt.Errorf("status %d is not documented", w.Code)
+    }
+    content, ok := response["content"].(map[string]interface{})
+    if !ok {
+        if w.Body.Len() > 0 {
+            return fmt.Errorf("status %d has no body, got %q", w.Code, w.Body.String())
+        }
+        return nil
+    }
+    for mediaType, media := range content {
+        if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, mediaType) {
+            return fmt.Errorf("Content-Type %q, want %s", got, mediaType)
+        }
+        if mediaType != "application/json" {
+            return nil
+        }
+        var value interface{}
+        if err := json.Unmarshal(w.Body.Bytes(), &value); err != nil {
+            return fmt.Errorf("invalid JSON body: %v", err)
+        }
+        return a.validate(media.(map[string]interface{})["schema"].(map[string]interface{}), value, "body")
+    }
+    return nil
+}
+
+// validate checks a decoded JSON value against a schema of the description. Properties that
+// are not described are errors too, so the description cannot miss fields.
+func (a *apiTest) validate(schema map[string]interface{}, value interface{}, at string) error {
+    if ref, ok := schema["$ref"].(string); ok {
+        components := a.spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+        schema, ok = components[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+        if !ok {
+            return fmt.Errorf("%s: unknown schema %s", at, ref)
+        }
+    }
+    if value == nil {
+        if schema["nullable"] == true {
+            return nil
+        }
+        return fmt.Errorf("%s: null is not allowed", at)
+    }
+
+    switch schema["type"] {
+    case "object":
+        fields, ok := value.(map[string]interface{})
+        if !ok {
+            return fmt.Errorf("%s: %v is not an object", at, value)
+        }
+        required, _ := schema["required"].([]interface{})
+        for _, name := range required {
+            if _, ok := fields[name.(string)]; !ok {
+                return fmt.Errorf("%s: missing required property %s", at, name)
+            }
+        }
+        properties, _ := schema["properties"].(map[string]interface{})
+        additional, _ := schema["additionalProperties"].(map[string]interface{})
+        for name, field := range fields {
+            property, ok := properties[name].(map[string]interface{})
+            if !ok {
+                property = additional
+            }
+            if property == nil {
+                return fmt.Errorf("%s: property %s is not described", at, name)
+            }
+            if err := a.validate(property, field, at + "." + name); err != nil {
+                return err
+            }
+        }
+    case "array":
+        items, ok := value.([]interface{})
+        if !ok {
+            return fmt.Errorf("%s: %v is not an array", at, value)
+        }
+        for i, item := range items {
+            if err := a.validate(schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+                return err
+            }
+        }
+    case "string":
+        s, ok := value.(string)
+        if !ok {
+            return fmt.Errorf("%s: %v is not a string", at, value)
+        }
+        if schema["format"] == "date-time" {
+            if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+                return fmt.Errorf("%s: %q is not a date-time", at, s)
+            }
+        }
+    case "integer":
+        if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+            return fmt.Errorf("%s: %v is not an integer", at, value)
+        }
+    case "number":
+        if _, ok := value.(float64); !ok {
+            return fmt.Errorf("%s: %v is not a number", at, value)
+        }
+    case "boolean":
+        if _, ok := value.(bool); !ok {
+            return fmt.Errorf("%s: %v is not a boolean", at, value)
+        }
+    }
+    return nil
+}
+
+// upload is a multipart/form-data request body
+type upload struct {
+    data        []byte
+    contentType string
+}
+
+func newUpload(t *testing.T, fileName string, fields map[string]string) upload {
+    t.Helper()
+    return newFileUpload(t, fileName, []byte("RIFF not really a recording"), fields)
+}
+
+// newFileUpload is newUpload of a file with the given content
+func newFileUpload(t *testing.T, fileName string, content []byte, fields map[string]string) upload {
+    t.Helper()
+    var buf bytes.Buffer
+    form := multipart.NewWriter(&buf)
+    for name, value := range fields {
+        form.WriteField(name, value)
+    }
+    file, err := form.CreateFormFile("file", fileName)
+    if err != nil {
+        t.Fatal(err)
+    }
+    file.Write(content)
+    if err := form.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return upload{data: buf.Bytes(), contentType: form.FormDataContentType()}
+}
+
+func TestAPIMatchesDescription(t *testing.T) {
+    a := newAPITest(t)
+    ana, anaKey := a.user("ana") // the first account, an admin
+    _, bobKey := a.user("bob")
+
+    // A finished meeting, only left in the archive
+    created := time.Now().Add(-time.Hour)
+    done := types.Task{ID: "done", OwnerID: ana.ID, Status: "completed", CreatedAt: created,
+        Meeting: types.Meeting{Title: "Planning", Attendees: []string{"Ana", "Bob"}},
+        Result: types.Result{
+            Transcript: "WEBVTT\n\n00:00.000 --> 00:05.000\n[SPEAKER_00]: The rollout moves to March.\n\n" +
+                "00:04.000 --> 00:09.000\n[SPEAKER_01]: March works.\n\n",
+            Summary:     "The rollout moves to March.",
+            Summaries:   []types.Summary{{ID: "1", Template: prompts.DefaultTemplate, TemplateVersion: 1, Status: "completed", Text: "The rollout moves to March."}},
+            ActionItems: []types.ActionItem{{Owner: "SPEAKER_00", Task: "Announce the date", Timestamp: "00:00:00"}},
+        }}
+    if err := a.h.Archive.Save(done); err != nil {
+        t.Fatal(err)
+    }
+
+    // Accounts
+    a.call("POST /auth/signup", "/auth/signup", "", credentials{Username: "cid", Password: "correct horse"}, http.StatusCreated)
+    a.call("POST /auth/signup", "/auth/signup", "", credentials{Username: "cid", Password: "correct horse"}, http.StatusConflict)
+    a.call("POST /auth/signup", "/auth/signup", "", credentials{Username: "dan", Password: "short"}, http.StatusBadRequest)
+    a.call("POST /auth/login", "/auth/login", "", credentials{Username: "cid", Password: "correct horse"}, http.StatusOK)
+    a.call("POST /auth/login", "/auth/login", "", credentials{Username: "cid", Password: "wrong horse"}, http.StatusUnauthorized)
+    a.call("POST /auth/logout", "/auth/logout", "", nil, http.StatusNoContent)
+    a.call("GET /auth/me", "/auth/me", anaKey, nil, http.StatusOK)
+    a.call("GET /auth/me", "/auth/me", "", nil, http.StatusUnauthorized)
+    a.call("GET /auth/keys", "/auth/keys", anaKey, nil, http.StatusOK)
+    var key createdAPIKey
+    json.Unmarshal(a.call("POST /auth/keys", "/auth/keys", anaKey, apiKeyRequest{Name: "nightly"}, http.StatusCreated), &key)
+    if key.APIKey == nil {
+        t.Fatal("no API key created")
+    }
+    a.call("DELETE /auth/keys/{id}", "/auth/keys/" + key.APIKey.ID, anaKey, nil, http.StatusNoContent)
+    a.call("DELETE /auth/keys/{id}", "/auth/keys/" + key.APIKey.ID, anaKey, nil, http.StatusNotFound)
+
+    // Teams
+    var team teamInfo
+    json.Unmarshal(a.call("POST /teams", "/teams", anaKey, teamRequest{Name: "Engineering"}, http.StatusCreated), &team)
+    a.call("POST /teams", "/teams", anaKey, teamRequest{Name: " "}, http.StatusBadRequest)
+    a.call("GET /teams", "/teams", anaKey, nil, http.StatusOK)
+    a.call("GET /teams/{id}", "/teams/" + team.ID, anaKey, nil, http.StatusOK)
+    a.call("GET /teams/{id}", "/teams/" + team.ID, bobKey, nil, http.StatusNotFound)
+    a.call("PUT /teams/{id}/members/{username}", "/teams/" + team.ID + "/members/bob", anaKey, memberRequest{Role: teams.Viewer}, http.StatusOK)
+    a.call("PUT /teams/{id}/members/{username}", "/teams/" + team.ID + "/members/bob", anaKey, memberRequest{Role: "owner"}, http.StatusBadRequest)
+    a.call("PUT /teams/{id}/members/{username}", "/teams/" + team.ID + "/members/bob", bobKey, memberRequest{Role: teams.Admin}, http.StatusForbidden)
+    a.call("DELETE /teams/{id}/members/{username}", "/teams/" + team.ID + "/members/ana", anaKey, nil, http.StatusConflict)
+    a.call("DELETE /teams/{id}", "/teams/" + team.ID, bobKey, nil, http.StatusForbidden)
+
+    // Uploads and tasks
+    var uploaded uploadResponse
+    json.Unmarshal(a.call("POST /tasks", "/tasks", anaKey, newUpload(t, "meeting.wav", map[string]string{"title": "Standup", "team": team.ID}), http.StatusAccepted), &uploaded)
+    a.call("POST /tasks", "/tasks", anaKey, newUpload(t, "meeting.txt", nil), http.StatusBadRequest)
+    a.call("POST /tasks", "/tasks", bobKey, newUpload(t, "meeting.wav", map[string]string{"team": team.ID}), http.StatusForbidden)
+    a.call("POST /tasks", "/tasks", "", newUpload(t, "meeting.wav", nil), http.StatusUnauthorized)
+    a.call("GET /tasks", "/tasks", anaKey, nil, http.StatusOK)
+    a.call("GET /tasks", "/tasks", "", nil, http.StatusUnauthorized)
+    a.call("GET /tasks/{id}", "/tasks/" + uploaded.TaskID, anaKey, nil, http.StatusOK)
+    a.call("GET /tasks/{id}", "/tasks/done", "", nil, http.StatusNotFound)
+    a.call("GET /tasks/{id}", "/tasks/done", anaKey, nil, http.StatusOK)
+    a.call("GET /tasks/{id}/result", "/tasks/done/result", anaKey, nil, http.StatusOK)
+    a.call("GET /tasks/{id}/result", "/tasks/" + uploaded.TaskID + "/result", anaKey, nil, http.StatusConflict)
+    a.call("GET /tasks/{id}/logs", "/tasks/" + uploaded.TaskID + "/logs", anaKey, nil, http.StatusOK)
+    a.call("GET /tasks/{id}/logs", "/tasks/done/logs", anaKey, nil, http.StatusNotFound)
+    a.call("GET /tasks/{id}/summaries", "/tasks/done/summaries", anaKey, nil, http.StatusOK)
+    a.call("POST /tasks/{id}/summaries", "/tasks/" + uploaded.TaskID + "/summaries", anaKey, summaryRequest{Template: prompts.DefaultTemplate}, http.StatusConflict)
+    a.call("POST /tasks/{id}/summaries", "/tasks/" + uploaded.TaskID + "/summaries", bobKey, summaryRequest{}, http.StatusForbidden)
+    a.call("GET /tasks/{id}/summaries/{summary}", "/tasks/done/summaries/1", anaKey, nil, http.StatusOK)
+    a.call("GET /tasks/{id}/summaries/{summary}", "/tasks/done/summaries/2", anaKey, nil, http.StatusNotFound)
+    a.call("GET /tasks/{id}/speakers", "/tasks/done/speakers", anaKey, nil, http.StatusOK)
+    a.call("PUT /tasks/{id}/speakers", "/tasks/" + uploaded.TaskID + "/speakers", anaKey, renameRequest{Names: map[string]string{"SPEAKER_00": "Ana"}}, http.StatusConflict)
+    a.call("PUT /tasks/{id}/speakers", "/tasks/" + uploaded.TaskID + "/speakers", anaKey, renameRequest{}, http.StatusBadRequest)
+    a.call("GET /tasks/{id}/analytics", "/tasks/done/analytics", anaKey, nil, http.StatusOK)
+    a.call("GET /tasks/{id}/analytics", "/tasks/" + uploaded.TaskID + "/analytics", anaKey, nil, http.StatusConflict)
+    a.call("POST /tasks/{id}/chat", "/tasks/done/chat", anaKey, chatRequest{Message: " "}, http.StatusBadRequest)
+    a.call("POST /tasks/{id}/chat", "/tasks/" + uploaded.TaskID + "/chat", anaKey, chatRequest{Message: "When?"}, http.StatusConflict)
+    a.call("PUT /tasks/{id}/team", "/tasks/" + uploaded.TaskID + "/team", bobKey, taskTeamRequest{}, http.StatusForbidden)
+    a.call("PUT /tasks/{id}/team", "/tasks/" + uploaded.TaskID + "/team", anaKey, taskTeamRequest{}, http.StatusOK)
+    a.call("GET /queue", "/queue", "", nil, http.StatusOK)
+
+    // Shared resources, changed only by admins
+    a.call("GET /templates", "/templates", bobKey, nil, http.StatusOK)
+    a.call("POST /templates", "/templates", bobKey, templateRequest{Name: "brief", Body: "Be brief."}, http.StatusForbidden)
+    a.call("POST /templates", "/templates", anaKey, templateRequest{Name: "brief", Body: "Be brief."}, http.StatusCreated)
+    a.call("POST /templates", "/templates", anaKey, templateRequest{Name: "brief", Body: "Be brief."}, http.StatusConflict)
+    a.call("PUT /templates/{name}", "/templates/brief", anaKey, templateRequest{Body: "Be very brief."}, http.StatusOK)
+    a.call("PUT /templates/{name}", "/templates/brief", anaKey, templateRequest{Body: "{{.Nope"}, http.StatusBadRequest)
+    a.call("GET /templates/{name}", "/templates/brief?version=1", bobKey, nil, http.StatusOK)
+    a.call("GET /templates/{name}", "/templates/brief?version=x", bobKey, nil, http.StatusBadRequest)
+    a.call("GET /templates/{name}/versions", "/templates/brief/versions", bobKey, nil, http.StatusOK)
+    a.call("DELETE /templates/{name}", "/templates/brief", anaKey, nil, http.StatusNoContent)
+    a.call("DELETE /templates/{name}", "/templates/" + prompts.DefaultTemplate, anaKey, nil, http.StatusConflict)
+    a.call("GET /templates/{name}/versions", "/templates/brief/versions", bobKey, nil, http.StatusNotFound)
+    a.call("POST /speakers", "/speakers", anaKey, enrollRequest{Name: "Ana", Embedding: []float64{0.1, 0.2}}, http.StatusCreated)
+    a.call("POST /speakers", "/speakers", anaKey, enrollRequest{Name: "Bob", TaskID: "done", Label: "SPEAKER_01"}, http.StatusNotFound)
+    a.call("POST /speakers", "/speakers", bobKey, enrollRequest{Name: "Bob", Embedding: []float64{0.2, 0.1}}, http.StatusForbidden)
+    a.call("GET /speakers", "/speakers", bobKey, nil, http.StatusOK)
+    a.call("DELETE /speakers/{name}", "/speakers/Ana", anaKey, nil, http.StatusNoContent)
+    a.call("DELETE /speakers/{name}", "/speakers/Ana", anaKey, nil, http.StatusNotFound)
+
+    // Archive, search and Q&A
+    a.call("GET /archive", "/archive", anaKey, nil, http.StatusOK)
+    a.call("GET /archive", "/archive", "", nil, http.StatusUnauthorized)
+    a.call("GET /archive/{id}", "/archive/done", anaKey, nil, http.StatusOK)
+    a.call("GET /archive/{id}", "/archive/done", bobKey, nil, http.StatusNotFound)
+    a.call("GET /search", "/search?q=rollout&limit=5", anaKey, nil, http.StatusOK)
+    a.call("GET /search", "/search", anaKey, nil, http.StatusBadRequest)
+    a.call("POST /ask", "/ask", anaKey, askRequest{Question: "When is the rollout?"}, http.StatusServiceUnavailable)
+
+    // Testimonials
+    a.call("GET /testimonials", "/testimonials", "", nil, http.StatusOK)
+    a.call("POST /testimonials", "/testimonials", "", "Great tool", http.StatusOK)
+    a.call("POST /testimonials", "/testimonials", "", " ", http.StatusBadRequest)
+
+    // Deleting
+    a.call("DELETE /teams/{id}/members/{username}", "/teams/" + team.ID + "/members/bob", bobKey, nil, http.StatusNoContent)
+    a.call("DELETE /teams/{id}", "/teams/" + team.ID, anaKey, nil, http.StatusNoContent)
+    a.call("DELETE /tasks/{id}", "/tasks/done", bobKey, nil, http.StatusNotFound)
+    a.call("DELETE /tasks/{id}", "/tasks/done", anaKey, nil, http.StatusNoContent)
+    a.call("DELETE /tasks/{id}", "/tasks/" + uploaded.TaskID, anaKey, nil, http.StatusNoContent)
+
+    // Other methods of a path
+    for _, path := range []string{"/tasks", "/tasks/x/result", "/templates/brief"} {
+        r := httptest.NewRequest("PATCH", path, nil)
+        w := httptest.NewRecorder()
+        a.server.ServeHTTP(w, r)
+        if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+            t.Errorf("PATCH %s: status %d, Allow %q, want 405 with the allowed methods", path, w.Code, w.Header().Get("Allow"))
+        }
+        pattern := path
+        if strings.HasPrefix(path, "/tasks/") {
+            pattern = "/tasks/{id}/result"
+        } else if strings.HasPrefix(path, "/templates/") {
+            pattern = "/templates/{name}"
+        }
+        if err := a.checkResponse("GET", pattern, w); err != nil {
+            t.Errorf("PATCH %s: %v", path, err)
+        }
+    }
+
+    for operation := range apiOperations {
+        if !a.called[operation] {
+            t.Errorf("%s was not called", operation)
+        }
+    }
+}
+
+func TestLegacyRoutesAreDescribed(t *testing.T) {
+    h := &HTTPHandler{}
+    if err := checkLegacy(h.Legacy(), h.APIv1(), legacyAliases); err != nil {
+        t.Fatal(err)
+    }
+    aliases := map[string]string{"GET /status": "GET /tasks/{id}"}
+    if err := checkLegacy(h.Legacy(), h.APIv1(), aliases); err == nil {
+        t.Error("a legacy route missing from the aliases was accepted")
+    }
+    aliases = map[string]string{"GET /gone": ""}
+    for key, value := range legacyAliases {
+        aliases[key] = value
+    }
+    if err := checkLegacy(h.Legacy(), h.APIv1(), aliases); err == nil {
+        t.Error("an alias without a route was accepted")
+    }
+
+    spec, err := buildOpenAPI(h.APIv1(), apiOperations)
+    if err != nil {
+        t.Fatal(err)
+    }
+    description := spec["info"].(object)["description"].(string)
+    for _, endpoint := range []string{"/v1/audio/transcriptions", "/healthz", "/readyz", "/metrics", "/api/openapi.json", "POST /upload", "GET /counter"} {
+        if !strings.Contains(description, endpoint) {
+            t.Errorf("the description does not mention %s", endpoint)
+        }
+    }
+}
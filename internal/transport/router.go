@@ -0,0 +1,123 @@
+package transport
+
+import (
+    "context"
+    "net/http"
+    "sort"
+    "strings"
+
+    semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// Router dispatches requests by path pattern and method. Patterns are slash-separated,
+// segments in braces ("/tasks/{id}/summaries") match any single non-empty segment and are
+// available to handlers with pathParam. Unknown paths get a JSON 404 (or go to NotFound),
+// known paths with another method a JSON 405 with the Allow header. The span of a request
+// (if it is traced) is named after the matched pattern.
+type Router struct {
+    NotFound http.Handler // optional fallback for unknown paths
+    routes   []*route
+}
+
+type route struct {
+    pattern  string
+    segments []string
+    handlers map[string]http.HandlerFunc // by method
+}
+
+type paramsKey struct{}
+
+func NewRouter() *Router {
+    return &Router{}
+}
+
+// Handle registers handler for the given methods of a path pattern
+func (rt *Router) Handle(pattern string, handler http.HandlerFunc, methods ...string) {
+    segments := splitPath(pattern)
+    var target *route
+    for _, existing := range rt.routes {
+        if strings.Join(existing.segments, "/") == strings.Join(segments, "/") {
+            target = existing
+            break
+        }
+    }
+    if target == nil {
+        target = &route{pattern: "/" + strings.Join(segments, "/"), segments: segments, handlers: make(map[string]http.HandlerFunc)}
+        rt.routes = append(rt.routes, target)
+    }
+    for _, method := range methods {
+        target.handlers[method] = handler
+    }
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    segments := splitPath(r.URL.Path)
+    for _, route := range rt.routes {
+        params, ok := route.match(segments)
+        if !ok {
+            continue
+        }
+        span := trace.SpanFromContext(r.Context())
+        span.SetName(r.Method + " " + route.pattern)
+        span.SetAttributes(semconv.HTTPRoute(route.pattern))
+        handler, ok := route.handlers[r.Method]
+        if !ok && r.Method == "HEAD" {
+            handler, ok = route.handlers["GET"]
+        }
+        if !ok {
+            w.Header().Set("Allow", route.allow())
+            writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method "+r.Method+" is not allowed here")
+            return
+        }
+        handler(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+        return
+    }
+    if rt.NotFound != nil {
+        rt.NotFound.ServeHTTP(w, r)
+        return
+    }
+    writeError(w, http.StatusNotFound, "not_found", "Not found")
+}
+
+// match returns the values of the pattern's parameters if the path segments fit it
+func (rt *route) match(segments []string) (map[string]string, bool) {
+    if len(segments) != len(rt.segments) {
+        return nil, false
+    }
+    params := make(map[string]string)
+    for i, segment := range rt.segments {
+        if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+            if segments[i] == "" {
+                return nil, false
+            }
+            params[segment[1:len(segment)-1]] = segments[i]
+        } else if segment != segments[i] {
+            return nil, false
+        }
+    }
+    return params, true
+}
+
+func (rt *route) allow() string {
+    methods := make([]string, 0, len(rt.handlers))
+    for method := range rt.handlers {
+        methods = append(methods, method)
+    }
+    sort.Strings(methods)
+    return strings.Join(methods, ", ")
+}
+
+// pathParam returns a parameter of the matched route pattern, empty outside a Router
+func pathParam(r *http.Request, name string) string {
+    params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+    return params[name]
+}
+
+func splitPath(path string) []string {
+    path = strings.Trim(path, "/")
+    if path == "" {
+        return nil
+    }
+    return strings.Split(path, "/")
+}
@@ -0,0 +1,44 @@
+package transport
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+
+    tests := []struct {
+        method string
+        path   string
+        allow  string
+    }{
+        {"PUT", "/templates", "GET, POST"},
+        {"DELETE", "/speakers", "GET, POST"},
+        {"PUT", "/teams", "GET, POST"},
+        {"DELETE", "/auth/keys", "GET, POST"},
+        {"GET", "/auth/login", "POST"},
+        {"GET", "/auth/signup", "POST"},
+        {"GET", "/auth/logout", "POST"},
+        {"POST", "/auth/me", "GET"},
+        {"GET", "/ask", "POST"},
+        {"POST", "/search", "GET"},
+        {"PUT", "/testimonials", "GET, POST"},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest(tt.method, tt.path, nil)
+        r.Header.Set("X-API-Key", anaKey)
+        w := httptest.NewRecorder()
+        a.server.ServeHTTP(w, r)
+        var body types.ErrorResponse
+        json.Unmarshal(w.Body.Bytes(), &body)
+        if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != tt.allow || body.Error.Code != "method_not_allowed" {
+            t.Errorf("%s %s: status %d, Allow %q, body %s, want 405 with Allow %q", tt.method, tt.path, w.Code, w.Header().Get("Allow"), w.Body.String(), tt.allow)
+        }
+    }
+}
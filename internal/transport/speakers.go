@@ -0,0 +1,94 @@
+package transport
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+)
+
+// Enrolled speakers are listed without their voice prints
+type speakerInfo struct {
+    Name        string
+    Enrollments int
+}
+
+// enrollRequest enrolls a speaker from a task's speaker label or from a raw embedding
+type enrollRequest struct {
+    Name      string    `json:"name"`
+    TaskID    string    `json:"task_id"`
+    Token     string    `json:"token"` // access token of the task, not needed for own tasks
+    Label     string    `json:"label"`
+    Embedding []float64 `json:"embedding"`
+}
+
+// listSpeakers serves GET /speakers: the enrolled speakers of the voice-print registry
+func (h *HTTPHandler) listSpeakers(w http.ResponseWriter, r *http.Request) {
+    enrolled := h.Speakers.List()
+    infos := make([]speakerInfo, 0, len(enrolled))
+    for _, speaker := range enrolled {
+        infos = append(infos, speakerInfo{Name: speaker.Name, Enrollments: len(speaker.Embeddings)})
+    }
+    writeJSON(w, http.StatusOK, infos)
+}
+
+// enrollSpeaker serves POST /speakers: enrolls a speaker either from a task's speaker label
+// or from a raw embedding
+func (h *HTTPHandler) enrollSpeaker(w http.ResponseWriter, r *http.Request) {
+    var req enrollRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+
+    embedding := req.Embedding
+    if req.TaskID != "" {
+        if h.checkTask(userID(r), req.TaskID, req.Token, teams.Read) != http.StatusOK {
+            writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+            return
+        }
+        var err error
+        embedding, err = h.Queue.SpeakerEmbedding(req.TaskID, req.Label)
+        switch err {
+        case nil:
+        case queue.ErrTaskNotFound:
+            writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+            return
+        case queue.ErrUnknownSpeaker:
+            writeError(w, http.StatusNotFound, "unknown_speaker", "No voice embedding for this speaker label")
+            return
+        default:
+            writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+            return
+        }
+    }
+
+    speaker, err := h.Speakers.Enroll(req.Name, embedding)
+    switch err {
+    case nil:
+    case speakers.ErrInvalidName:
+        writeError(w, http.StatusBadRequest, "invalid_speaker_name", "Invalid speaker name")
+        return
+    case speakers.ErrInvalidEmbedding:
+        writeError(w, http.StatusBadRequest, "invalid_embedding", "Invalid embedding")
+        return
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    writeJSON(w, http.StatusCreated, speakerInfo{Name: speaker.Name, Enrollments: len(speaker.Embeddings)})
+}
+
+// HandleEnrolledSpeaker serves DELETE /speakers/{name}
+func (h *HTTPHandler) HandleEnrolledSpeaker(w http.ResponseWriter, r *http.Request) {
+    switch err := h.Speakers.Delete(pathParam(r, "name")); err {
+    case nil:
+        w.WriteHeader(http.StatusNoContent)
+    case speakers.ErrNotFound:
+        writeError(w, http.StatusNotFound, "speaker_not_found", "Speaker not found")
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+    }
+}
@@ -0,0 +1,214 @@
+package transport
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/teams"
+)
+
+// Team as returned by the API, with members listed by username
+type teamInfo struct {
+    ID      string
+    Name    string
+    Role    teams.Role            // role of the requesting user
+    Members map[string]teams.Role `json:",omitempty"` // username -> role
+}
+
+type teamRequest struct {
+    Name string `json:"name"`
+}
+
+type memberRequest struct {
+    Role teams.Role `json:"role"`
+}
+
+// taskTeamRequest shares a task with a team, an empty TeamID unshares it
+type taskTeamRequest struct {
+    TeamID string `json:"team_id"`
+}
+
+// listTeams serves GET /teams: the teams of the user
+func (h *HTTPHandler) listTeams(w http.ResponseWriter, r *http.Request) {
+    user := userID(r)
+    infos := []teamInfo{}
+    for _, team := range h.Teams.List(user) {
+        infos = append(infos, teamInfo{ID: team.ID, Name: team.Name, Role: team.Members[user]})
+    }
+    writeJSON(w, http.StatusOK, infos)
+}
+
+// createTeam serves POST /teams: creates a team with the user as admin
+func (h *HTTPHandler) createTeam(w http.ResponseWriter, r *http.Request) {
+    user := userID(r)
+    var req teamRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    team, err := h.Teams.Create(req.Name, user)
+    if err == teams.ErrInvalidName {
+        writeError(w, http.StatusBadRequest, "invalid_team_name", "Invalid team name")
+        return
+    } else if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    writeJSON(w, http.StatusCreated, h.teamInfo(team, user))
+}
+
+// memberTeam returns the team of the request's {id} if the user is a member, otherwise it
+// responds with 404 so team IDs cannot be probed
+func (h *HTTPHandler) memberTeam(w http.ResponseWriter, r *http.Request) (*teams.Team, bool) {
+    team, err := h.Teams.Get(pathParam(r, "id"))
+    if err != nil || team.Members[userID(r)] == "" {
+        writeError(w, http.StatusNotFound, "team_not_found", "Team not found")
+        return nil, false
+    }
+    return team, true
+}
+
+// getTeam serves GET /teams/{id}: the team and its members, for members
+func (h *HTTPHandler) getTeam(w http.ResponseWriter, r *http.Request) {
+    team, ok := h.memberTeam(w, r)
+    if !ok {
+        return
+    }
+    writeJSON(w, http.StatusOK, h.teamInfo(team, userID(r)))
+}
+
+// deleteTeam serves DELETE /teams/{id}, for team admins
+func (h *HTTPHandler) deleteTeam(w http.ResponseWriter, r *http.Request) {
+    team, ok := h.memberTeam(w, r)
+    if !ok {
+        return
+    }
+    if team.Members[userID(r)] != teams.Admin {
+        writeError(w, http.StatusForbidden, "forbidden", "Only team admins can delete the team")
+        return
+    }
+    if err := h.Teams.Delete(team.ID); err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// teamMember returns the team of the request's {id} and the user named by {username}. Team
+// admins manage all members, others may only remove themselves (if self is true).
+func (h *HTTPHandler) teamMember(w http.ResponseWriter, r *http.Request, self bool) (*teams.Team, *auth.User, bool) {
+    team, ok := h.memberTeam(w, r)
+    if !ok {
+        return nil, nil, false
+    }
+    member, err := h.Users.GetByName(pathParam(r, "username"))
+    if err == auth.ErrUserNotFound {
+        writeError(w, http.StatusNotFound, "user_not_found", "User not found")
+        return nil, nil, false
+    } else if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return nil, nil, false
+    }
+    user := userID(r)
+    if team.Members[user] != teams.Admin && !(self && member.ID == user) {
+        writeError(w, http.StatusForbidden, "forbidden", "Only team admins can manage members")
+        return nil, nil, false
+    }
+    return team, member, true
+}
+
+// setTeamMember serves PUT /teams/{id}/members/{username}: adds a member or changes their role
+func (h *HTTPHandler) setTeamMember(w http.ResponseWriter, r *http.Request) {
+    team, member, ok := h.teamMember(w, r, false)
+    if !ok {
+        return
+    }
+    var req memberRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    if err := h.Teams.SetMember(team.ID, member.ID, req.Role); err != nil {
+        writeMemberError(w, err)
+        return
+    }
+    team, err := h.Teams.Get(team.ID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "team_not_found", "Team not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, h.teamInfo(team, userID(r)))
+}
+
+// removeTeamMember serves DELETE /teams/{id}/members/{username}, members may remove themselves
+func (h *HTTPHandler) removeTeamMember(w http.ResponseWriter, r *http.Request) {
+    team, member, ok := h.teamMember(w, r, true)
+    if !ok {
+        return
+    }
+    if err := h.Teams.RemoveMember(team.ID, member.ID); err != nil {
+        writeMemberError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func writeMemberError(w http.ResponseWriter, err error) {
+    switch err {
+    case teams.ErrInvalidRole:
+        writeError(w, http.StatusBadRequest, "invalid_role", "Role must be viewer, editor or admin")
+    case teams.ErrLastAdmin:
+        writeError(w, http.StatusConflict, "last_admin", "The team needs at least one admin")
+    case teams.ErrNotMember:
+        writeError(w, http.StatusNotFound, "not_a_member", "User is not a member of the team")
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+    }
+}
+
+// setTaskTeam serves PUT /tasks/{id}/team: shares a meeting with a team ({"team_id": ""} makes it
+// private again). Allowed for the owner and admins of the team it is currently shared with, for
+// meetings without an owner only for site admins; the target team needs the user as editor.
+func (h *HTTPHandler) setTaskTeam(w http.ResponseWriter, r *http.Request) {
+    taskID := pathParam(r, "id")
+    if !h.authorizeTask(w, r, taskID, teams.Edit) {
+        return
+    }
+    var req taskTeamRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    task, err := h.Queue.GetTaskInfo(taskID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "task_not_found", "Invalid task ID")
+        return
+    }
+    user := userID(r)
+    isOwner := task.OwnerID == user || (task.OwnerID == "" && h.isAdmin(user))
+    if !isOwner && !(task.TeamID != "" && h.Teams.Role(task.TeamID, user) == teams.Admin) {
+        writeError(w, http.StatusForbidden, "forbidden", "Only the owner or a team admin can change sharing")
+        return
+    }
+    if req.TeamID != "" && !h.Teams.Role(req.TeamID, user).Allows(teams.Edit) {
+        writeError(w, http.StatusForbidden, "forbidden", "You are not an editor of this team")
+        return
+    }
+    if err := h.Queue.SetTeam(taskID, req.TeamID); err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Error processing request")
+        return
+    }
+    writeJSON(w, http.StatusOK, req)
+}
+
+// teamInfo lists a team's members by username
+func (h *HTTPHandler) teamInfo(team *teams.Team, userID string) teamInfo {
+    info := teamInfo{ID: team.ID, Name: team.Name, Role: team.Members[userID], Members: make(map[string]teams.Role)}
+    for memberID, role := range team.Members {
+        if member, err := h.Users.Get(memberID); err == nil {
+            info.Members[member.Username] = role
+        }
+    }
+    return info
+}
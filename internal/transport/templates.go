@@ -0,0 +1,115 @@
+package transport
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+)
+
+type templateRequest struct {
+    Name string `json:"name"`
+    Body string `json:"body"`
+}
+
+// listTemplates serves GET /templates: the latest version of every template
+func (h *HTTPHandler) listTemplates(w http.ResponseWriter, r *http.Request) {
+    templates, err := h.Templates.List()
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+        return
+    }
+    writeJSON(w, http.StatusOK, templates)
+}
+
+// createTemplate serves POST /templates: creates a template at version 1
+func (h *HTTPHandler) createTemplate(w http.ResponseWriter, r *http.Request) {
+    var req templateRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    tmpl, err := h.Templates.Create(req.Name, req.Body)
+    if err != nil {
+        writeTemplateError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, tmpl)
+}
+
+// getTemplate serves GET /templates/{name}: the latest version unless ?version=N is given
+func (h *HTTPHandler) getTemplate(w http.ResponseWriter, r *http.Request) {
+    version := 0
+    if v := r.URL.Query().Get("version"); v != "" {
+        var err error
+        if version, err = strconv.Atoi(v); err != nil || version < 1 {
+            writeError(w, http.StatusBadRequest, "invalid_version", "Invalid version")
+            return
+        }
+    }
+    tmpl, err := h.Templates.Get(pathParam(r, "name"), version)
+    if err != nil {
+        writeTemplateError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, tmpl)
+}
+
+// updateTemplate serves PUT /templates/{name}, every update is stored as a new version
+func (h *HTTPHandler) updateTemplate(w http.ResponseWriter, r *http.Request) {
+    var req templateRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+        return
+    }
+    tmpl, err := h.Templates.Update(pathParam(r, "name"), req.Body)
+    if err != nil {
+        writeTemplateError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, tmpl)
+}
+
+// deleteTemplate serves DELETE /templates/{name}
+func (h *HTTPHandler) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+    if err := h.Templates.Delete(pathParam(r, "name")); err != nil {
+        writeTemplateError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// listTemplateVersions serves GET /templates/{name}/versions
+func (h *HTTPHandler) listTemplateVersions(w http.ResponseWriter, r *http.Request) {
+    versions, err := h.Templates.Versions(pathParam(r, "name"))
+    if err != nil {
+        writeTemplateError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, versions)
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+    switch {
+    case errors.Is(err, prompts.ErrNotFound):
+        writeError(w, http.StatusNotFound, "template_not_found", "Template not found")
+    case errors.Is(err, prompts.ErrExists):
+        writeError(w, http.StatusConflict, "template_exists", "Template already exists")
+    case errors.Is(err, prompts.ErrProtected):
+        writeError(w, http.StatusConflict, "template_protected", "The default template cannot be deleted")
+    case errors.Is(err, prompts.ErrInvalidName):
+        writeError(w, http.StatusBadRequest, "invalid_template_name", "Invalid template name (use lowercase letters, digits, '-' and '_')")
+    case errors.Is(err, prompts.ErrInvalidTemplate):
+        writeError(w, http.StatusBadRequest, "invalid_template", err.Error())
+    default:
+        writeError(w, http.StatusInternalServerError, "internal_error", "Internal Server Error")
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
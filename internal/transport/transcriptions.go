@@ -0,0 +1,228 @@
+package transport
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "regexp"
+    "strings"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/metrics"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/queue"
+    "github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// Same file size limit as OpenAI's transcription API, longer recordings are uploaded as tasks
+const maxTranscriptionSize = 25 << 20 // 25 MB
+
+// ISO 639-1 (or 639-3) language code, as sent by OpenAI clients
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// transcription is the json response format
+type transcription struct {
+    Text string `json:"text"`
+}
+
+// verboseTranscription is the verbose_json response format
+type verboseTranscription struct {
+    Task     string                 `json:"task"`
+    Language string                 `json:"language,omitempty"` // only known if it was requested
+    Duration float64                `json:"duration"`
+    Text     string                 `json:"text"`
+    Segments []transcriptionSegment `json:"segments"`
+}
+
+type transcriptionSegment struct {
+    ID      int     `json:"id"`
+    Start   float64 `json:"start"`
+    End     float64 `json:"end"`
+    Text    string  `json:"text"`
+    Speaker string  `json:"speaker,omitempty"` // diarization label or recognized name, not part of OpenAI's format
+}
+
+// OpenAI returns the routes compatible with OpenAI's API, mounted at /v1/ so that tools built
+// for it can use this server as their base URL
+func (h *HTTPHandler) OpenAI() *Router {
+    rt := NewRouter()
+    rt.Handle("/v1/audio/transcriptions", auth.RequireUser(h.HandleTranscription), "POST")
+    return rt
+}
+
+// HandleTranscription serves POST /v1/audio/transcriptions like OpenAI's API: the recording
+// is transcribed by whisperx through the queue while the request waits. The model, prompt
+// and temperature fields are ignored.
+func (h *HTTPHandler) HandleTranscription(w http.ResponseWriter, r *http.Request) {
+    // Leave some room for the other form fields
+    r.Body = http.MaxBytesReader(w, r.Body, maxTranscriptionSize + 1 << 20)
+    if err := r.ParseMultipartForm(32 << 20); err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            writeError(w, http.StatusRequestEntityTooLarge, "file_too_large", "Recordings over 25 MB must be uploaded to /api/v1/tasks")
+        } else {
+            writeError(w, http.StatusBadRequest, "invalid_request", "Error parsing multipart form")
+        }
+        return
+    }
+
+    file, header, err := r.FormFile("file")
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_file", "Invalid file")
+        return
+    }
+    defer file.Close()
+    if header.Size > maxTranscriptionSize {
+        writeError(w, http.StatusRequestEntityTooLarge, "file_too_large", "Recordings over 25 MB must be uploaded to /api/v1/tasks")
+        return
+    }
+    suffix := recordingSuffix(header.Filename)
+    if suffix == "" {
+        writeError(w, http.StatusBadRequest, "unsupported_file_type", "File must be a .wav or .mp4")
+        return
+    }
+
+    format := r.FormValue("response_format")
+    if format == "" {
+        format = "json"
+    }
+    if format != "json" && format != "text" && format != "srt" && format != "vtt" && format != "verbose_json" {
+        writeError(w, http.StatusBadRequest, "invalid_response_format", "response_format must be json, text, srt, vtt or verbose_json")
+        return
+    }
+    language := r.FormValue("language")
+    if language != "" && !languageCode.MatchString(language) {
+        writeError(w, http.StatusBadRequest, "invalid_language", "language must be an ISO 639-1 code")
+        return
+    }
+
+    tempFile, err := os.CreateTemp("", "upload-*." + suffix)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "internal_error", "Failed to create temp file")
+        return
+    }
+    written, err := io.Copy(tempFile, file)
+    metrics.UploadBytes.WithLabelValues("openai").Add(float64(written))
+    tempFile.Close()
+    if err != nil {
+        os.Remove(tempFile.Name())
+        writeError(w, http.StatusInternalServerError, "internal_error", "Failed to save file")
+        return
+    }
+
+    taskID, _, err := h.Queue.EnqueueTranscription(r.Context(), userID(r), tempFile.Name(), language)
+    if err != nil {
+        os.Remove(tempFile.Name())
+        if err == queue.ErrShuttingDown {
+            writeError(w, http.StatusServiceUnavailable, "shutting_down", "The server is shutting down")
+        } else {
+            writeError(w, http.StatusInternalServerError, "internal_error", "Error processing file")
+        }
+        return
+    }
+    task, err := h.waitForTask(r.Context(), taskID)
+    // Transcriptions are not kept once the request is over
+    go h.discardTask(taskID)
+    if err == queue.ErrTaskNotFound {
+        // Cancelled, e.g. because the server is shutting down
+        writeError(w, http.StatusServiceUnavailable, "transcription_cancelled", "The transcription was cancelled")
+        return
+    }
+    if err != nil {
+        return // the client has gone away
+    }
+    if task.Status == "failed" {
+        writeError(w, http.StatusInternalServerError, "transcription_failed", task.Result.ErrorMsg)
+        return
+    }
+
+    cues := processing.ParseCues(task.Result.Transcript)
+    switch format {
+    case "json":
+        writeJSON(w, http.StatusOK, transcription{Text: transcriptText(cues)})
+    case "verbose_json":
+        response := verboseTranscription{Task: "transcribe", Language: language, Text: transcriptText(cues), Segments: []transcriptionSegment{}}
+        for i, cue := range cues {
+            response.Segments = append(response.Segments, transcriptionSegment{ID: i, Start: cue.Start, End: cue.End, Text: cue.Text, Speaker: cue.Speaker})
+            if cue.End > response.Duration {
+                response.Duration = cue.End
+            }
+        }
+        writeJSON(w, http.StatusOK, response)
+    case "text":
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        fmt.Fprintln(w, transcriptText(cues))
+    case "srt":
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        io.WriteString(w, formatSubtitles(cues, false))
+    case "vtt":
+        w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+        io.WriteString(w, formatSubtitles(cues, true))
+    }
+}
+
+// waitForTask blocks until a task has finished or ctx is done
+func (h *HTTPHandler) waitForTask(ctx context.Context, taskID string) (*types.Task, error) {
+    for {
+        changed := h.Queue.Changed()
+        task, err := h.Queue.GetTaskInfo(taskID)
+        if err != nil {
+            return nil, err
+        }
+        if queue.IsFinished(task) {
+            return task, nil
+        }
+        select {
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        case <-changed:
+        }
+    }
+}
+
+// discardTask deletes a task, after it has finished if it is being processed
+func (h *HTTPHandler) discardTask(taskID string) {
+    if h.Queue.Delete(taskID) == queue.ErrTaskBusy {
+        h.waitForTask(context.Background(), taskID)
+        h.Queue.Delete(taskID)
+    }
+}
+
+// transcriptText joins the text of all cues, without speaker labels
+func transcriptText(cues []processing.Cue) string {
+    texts := make([]string, len(cues))
+    for i, cue := range cues {
+        texts[i] = cue.Text
+    }
+    return strings.Join(texts, " ")
+}
+
+// formatSubtitles renders cues as .vtt or .srt, keeping the "[speaker]: " prefixes of whisperx
+func formatSubtitles(cues []processing.Cue, vtt bool) string {
+    var b strings.Builder
+    separator := ","
+    if vtt {
+        b.WriteString("WEBVTT\n\n")
+        separator = "."
+    }
+    for i, cue := range cues {
+        if !vtt {
+            fmt.Fprintf(&b, "%d\n", i + 1)
+        }
+        fmt.Fprintf(&b, "%s --> %s\n", subtitleTime(cue.Start, separator), subtitleTime(cue.End, separator))
+        if cue.Speaker != "" {
+            fmt.Fprintf(&b, "[%s]: ", cue.Speaker)
+        }
+        b.WriteString(cue.Text + "\n\n")
+    }
+    return b.String()
+}
+
+// subtitleTime formats seconds as "hh:mm:ss.mmm", with a comma before the milliseconds for .srt
+func subtitleTime(seconds float64, separator string) string {
+    ms := int64(seconds * 1000 + 0.5)
+    return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms / 3600000, ms / 60000 % 60, ms / 1000 % 60, separator, ms % 1000)
+}
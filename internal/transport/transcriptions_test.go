@@ -0,0 +1,185 @@
+package transport
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/stanek-michal/go-ai-summarizer/internal/auth"
+    "github.com/stanek-michal/go-ai-summarizer/internal/processing"
+)
+
+const meetingTranscript = "WEBVTT\n\n" +
+    "00:00.000 --> 00:05.250\n[SPEAKER_00]: The rollout moves to March.\n\n" +
+    "00:05.250 --> 00:09.000\n[SPEAKER_01]: I'll tell the customers.\n\n"
+
+// transcribe sends a transcription request with fields as the owner of apiKey
+func (a *apiTest) transcribe(ctx context.Context, apiKey string, fields map[string]string) *httptest.ResponseRecorder {
+    a.t.Helper()
+    body := newUpload(a.t, "meeting.wav", fields)
+    r := httptest.NewRequest("POST", "/v1/audio/transcriptions", bytes.NewReader(body.data)).WithContext(ctx)
+    r.Header.Set("Content-Type", body.contentType)
+    r.Header.Set("X-API-Key", apiKey)
+    w := httptest.NewRecorder()
+    auth.Middleware(auth.Chain{a.h.Sessions, auth.APIKeyAuthenticator{Users: a.h.Users}}, a.h.OpenAI()).ServeHTTP(w, r)
+    return w
+}
+
+// waitForQueue waits until the queue of a holds tasks with the given statuses, in order.
+// Queueing a task does not signal a change, so the queue is polled too.
+func (a *apiTest) waitForQueue(statuses ...string) {
+    a.t.Helper()
+    timeout := time.After(10 * time.Second)
+    for {
+        changed := a.h.Queue.Changed()
+        var current []string
+        for _, task := range a.h.Queue.List() {
+            current = append(current, task.Status)
+        }
+        if reflect.DeepEqual(current, statuses) || len(current) == 0 && len(statuses) == 0 {
+            return
+        }
+        select {
+        case <-changed:
+        case <-time.After(10 * time.Millisecond):
+        case <-timeout:
+            a.t.Fatalf("queue has tasks %v, want %v", current, statuses)
+        }
+    }
+}
+
+func TestTranscriptionFormats(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+    a.processTasks(meetingTranscript)()
+
+    tests := []struct {
+        format      string
+        language    string
+        contentType string
+        body        string
+    }{
+        {"", "", "application/json", `{"text":"The rollout moves to March. I'll tell the customers."}` + "\n"},
+        {"json", "en", "application/json", `{"text":"The rollout moves to March. I'll tell the customers."}` + "\n"},
+        {"text", "", "text/plain; charset=utf-8", "The rollout moves to March. I'll tell the customers.\n"},
+        {"srt", "", "text/plain; charset=utf-8", "1\n00:00:00,000 --> 00:00:05,250\n[SPEAKER_00]: The rollout moves to March.\n\n" +
+            "2\n00:00:05,250 --> 00:00:09,000\n[SPEAKER_01]: I'll tell the customers.\n\n"},
+        {"vtt", "", "text/vtt; charset=utf-8", "WEBVTT\n\n00:00:00.000 --> 00:00:05.250\n[SPEAKER_00]: The rollout moves to March.\n\n" +
+            "00:00:05.250 --> 00:00:09.000\n[SPEAKER_01]: I'll tell the customers.\n\n"},
+    }
+    for _, tt := range tests {
+        w := a.transcribe(context.Background(), anaKey, map[string]string{"response_format": tt.format, "language": tt.language})
+        if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.contentType || w.Body.String() != tt.body {
+            t.Errorf("%q: status %d, Content-Type %q, body %q", tt.format, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+        }
+    }
+
+    // verbose_json has the segments with their speakers, and the language if it was given
+    w := a.transcribe(context.Background(), anaKey, map[string]string{"response_format": "verbose_json", "language": "de"})
+    var verbose verboseTranscription
+    if err := json.Unmarshal(w.Body.Bytes(), &verbose); err != nil || w.Code != http.StatusOK {
+        t.Fatalf("verbose_json: status %d, %v", w.Code, err)
+    }
+    want := verboseTranscription{Task: "transcribe", Language: "de", Duration: 9, Text: "The rollout moves to March. I'll tell the customers.", Segments: []transcriptionSegment{
+        {ID: 0, Start: 0, End: 5.25, Text: "The rollout moves to March.", Speaker: "SPEAKER_00"},
+        {ID: 1, Start: 5.25, End: 9, Text: "I'll tell the customers.", Speaker: "SPEAKER_01"},
+    }}
+    if !reflect.DeepEqual(verbose, want) {
+        t.Errorf("verbose_json = %+v, want %+v", verbose, want)
+    }
+
+    if w := a.transcribe(context.Background(), anaKey, map[string]string{"response_format": "xml"}); w.Code != http.StatusBadRequest {
+        t.Errorf("unknown response_format: status %d, want 400", w.Code)
+    }
+    // Transcriptions are not kept
+    a.waitForQueue()
+}
+
+func TestSubtitleTime(t *testing.T) {
+    tests := []struct {
+        seconds   float64
+        separator string
+        want      string
+    }{
+        {0, ".", "00:00:00.000"},
+        {5.25, ",", "00:00:05,250"},
+        {0.0004, ".", "00:00:00.000"},
+        {0.0005, ".", "00:00:00.001"},
+        {59.9996, ".", "00:01:00.000"}, // rounding carries into the minutes
+        {3599.9999, ",", "01:00:00,000"},
+        {3661.5, ".", "01:01:01.500"},
+        {36000, ".", "10:00:00.000"},
+        {360000.123, ".", "100:00:00.123"},
+    }
+    for _, tt := range tests {
+        if got := subtitleTime(tt.seconds, tt.separator); got != tt.want {
+            t.Errorf("subtitleTime(%v, %q) = %q, want %q", tt.seconds, tt.separator, got, tt.want)
+        }
+    }
+}
+
+func TestFormatSubtitles(t *testing.T) {
+    cues := []processing.Cue{
+        {Start: 3725.0005, End: 3730, Text: "Without a speaker."},
+        {Start: 3730, End: 3731.9994, Speaker: "Ana", Text: "With one."},
+    }
+    tests := []struct {
+        name string
+        cues []processing.Cue
+        vtt  bool
+        want string
+    }{
+        {"vtt", cues, true, "WEBVTT\n\n01:02:05.001 --> 01:02:10.000\nWithout a speaker.\n\n01:02:10.000 --> 01:02:11.999\n[Ana]: With one.\n\n"},
+        {"srt", cues, false, "1\n01:02:05,001 --> 01:02:10,000\nWithout a speaker.\n\n2\n01:02:10,000 --> 01:02:11,999\n[Ana]: With one.\n\n"},
+        {"empty vtt", nil, true, "WEBVTT\n\n"},
+        {"empty srt", nil, false, ""},
+    }
+    for _, tt := range tests {
+        if got := formatSubtitles(tt.cues, tt.vtt); got != tt.want {
+            t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestTranscriptionClientGone(t *testing.T) {
+    a := newAPITest(t)
+    _, anaKey := a.user("ana")
+    release := a.processTasks(meetingTranscript)
+
+    // The first transcription is being processed, the second one waits for it
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan *httptest.ResponseRecorder, 2)
+    go func() { done <- a.transcribe(ctx, anaKey, nil) }()
+    a.waitForQueue("processing")
+    go func() { done <- a.transcribe(ctx, anaKey, nil) }()
+    a.waitForQueue("processing", "waiting")
+
+    // Both clients go away, nothing is written for them
+    cancel()
+    for i := 0; i < 2; i++ {
+        select {
+        case w := <-done:
+            if w.Body.Len() != 0 {
+                t.Errorf("response written after the client went away: %d %q", w.Code, w.Body.String())
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatal("the request did not return after the client went away")
+        }
+    }
+
+    // The waiting task is dropped at once, the busy one once it has finished
+    a.waitForQueue("processing")
+    release()
+    a.waitForQueue()
+    uploads, err := filepath.Glob(filepath.Join(os.TempDir(), "upload-*"))
+    if err != nil || len(uploads) != 0 {
+        t.Errorf("uploads left behind: %v %v", uploads, err)
+    }
+}
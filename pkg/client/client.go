@@ -0,0 +1,302 @@
+// Package client is a Go client for the summarizer's /api/v1 REST API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+var (
+	// ErrTaskFailed is returned by Wait when processing of the task failed
+	ErrTaskFailed = errors.New("task failed")
+)
+
+// Error is an error response of the API, Code is one of its machine-readable error codes
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("summarizer: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+}
+
+// IsNotFound reports whether err is a 404 from the API, e.g. an unknown task or a wrong token
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client calls the API of one summarizer server. Requests are retried with exponential
+// backoff when the server is unavailable; GET and DELETE requests also when the connection
+// fails. All methods return early when their context is cancelled.
+type Client struct {
+	BaseURL      string // e.g. http://localhost:9001
+	APIKey       string // sent as X-API-Key, needed for uploads
+	HTTPClient   *http.Client
+	MaxRetries   int           // retries after the first attempt
+	RetryDelay   time.Duration // delay before the first retry, doubled for every further one
+	PollInterval time.Duration // how often Wait checks the task status
+}
+
+// New returns a client for the server at baseURL using an API key (see /auth/keys)
+func New(baseURL string, apiKey string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIKey:       apiKey,
+		HTTPClient:   http.DefaultClient,
+		MaxRetries:   3,
+		RetryDelay:   500 * time.Millisecond,
+		PollInterval: 2 * time.Second,
+	}
+}
+
+// Task identifies an uploaded task. Token gives read access to it without an account.
+type Task struct {
+	ID    string `json:"task_id"`
+	Token string `json:"token"`
+}
+
+// UploadOptions are the optional fields of an upload
+type UploadOptions struct {
+	Template  string // summary template, the server default if empty
+	Mode      string // map_reduce (default) or concat
+	Title     string
+	Date      string
+	Attendees []string
+	TTL       time.Duration // lifetime of the result, 0 for the server default
+	Team      string        // ID of a team to share the meeting with
+}
+
+// UploadFile uploads a .wav or .mp4 recording from disk
+func (c *Client) UploadFile(ctx context.Context, path string, opts UploadOptions) (*Task, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return c.Upload(ctx, filepath.Base(path), file, opts)
+}
+
+// Upload uploads a recording, fileName must end in .wav or .mp4. The recording is streamed,
+// it is read again from the start if the upload is retried.
+func (c *Client) Upload(ctx context.Context, fileName string, recording io.ReadSeeker, opts UploadOptions) (*Task, error) {
+	fields := map[string]string{
+		"template":  opts.Template,
+		"mode":      opts.Mode,
+		"title":     opts.Title,
+		"date":      opts.Date,
+		"attendees": strings.Join(opts.Attendees, ","),
+		"team":      opts.Team,
+	}
+	if opts.TTL > 0 {
+		fields["ttl"] = opts.TTL.String()
+	}
+
+	// The form is written by a goroutine while the request is sent, a retry waits for the
+	// previous writer to stop before rewinding the recording
+	var written chan struct{}
+	body := func() (io.Reader, string, error) {
+		if written != nil {
+			<-written
+		}
+		if _, err := recording.Seek(0, io.SeekStart); err != nil {
+			return nil, "", err
+		}
+		reader, writer := io.Pipe()
+		form := multipart.NewWriter(writer)
+		done := make(chan struct{})
+		written = done
+		go func() {
+			defer close(done)
+			writer.CloseWithError(writeUploadForm(form, fileName, recording, fields))
+		}()
+		return reader, form.FormDataContentType(), nil
+	}
+
+	var task Task
+	if err := c.do(ctx, "POST", "/tasks", "", body, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func writeUploadForm(form *multipart.Writer, fileName string, recording io.Reader, fields map[string]string) error {
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, recording); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// Status returns the status of a task, token may be empty for the user's own tasks
+func (c *Client) Status(ctx context.Context, taskID string, token string) (*types.TaskInfo, error) {
+	var info types.TaskInfo
+	if err := c.do(ctx, "GET", "/tasks/"+url.PathEscape(taskID), token, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Result returns the transcript, summaries and action items of a finished task
+func (c *Client) Result(ctx context.Context, taskID string, token string) (*types.Result, error) {
+	var result types.Result
+	if err := c.do(ctx, "GET", "/tasks/"+url.PathEscape(taskID)+"/result", token, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Wait polls the task until it has finished and returns its result. progress, if not nil,
+// is called with the task status whenever it changes. A failed task returns its result
+// together with an error wrapping ErrTaskFailed.
+func (c *Client) Wait(ctx context.Context, taskID string, token string, progress func(types.TaskInfo)) (*types.Result, error) {
+	last := ""
+	for {
+		info, err := c.Status(ctx, taskID, token)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil && info.Status != last {
+			progress(*info)
+		}
+		last = info.Status
+
+		switch info.Status {
+		case "completed":
+			return c.Result(ctx, taskID, token)
+		case "failed":
+			result, err := c.Result(ctx, taskID, token)
+			if err != nil {
+				return nil, err
+			}
+			return result, fmt.Errorf("%w: %s", ErrTaskFailed, result.ErrorMsg)
+		}
+
+		if err := sleep(ctx, c.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Cancel removes a task that is still waiting to be processed, or deletes a finished one.
+// Tasks that are being processed cannot be cancelled, the API responds with 409 (task_busy).
+func (c *Client) Cancel(ctx context.Context, taskID string, token string) error {
+	return c.do(ctx, "DELETE", "/tasks/"+url.PathEscape(taskID), token, nil, nil)
+}
+
+// do sends a request to the API and decodes the JSON response into out (if not nil).
+// body returns a fresh request body and its content type for every attempt.
+func (c *Client) do(ctx context.Context, method string, path string, token string, body func() (io.Reader, string, error), out interface{}) error {
+	idempotent := method == "GET" || method == "DELETE"
+	delay := c.RetryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, token, body)
+		retry := attempt < c.MaxRetries && ctx.Err() == nil
+		if err != nil {
+			if !retry || !idempotent {
+				return err
+			}
+		} else if retry && retryable(resp.StatusCode, idempotent) {
+			resp.Body.Close()
+		} else {
+			defer resp.Body.Close()
+			return decodeResponse(resp, out)
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, token string, body func() (io.Reader, string, error)) (*http.Response, error) {
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		var err error
+		if reader, contentType, err = body(); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if token != "" {
+		req.Header.Set("X-Task-Token", token)
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.HTTPClient.Do(req)
+}
+
+// retryable reports whether a response means the server could not handle the request right now.
+// Requests that are not idempotent are only retried if the server surely did not act on them.
+func retryable(status int, idempotent bool) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		var body types.ErrorResponse
+		if json.Unmarshal(data, &body) != nil || body.Error.Code == "" {
+			return &Error{StatusCode: resp.StatusCode, Code: "unknown", Message: string(bytes.TrimSpace(data))}
+		}
+		return &Error{StatusCode: resp.StatusCode, Code: body.Error.Code, Message: body.Error.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
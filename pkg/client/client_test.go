@@ -0,0 +1,275 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// newClient returns a client of server that retries twice without waiting long
+func newClient(server *httptest.Server) *Client {
+	c := New(server.URL, "sk_test")
+	c.RetryDelay = 10 * time.Millisecond
+	c.MaxRetries = 2
+	c.PollInterval = time.Millisecond
+	return c
+}
+
+// roundTripper fails every request with a connection error
+type roundTripper struct {
+	mu       sync.Mutex
+	attempts int
+}
+
+func (rt *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.attempts++
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	return nil, errors.New("connection refused")
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int // responses of the attempts, the last one repeats
+		attempts int
+		status   int // of the returned error, 0 for success
+	}{
+		{"unavailable", "GET", []int{503, 503, 200}, 3, 0},
+		{"too many requests", "GET", []int{429, 200}, 2, 0},
+		{"upload unavailable", "POST", []int{503, 429, 202}, 3, 0},
+		{"gives up", "GET", []int{503}, 3, 503},
+		{"bad gateway", "GET", []int{502, 200}, 2, 0},
+		// The server may have acted on the upload before the gateway failed
+		{"upload bad gateway", "POST", []int{502, 202}, 1, 502},
+		{"client error", "GET", []int{404}, 1, 404},
+	}
+	for _, tt := range tests {
+		var attempts []time.Time
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts = append(attempts, time.Now())
+			status := tt.statuses[len(tt.statuses)-1]
+			if len(attempts) <= len(tt.statuses) {
+				status = tt.statuses[len(attempts)-1]
+			}
+			if r.Header.Get("X-API-Key") != "sk_test" {
+				status = http.StatusUnauthorized
+			}
+			io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if status >= 300 {
+				w.Write([]byte(`{"error": {"code": "busy", "message": "Try again"}}`))
+			} else {
+				w.Write([]byte(`{"task_id": "t1", "token": "secret", "ID": "t1", "Status": "waiting"}`))
+			}
+		}))
+		c := newClient(server)
+
+		var err error
+		if tt.method == "POST" {
+			_, err = c.Upload(context.Background(), "meeting.wav", strings.NewReader("RIFF"), UploadOptions{})
+		} else {
+			_, err = c.Status(context.Background(), "t1", "")
+		}
+		server.Close()
+
+		if len(attempts) != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, len(attempts), tt.attempts)
+		}
+		var apiErr *Error
+		if tt.status == 0 && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if tt.status != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+			t.Errorf("%s: err = %v, want a %d error", tt.name, err, tt.status)
+		}
+		// Exponential backoff: 10ms, then 20ms
+		for i := 1; i < len(attempts); i++ {
+			if wait, min := attempts[i].Sub(attempts[i-1]), c.RetryDelay<<(i-1); wait < min {
+				t.Errorf("%s: retry %d after %v, want at least %v", tt.name, i, wait, min)
+			}
+		}
+	}
+}
+
+func TestRetryConnectionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(c *Client) error
+		attempts int
+	}{
+		{"status", func(c *Client) error { _, err := c.Status(context.Background(), "t1", ""); return err }, 3},
+		{"cancel", func(c *Client) error { return c.Cancel(context.Background(), "t1", "") }, 3},
+		// An upload may have arrived even though the connection failed
+		{"upload", func(c *Client) error {
+			_, err := c.Upload(context.Background(), "meeting.wav", strings.NewReader("RIFF"), UploadOptions{})
+			return err
+		}, 1},
+	}
+	for _, tt := range tests {
+		transport := &roundTripper{}
+		c := New("http://summarizer.invalid", "sk_test")
+		c.HTTPClient = &http.Client{Transport: transport}
+		c.RetryDelay = time.Millisecond
+		c.MaxRetries = 2
+		if err := tt.call(c); err == nil {
+			t.Errorf("%s: succeeded without a connection", tt.name)
+		}
+		if transport.attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, transport.attempts, tt.attempts)
+		}
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	c := newClient(server)
+	c.RetryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+	defer cancel()
+	if _, err := c.Status(ctx, "t1", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's error while waiting to retry", err)
+	}
+}
+
+func TestUploadRewound(t *testing.T) {
+	recording := bytes.Repeat([]byte("0123456789"), 100000)
+	var mu sync.Mutex
+	var received [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("attempt without a file: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		attempt := len(received)
+		mu.Unlock()
+		var data []byte
+		if attempt == 0 {
+			// The first attempt fails after reading only part of the recording
+			data = make([]byte, 1000)
+			io.ReadFull(file, data)
+		} else {
+			data, _ = io.ReadAll(file)
+		}
+		mu.Lock()
+		received = append(received, data)
+		mu.Unlock()
+		if header.Filename != "meeting.wav" || r.FormValue("title") != "Planning" || r.FormValue("attendees") != "Ana,Bob" {
+			t.Errorf("attempt %d: file %q, title %q, attendees %q", attempt, header.Filename, r.FormValue("title"), r.FormValue("attendees"))
+		}
+		if attempt == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"task_id": "t1", "token": "secret"}`))
+	}))
+	defer server.Close()
+
+	c := newClient(server)
+	task, err := c.Upload(context.Background(), "meeting.wav", bytes.NewReader(recording),
+		UploadOptions{Title: "Planning", Attendees: []string{"Ana", "Bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ID != "t1" || task.Token != "secret" {
+		t.Errorf("task = %+v", task)
+	}
+	if len(received) != 2 || !bytes.Equal(received[1], recording) {
+		t.Errorf("the retried upload did not send the whole recording from the start")
+	}
+}
+
+func TestWait(t *testing.T) {
+	statuses := []string{"waiting", "waiting", "processing", "failed"}
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Task-Token") != "secret" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": "task_not_found", "message": "Invalid task ID"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/tasks/t1":
+			status := statuses[polls]
+			polls++
+			w.Write([]byte(`{"ID": "t1", "Status": "` + status + `"}`))
+		case "/api/v1/tasks/t1/result":
+			w.Write([]byte(`{"Transcript": "WEBVTT", "ErrorMsg": "summarizer crashed"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := newClient(server)
+
+	var seen []string
+	result, err := c.Wait(context.Background(), "t1", "secret", func(info types.TaskInfo) {
+		seen = append(seen, info.Status)
+	})
+	if !errors.Is(err, ErrTaskFailed) || !strings.Contains(err.Error(), "summarizer crashed") {
+		t.Errorf("err = %v, want ErrTaskFailed with the task's error", err)
+	}
+	if result == nil || result.Transcript != "WEBVTT" {
+		t.Errorf("result = %+v, want the result of the failed task", result)
+	}
+	if strings.Join(seen, ",") != "waiting,processing,failed" {
+		t.Errorf("progress = %v, want every status once", seen)
+	}
+
+	if _, err := c.Wait(context.Background(), "t1", "wrong", nil); !IsNotFound(err) {
+		t.Errorf("wrong token: err = %v, want a 404", err)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		err    *Error
+	}{
+		{"success", 200, `{"ID": "t1"}`, nil},
+		{"error envelope", 409, `{"error": {"code": "task_busy", "message": "Task is being processed"}}`, &Error{409, "task_busy", "Task is being processed"}},
+		{"not JSON", 502, "Bad Gateway\n", &Error{502, "unknown", "Bad Gateway"}},
+		{"JSON without a code", 500, `{"message": "oops"}`, &Error{500, "unknown", `{"message": "oops"}`}},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}
+		var info types.TaskInfo
+		err := decodeResponse(resp, &info)
+		if tt.err == nil {
+			if err != nil || info.ID != "t1" {
+				t.Errorf("%s: %+v, %v", tt.name, info, err)
+			}
+			continue
+		}
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || *apiErr != *tt.err {
+			t.Errorf("%s: err = %#v, want %#v", tt.name, err, tt.err)
+		}
+	}
+	if !IsNotFound(&Error{StatusCode: 404}) || IsNotFound(&Error{StatusCode: 403}) || IsNotFound(errors.New("404")) {
+		t.Error("IsNotFound only matches 404 errors of the API")
+	}
+}
@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// newTaskID returns a random (version 4) UUID
+func newTaskID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// newAccessToken returns a random secret for reading a task's results
+func newAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the form an access token is stored in, so a leaked archive does not leak tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMatches reports whether token is the access token of a task
+func TokenMatches(task *types.Task, token string) bool {
+	return token != "" && task.TokenHash != "" &&
+		subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(task.TokenHash)) == 1
+}
+
+// SetTeam shares a task with a team, or makes it private to its owner again if teamID is empty
+func (q *Queue) SetTeam(taskID string, teamID string) error {
+	q.mu.Lock()
+	task, ok := q.taskLookup[taskID]
+	if ok {
+		task.TeamID = teamID
+	}
+	q.mu.Unlock()
+	if ok {
+		q.archiveTask(task)
+		return nil
+	}
+
+	if q.archive == nil {
+		return ErrTaskNotFound
+	}
+	q.archiveMu.Lock()
+	defer q.archiveMu.Unlock()
+	archived, err := q.archive.Get(taskID)
+	if err != nil {
+		return ErrTaskNotFound
+	}
+	archived.TeamID = teamID
+	return q.archive.Save(*archived)
+}
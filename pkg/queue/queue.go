@@ -1,158 +1,598 @@
 package queue
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stanek-michal/go-ai-summarizer/internal/archive"
+	"github.com/stanek-michal/go-ai-summarizer/internal/metrics"
 	"github.com/stanek-michal/go-ai-summarizer/internal/processing"
+	"github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+	"github.com/stanek-michal/go-ai-summarizer/internal/speakers"
+	"github.com/stanek-michal/go-ai-summarizer/internal/tasklog"
+	"github.com/stanek-michal/go-ai-summarizer/internal/tracing"
 	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
 )
 
+var (
+	ErrTaskNotFound    = errors.New("task not found")
+	ErrNoTranscript    = errors.New("task has no transcript to summarize")
+	ErrUnknownTemplate = errors.New("unknown summary template")
+	ErrUnknownMode     = errors.New("unknown summary mode")
+	ErrUnknownSpeaker  = errors.New("unknown speaker label")
+	ErrInvalidSpeaker  = errors.New("invalid speaker name")
+	ErrTaskBusy        = errors.New("task is still being processed")
+	ErrInvalidTTL      = errors.New("invalid result lifetime")
+	ErrShuttingDown    = errors.New("server is shutting down")
+)
+
+// job is a unit of work for the AI engine - either the full pipeline for a new
+// upload or (when summaryID is set) a re-summarization of a finished task
+type job struct {
+	task      *types.Task
+	summaryID string
+	language  string // transcription language of a transcription-only task, detected if empty
+	trace     trace.SpanContext // span of the request that queued the job, the job's spans join its trace
+	queuedAt  time.Time
+}
+
+var tracer = otel.Tracer("github.com/stanek-michal/go-ai-summarizer/pkg/queue")
+
+// startJob traces the wait of a job in the queue and starts the span of its processing
+func startJob(j job, kind string) (context.Context, trace.Span) {
+	ctx := trace.ContextWithSpanContext(context.Background(), j.trace)
+	attrs := trace.WithAttributes(attribute.String("task.id", j.task.ID), attribute.String("task.kind", kind))
+	_, wait := tracer.Start(ctx, "queue.wait", attrs, trace.WithTimestamp(j.queuedAt))
+	wait.End()
+	return tracer.Start(ctx, "task.process", attrs)
+}
+
 // Queue represents a queue of tasks to be processed
 type Queue struct {
-	taskLookup map[int]*types.Task    // For task status lookup
-	taskQueue []*types.Task           // FIFO queue to maintain order and garbage collect old unclaimed tasks
-	processing chan *types.Task       // enqueue tasks for processing
-	lastID     int                    // last ID that was used for a task - ever incrementing counter
+	taskLookup map[string]*types.Task // For task status lookup
+	taskQueue []*types.Task           // FIFO queue to maintain order, finished tasks stay until they expire or are deleted
+	processing chan job               // enqueue jobs for processing
 	mu         sync.Mutex
 	processor  *processing.Processor
+	templates  *prompts.Store         // summary prompt templates
+	archive    *archive.Archive       // completed meetings are kept here, may be nil
+	archiveMu  sync.Mutex             // orders archive writes of task snapshots
+	retention  RetentionPolicy
+	logs       *tasklog.Store         // per-task logs, may be nil
+	changed    chan struct{}          // closed and replaced whenever a task or summary changes status
+	closed     bool                   // set by Close, no new work is taken
 }
 
-func NewQueue() *Queue {
+func NewQueue(processor *processing.Processor, templates *prompts.Store, meetings *archive.Archive, retention RetentionPolicy, logs *tasklog.Store) *Queue {
 	return &Queue{
-		taskLookup: make(map[int]*types.Task),
+		taskLookup: make(map[string]*types.Task),
 		taskQueue:  make([]*types.Task, 0),
-		processing: make(chan *types.Task, 1), // Limit to 1 as AI engine can process one thing at a time
-		lastID:     0,
-		processor:  processing.NewProcessor(),
+		processing: make(chan job, 1), // Limit to 1 as AI engine can process one thing at a time
+		processor:  processor,
+		templates:  templates,
+		archive:    meetings,
+		retention:  retention,
+		logs:       logs,
+		changed:    make(chan struct{}),
 	}
 }
 
+// Changed returns a channel that is closed the next time a task or one of its summaries
+// changes status, to wait for progress without polling
+func (q *Queue) Changed() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.changed
+}
+
+// notify wakes up everyone waiting on Changed, caller must hold q.mu
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
 // StartProcessing processes one task at a time in infinite loop
 func (q *Queue) StartProcessing() {
-	for task := range q.processing {
+	for j := range q.processing {
+		if j.summaryID != "" {
+			q.processSummary(j)
+			continue
+		}
+		if j.task.Transcription {
+			q.processTranscription(j)
+			continue
+		}
+		task := j.task
 		filename := ""
 		q.mu.Lock()
+		if task.Status == "cancelled" || q.closed {
+			q.mu.Unlock()
+			continue // deleted while waiting, or left for Checkpoint
+		}
 		filename = task.FileName
 		task.Status = "processing"
+		meeting := task.Meeting
+		summary := task.Result.Summaries[0]
+		wait := time.Since(task.CreatedAt)
+		metrics.QueueWait.Observe(wait.Seconds())
+		q.notify()
 		q.mu.Unlock()
 
-		result, err := q.processor.Process(filename)
+		ctx, span := startJob(j, "meeting")
+		logger := q.logs.Open(task.ID)
+		logger.Info("Processing task", "waited", wait.Round(time.Millisecond), "template", summary.Template, "mode", summary.Mode)
+		start := time.Now()
+		var result types.Result
+		prompt, _, err := q.templates.Render(summary.Template, summary.TemplateVersion, meeting)
+		if err != nil {
+			logger.Error("Failed to render summary template", "err", err)
+			metrics.Failures.WithLabelValues("template", "render").Inc()
+			processing.CleanUpUserFiles(filename, "")
+			result = types.Result{ErrorMsg: err.Error()}
+		} else {
+			result, err = q.processor.Process(ctx, logger, filename, processing.SummaryOptions{Prompt: prompt, Mode: summary.Mode, Extract: true})
+		}
+		logFinished(logger, "Task", err, start)
+		logger.Close()
+		tracing.End(span, err)
 
 		q.mu.Lock()
-		task.Result = result
+		// Keep the initial summary entry (and any queued in the meantime) in sync with the result
+		generated := result.Summaries
+		result.Summaries = task.Result.Summaries
 		if err != nil {
 			task.Status = "failed"
+			result.Summaries[0].Status = "failed"
+			result.Summaries[0].ErrorMsg = result.ErrorMsg
 		} else {
 			task.Status = "completed"
+			result.Summaries[0].Status = "completed"
+			result.Summaries[0].Text = result.Summary
+			if len(generated) > 0 {
+				result.Summaries[0].ChunkSummaries = generated[0].ChunkSummaries
+			}
 		}
+		task.Result = result
+		q.setExpiry(task)
+		metrics.TaskDuration.WithLabelValues("meeting", task.Status).Observe(time.Since(start).Seconds())
+		q.notify()
 		q.mu.Unlock()
+
+		q.archiveTask(task)
 	}
 }
 
-// Enqueue adds a new task to the queue
-func (q *Queue) Enqueue(fileName string) (int, error) {
-	// Garbage collect old completed entries if we accumulated too many
-	q.GarbageCollectOldEntries()
-
-	q.mu.Lock()
+// Enqueue adds a new task to the queue, shared with teamID unless it is empty. Its initial
+// summary uses the latest version of the given template and the given mode (map-reduce if
+// empty). The result is kept for ttl after the task finishes, 0 means the retention policy's TTL.
+// Returns the random task ID and the access token required to read the task.
+func (q *Queue) Enqueue(ctx context.Context, ownerID string, teamID string, fileName string, meeting types.Meeting, template string, mode string, ttl time.Duration) (string, string, error) {
+	tmpl, mode, err := q.resolveSummaryOptions(template, 0, mode)
+	if err != nil {
+		return "", "", err
+	}
+	if ttl < 0 || (q.retention.TTL > 0 && ttl > q.retention.TTL) {
+		return "", "", ErrInvalidTTL
+	}
 
-	// Generate a unique identifier for the task
-        q.lastID++
-	taskID := q.lastID
-	task := &types.Task{
-		ID:       strconv.Itoa(taskID),
+	return q.add(ctx, job{task: &types.Task{
+		OwnerID:  ownerID,
+		TeamID:   teamID,
 		FileName: fileName,
-		Status:   "waiting",
+		Meeting:  meeting,
+		TTL:      ttl,
+		Result:   types.Result{Summaries: []types.Summary{{
+			ID:              "1",
+			Template:        tmpl.Name,
+			TemplateVersion: tmpl.Version,
+			Mode:            mode,
+			Status:          "waiting",
+		}}},
+	}})
+}
+
+// EnqueueTranscription adds a task that only transcribes the file, in the given language
+// (detected if empty). Its result is neither summarized nor archived.
+func (q *Queue) EnqueueTranscription(ctx context.Context, ownerID string, fileName string, language string) (string, string, error) {
+	return q.add(ctx, job{task: &types.Task{OwnerID: ownerID, FileName: fileName, Transcription: true}, language: language})
+}
+
+// add assigns the job's new task an ID and access token and queues it for processing,
+// its spans join the trace of ctx
+func (q *Queue) add(ctx context.Context, j job) (string, string, error) {
+	// Generate an unguessable identifier and access token for the task
+	taskID, err := newTaskID()
+	if err != nil {
+		return "", "", err
+	}
+	token, err := newAccessToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return "", "", ErrShuttingDown
 	}
+	task := j.task
+	task.ID = taskID
+	task.TokenHash = hashToken(token)
+	task.Status = "waiting"
+	task.CreatedAt = time.Now()
 	q.taskQueue = append(q.taskQueue, task)
 	q.taskLookup[taskID] = task
 	q.mu.Unlock()
 
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("task.id", taskID))
+	j.trace = span.SpanContext()
+	j.queuedAt = task.CreatedAt
+
+	logger := q.logs.Open(taskID)
+	var size int64
+	if info, err := os.Stat(task.FileName); err == nil {
+		size = info.Size()
+	}
+	if j.trace.IsValid() {
+		logger.Logger = logger.With("trace_id", j.trace.TraceID())
+	}
+	logger.Info("Task queued", "owner", task.OwnerID, "file", task.FileName, "bytes", size, "transcription", task.Transcription)
+	logger.Close()
+
 	// Send task to processor channel
 	go func() {
-		q.processing <- task
+		q.processing <- j
 	}()
 
-	return taskID, nil
+	return taskID, token, nil
 }
 
-// Cleanup a task by ID (only if its status is "completed" or "failed")
-func (q *Queue) Cleanup(taskID int) {
+// EnqueueSummary schedules another summarization of a finished task's transcript
+// with the given template version (latest if 0) and mode (map-reduce if empty)
+func (q *Queue) EnqueueSummary(ctx context.Context, taskID string, template string, version int, mode string) (*types.Summary, error) {
+	tmpl, mode, err := q.resolveSummaryOptions(template, version, mode)
+	if err != nil {
+		return nil, err
+	}
+
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil, ErrShuttingDown
+	}
+	task, ok := q.taskLookup[taskID]
+	if !ok {
+		q.mu.Unlock()
+		return nil, ErrTaskNotFound
+	}
+	if (task.Status != "completed" && task.Status != "failed") || task.Result.Transcript == "" {
+		q.mu.Unlock()
+		return nil, ErrNoTranscript
+	}
+	summary := types.Summary{
+		ID:              strconv.Itoa(len(task.Result.Summaries) + 1),
+		Template:        tmpl.Name,
+		TemplateVersion: tmpl.Version,
+		Mode:            mode,
+		Status:          "waiting",
+	}
+	task.Result.Summaries = append(task.Result.Summaries, summary)
+	q.notify()
+	q.mu.Unlock()
 
+	// Send job to processor channel
+	j := job{task: task, summaryID: summary.ID, trace: trace.SpanContextFromContext(ctx), queuedAt: time.Now()}
+	go func() {
+		q.processing <- j
+	}()
+
+	return &summary, nil
+}
+
+// RenameSpeakers assigns names to the speaker labels (label -> name) of a finished task,
+// rewriting its transcript and the owners of its action items
+func (q *Queue) RenameSpeakers(taskID string, names map[string]string) error {
+	q.mu.Lock()
 	task, ok := q.taskLookup[taskID]
 	if !ok {
-		return // Task not found
-	}
-
-	// Check if the task is completed before removing it
-	if task.Status == "completed" || task.Status == "failed" {
-		// Remove from taskLookup
-		delete(q.taskLookup, taskID)
-		// Remove from taskQueue
-		for i, t := range q.taskQueue {
-			if t.ID == strconv.Itoa(taskID) {
-				q.taskQueue = append(q.taskQueue[:i], q.taskQueue[i+1:]...)
-				break
+		q.mu.Unlock()
+		return ErrTaskNotFound
+	}
+	err := renameSpeakers(task, names)
+	q.mu.Unlock()
+
+	if err == nil {
+		q.archiveTask(task)
+	}
+	return err
+}
+
+// renameSpeakers applies speaker names to a task, caller must hold q.mu
+func renameSpeakers(task *types.Task, names map[string]string) error {
+	if (task.Status != "completed" && task.Status != "failed") || task.Result.Transcript == "" {
+		return ErrNoTranscript
+	}
+
+	labels := make(map[string]bool)
+	for _, label := range processing.SpeakerLabels(task.Result.Transcript) {
+		labels[label] = true
+	}
+	for label, name := range names {
+		if !labels[label] {
+			return ErrUnknownSpeaker
+		}
+		if !speakers.ValidName(name) {
+			return ErrInvalidSpeaker
+		}
+		names[label] = strings.TrimSpace(name)
+	}
+
+	task.Result.Transcript = processing.RenameSpeakers(task.Result.Transcript, names)
+	task.Result.SpeakerEmbeddings = processing.RenameEmbeddings(task.Result.SpeakerEmbeddings, names)
+	// Action items are replaced rather than modified in place, copies handed out by GetTaskInfo share them
+	if len(task.Result.ActionItems) > 0 {
+		actionItems := make([]types.ActionItem, len(task.Result.ActionItems))
+		for i, item := range task.Result.ActionItems {
+			if name, ok := names[item.Owner]; ok {
+				item.Owner = name
 			}
+			actionItems[i] = item
 		}
+		task.Result.ActionItems = actionItems
 	}
+	return nil
 }
 
-// Garbage collect old completed/failed entries if unclaimed by clients
-func (q *Queue) GarbageCollectOldEntries() {
+// SpeakerEmbedding returns the voice embedding of a speaker label in a finished task
+func (q *Queue) SpeakerEmbedding(taskID string, label string) ([]float64, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.taskQueue) < 50 {
-		return // Not enough to garbage collect yet
+	task, ok := q.taskLookup[taskID]
+	if !ok {
+		return nil, ErrTaskNotFound
 	}
-	if q.taskQueue[0].Status == "waiting" || q.taskQueue[0].Status == "processing" {
-		return // No completed entries
+	embedding, ok := task.Result.SpeakerEmbeddings[label]
+	if !ok {
+		return nil, ErrUnknownSpeaker
 	}
-	var completedEntries []int
-	for _, entry := range q.taskQueue {
-		if entry.Status == "waiting" || entry.Status == "processing" {
-			break
-		} else {
-			idNum, err := strconv.Atoi(entry.ID)
-			if err != nil {
-				panic("error converting ID to integer")
-			}
-			completedEntries = append(completedEntries, idNum)
+	return embedding, nil
+}
+
+// processSummary runs a queued re-summarization and stores its outcome on the task
+func (q *Queue) processSummary(j job) {
+	task, summaryID := j.task, j.summaryID
+	q.mu.Lock()
+	summary := findSummary(task, summaryID)
+	if q.closed {
+		q.mu.Unlock()
+		return // failed by Checkpoint
+	}
+	summary.Status = "processing"
+	transcript := task.Result.Transcript
+	template, version, mode := summary.Template, summary.TemplateVersion, summary.Mode
+	meeting := task.Meeting
+	q.notify()
+	q.mu.Unlock()
+
+	ctx, span := startJob(j, "summary")
+	start := time.Now()
+	logger := q.logs.Open(task.ID)
+	logger.Info("Summarizing again", "summary_id", summaryID, "template", template, "version", version, "mode", mode)
+	prompt, _, err := q.templates.Render(template, version, meeting)
+	var generated types.Summary
+	if err == nil {
+		generated, err = q.processor.Summarize(ctx, logger, transcript, processing.SummaryOptions{Prompt: prompt, Mode: mode})
+	}
+	logFinished(logger, "Summary " + summaryID, err, start)
+	logger.Close()
+	tracing.End(span, err)
+
+	q.mu.Lock()
+	summary = findSummary(task, summaryID)
+	if err != nil {
+		summary.Status = "failed"
+		summary.ErrorMsg = err.Error()
+	} else {
+		summary.Status = "completed"
+		summary.Text = generated.Text
+		summary.ChunkSummaries = generated.ChunkSummaries
+	}
+	metrics.TaskDuration.WithLabelValues("summary", summary.Status).Observe(time.Since(start).Seconds())
+	q.notify()
+	q.mu.Unlock()
+
+	q.archiveTask(task)
+}
+
+// processTranscription runs a transcription-only task
+func (q *Queue) processTranscription(j job) {
+	task := j.task
+	q.mu.Lock()
+	if task.Status == "cancelled" || q.closed {
+		q.mu.Unlock()
+		return // deleted while waiting, or cancelled by Checkpoint
+	}
+	filename := task.FileName
+	task.Status = "processing"
+	wait := time.Since(task.CreatedAt)
+	metrics.QueueWait.Observe(wait.Seconds())
+	q.notify()
+	q.mu.Unlock()
+
+	ctx, span := startJob(j, "transcription")
+	logger := q.logs.Open(task.ID)
+	logger.Info("Processing transcription", "waited", wait.Round(time.Millisecond))
+	start := time.Now()
+	result, err := q.processor.Transcribe(ctx, logger, filename, j.language)
+	logFinished(logger, "Transcription", err, start)
+	logger.Close()
+	tracing.End(span, err)
+
+	q.mu.Lock()
+	if err != nil {
+		task.Status = "failed"
+	} else {
+		task.Status = "completed"
+	}
+	task.Result = result
+	q.setExpiry(task)
+	metrics.TaskDuration.WithLabelValues("transcription", task.Status).Observe(time.Since(start).Seconds())
+	q.notify()
+	q.mu.Unlock()
+}
+
+// archiveTask stores a snapshot of a completed task in the archive, caller must not hold q.mu
+func (q *Queue) archiveTask(task *types.Task) {
+	if q.archive == nil {
+		return
+	}
+	q.archiveMu.Lock()
+	defer q.archiveMu.Unlock()
+
+	q.mu.Lock()
+	if task.Status != "completed" || q.taskLookup[task.ID] != task {
+		q.mu.Unlock()
+		return // not finished, or deleted in the meantime
+	}
+	snapshot := copyTask(task)
+	q.mu.Unlock()
+
+	if err := q.archive.Save(*snapshot); err != nil {
+		slog.Error("Failed to archive task", "task_id", snapshot.ID, "err", err)
+	}
+}
+
+// logFinished logs the outcome of processing what, started at start
+func logFinished(logger *tasklog.Log, what string, err error, start time.Time) {
+	duration := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		logger.Error(what + " failed", "duration", duration, "err", err)
+	} else {
+		logger.Info(what + " completed", "duration", duration)
+	}
+}
+
+// copyTask returns a copy of a task that is safe to use without holding q.mu, caller must hold q.mu
+func copyTask(task *types.Task) *types.Task {
+	localTask := *task
+	localTask.Result.Summaries = append([]types.Summary(nil), task.Result.Summaries...)
+	return &localTask
+}
+
+// resolveSummaryOptions validates the requested template and mode, pinning the template version
+func (q *Queue) resolveSummaryOptions(template string, version int, mode string) (*prompts.Template, string, error) {
+	if mode == "" {
+		mode = processing.ModeMapReduce
+	}
+	if !processing.ValidMode(mode) {
+		return nil, "", ErrUnknownMode
+	}
+	tmpl, err := q.templates.Get(template, version)
+	if err != nil {
+		if err == prompts.ErrNotFound || err == prompts.ErrInvalidName {
+			return nil, "", ErrUnknownTemplate
 		}
+		return nil, "", err
 	}
-	if len(completedEntries) < 10 {
-		return // Not enough accumulated yet
+	return tmpl, mode, nil
+}
+
+// findSummary returns the summary with the given ID, caller must hold q.mu
+func findSummary(task *types.Task, summaryID string) *types.Summary {
+	for i := range task.Result.Summaries {
+		if task.Result.Summaries[i].ID == summaryID {
+			return &task.Result.Summaries[i]
+		}
 	}
-	entriesToCleanup := completedEntries[:len(completedEntries)/2]
-	for _, entryID := range entriesToCleanup {
-		// Remove from taskLookup
-		delete(q.taskLookup, entryID)
+	return nil
+}
+
+// hasPendingSummaries reports whether a task still has re-summarizations queued, caller must hold q.mu
+func hasPendingSummaries(task *types.Task) bool {
+	for _, summary := range task.Result.Summaries {
+		if summary.Status == "waiting" || summary.Status == "processing" {
+			return true
+		}
 	}
-	// Truncate entries from front of queue
-	q.taskQueue = q.taskQueue[len(entriesToCleanup):]
+	return false
 }
 
-// Returns the task info (status and result) by its ID
-func (q *Queue) GetTaskInfo(taskID int) (*types.Task, error) {
+// Returns the task info (status and result) by its ID, falling back to the archive
+// for completed tasks that were already removed from the queue
+func (q *Queue) GetTaskInfo(taskID string) (*types.Task, error) {
 	q.mu.Lock()
-	defer q.mu.Unlock()
 	task, exists := q.taskLookup[taskID]
+	if exists {
+		localTask := copyTask(task)
+		q.mu.Unlock()
+		return localTask, nil
+	}
+	q.mu.Unlock()
 
-	if !exists {
-		return nil, fmt.Errorf("task not found")
+	if q.archive != nil {
+		if archived, err := q.archive.Get(taskID); err == nil {
+			return archived, nil
+		}
 	}
-	localTask := *task
+	return nil, ErrTaskNotFound
+}
+
+// TaskLog returns the log of a task's processing, including the output of the commands it ran
+func (q *Queue) TaskLog(taskID string) ([]byte, error) {
+	data, err := q.logs.Read(taskID)
+	if err == tasklog.ErrNotFound {
+		return nil, ErrTaskNotFound
+	}
+	return data, err
+}
+
+// List returns copies of the tasks held in memory - pending, processing and finished ones
+// that have not expired yet - in upload order
+func (q *Queue) List() []*types.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tasks := make([]*types.Task, 0, len(q.taskQueue))
+	for _, task := range q.taskQueue {
+		tasks = append(tasks, copyTask(task))
+	}
+	return tasks
+}
 
-	return &localTask, nil
+// CountByStatus returns the number of tasks held in memory per status
+func (q *Queue) CountByStatus() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, task := range q.taskQueue {
+		counts[task.Status]++
+	}
+	return counts
 }
 
+// GetQueueLength returns the number of tasks waiting for or in processing
 func (q *Queue) GetQueueLength() (int, error) {
     q.mu.Lock()
     defer q.mu.Unlock()
 
-    return len(q.taskQueue), nil
+    pending := 0
+    for _, task := range q.taskQueue {
+        if task.Status == "waiting" || task.Status == "processing" {
+            pending++
+        }
+    }
+    return pending, nil
 }
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stanek-michal/go-ai-summarizer/internal/processing"
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+const transcript = "WEBVTT\n\n" +
+	"00:00.000 --> 00:05.000\n[SPEAKER_00]: The rollout moves to March.\n\n" +
+	"00:05.000 --> 00:09.000\n[SPEAKER_01]: I'll tell the customers.\n"
+
+func TestRenameSpeakers(t *testing.T) {
+	q := NewQueue(nil, nil, nil, RetentionPolicy{}, nil)
+	actionItems := []types.ActionItem{{Task: "Tell the customers", Owner: "SPEAKER_01"}, {Task: "Update the plan"}}
+	q.taskLookup["t1"] = &types.Task{ID: "t1", Status: "completed", Result: types.Result{
+		Transcript:        transcript,
+		ActionItems:       actionItems,
+		SpeakerEmbeddings: map[string][]float64{"SPEAKER_00": {1, 0}, "SPEAKER_01": {0, 1}},
+	}}
+	q.taskLookup["t2"] = &types.Task{ID: "t2", Status: "processing"}
+
+	tests := []struct {
+		name   string
+		taskID string
+		names  map[string]string
+		err    error
+	}{
+		{"unknown task", "nope", map[string]string{"SPEAKER_00": "Ana"}, ErrTaskNotFound},
+		{"no transcript yet", "t2", map[string]string{"SPEAKER_00": "Ana"}, ErrNoTranscript},
+		{"unknown label", "t1", map[string]string{"SPEAKER_02": "Ana"}, ErrUnknownSpeaker},
+		{"blank name", "t1", map[string]string{"SPEAKER_00": "  "}, ErrInvalidSpeaker},
+		{"name that breaks the cue format", "t1", map[string]string{"SPEAKER_00": "Ana]: x"}, ErrInvalidSpeaker},
+		{"rename", "t1", map[string]string{"SPEAKER_01": " Bob "}, nil},
+	}
+	for _, tt := range tests {
+		if err := q.RenameSpeakers(tt.taskID, tt.names); err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	task, err := q.GetTaskInfo("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := processing.SpeakerLabels(task.Result.Transcript); !reflect.DeepEqual(labels, []string{"SPEAKER_00", "Bob"}) {
+		t.Errorf("labels after renaming = %v", labels)
+	}
+	if task.Result.ActionItems[0].Owner != "Bob" || task.Result.ActionItems[1].Owner != "" {
+		t.Errorf("action items after renaming = %+v", task.Result.ActionItems)
+	}
+	if actionItems[0].Owner != "SPEAKER_01" {
+		t.Error("the action items handed out before the rename were modified")
+	}
+	if _, err := q.SpeakerEmbedding("t1", "Bob"); err != nil {
+		t.Errorf("embedding of the renamed speaker: %v", err)
+	}
+	if _, err := q.SpeakerEmbedding("t1", "SPEAKER_01"); err != ErrUnknownSpeaker {
+		t.Errorf("embedding of the old label: err = %v, want ErrUnknownSpeaker", err)
+	}
+}
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/stanek-michal/go-ai-summarizer/internal/archive"
+	"github.com/stanek-michal/go-ai-summarizer/internal/processing"
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// How often expired results are removed
+const retentionInterval = time.Minute
+
+// RetentionPolicy controls how long results of finished tasks are kept
+type RetentionPolicy struct {
+	TTL      time.Duration // default (and maximum) lifetime of a result after the task finishes, 0 keeps results until deleted
+	MaxBytes int64         // archive size limit, the oldest meetings are deleted first when exceeded, 0 for no limit
+}
+
+// StartRetention removes expired results periodically, runs in infinite loop
+func (q *Queue) StartRetention() {
+	for {
+		q.ApplyRetention()
+		time.Sleep(retentionInterval)
+	}
+}
+
+// ApplyRetention deletes finished tasks past their expiry time, then the oldest
+// archived meetings while the archive is over its size limit
+func (q *Queue) ApplyRetention() {
+	now := time.Now()
+
+	q.mu.Lock()
+	var expired []string
+	for _, task := range q.taskQueue {
+		if IsFinished(task) && task.ExpiresAt != nil && now.After(*task.ExpiresAt) {
+			expired = append(expired, task.ID)
+		}
+	}
+	q.mu.Unlock()
+	for _, taskID := range expired {
+		if err := q.Delete(taskID); err != nil && err != ErrTaskNotFound && err != ErrTaskBusy {
+			slog.Error("Failed to delete expired task", "task_id", taskID, "err", err)
+		}
+	}
+
+	if q.archive == nil {
+		return
+	}
+	var size int64
+	var kept []archive.Meeting
+	for _, meeting := range q.archive.List() {
+		expiresAt := meeting.ExpiresAt
+		if expiresAt == nil && q.retention.TTL > 0 {
+			// Archived without an expiry (before a TTL was configured)
+			archivedExpiry := meeting.ArchivedAt.Add(q.retention.TTL)
+			expiresAt = &archivedExpiry
+		}
+		if expiresAt != nil && now.After(*expiresAt) {
+			q.deleteArchived(meeting.TaskID)
+			continue
+		}
+		size += meeting.Size
+		kept = append(kept, meeting)
+	}
+	if q.retention.MaxBytes <= 0 || size <= q.retention.MaxBytes {
+		return
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].ArchivedAt.Before(kept[j].ArchivedAt) })
+	for _, meeting := range kept {
+		if size <= q.retention.MaxBytes {
+			break
+		}
+		slog.Info("Archive over size limit, deleting oldest meeting", "task_id", meeting.TaskID, "max_bytes", q.retention.MaxBytes)
+		if q.deleteArchived(meeting.TaskID) {
+			size -= meeting.Size
+		}
+	}
+}
+
+// Delete removes a finished task with its archived meeting and log, or cancels a task
+// that is still waiting to be processed
+func (q *Queue) Delete(taskID string) error {
+	err := q.delete(taskID)
+	if err == nil || err == ErrTaskNotFound {
+		if err := q.logs.Delete(taskID); err != nil {
+			slog.Error("Failed to delete task log", "task_id", taskID, "err", err)
+		}
+	}
+	return err
+}
+
+// delete removes a task and its archived meeting
+func (q *Queue) delete(taskID string) error {
+	q.mu.Lock()
+	task, inQueue := q.taskLookup[taskID]
+	cancelled := ""
+	if inQueue {
+		if task.Status == "waiting" {
+			task.Status = "cancelled" // skipped by StartProcessing
+			cancelled = task.FileName
+		} else if !IsFinished(task) {
+			q.mu.Unlock()
+			return ErrTaskBusy
+		}
+		delete(q.taskLookup, taskID)
+		for i, t := range q.taskQueue {
+			if t == task {
+				q.taskQueue = append(q.taskQueue[:i], q.taskQueue[i+1:]...)
+				break
+			}
+		}
+		q.notify()
+	}
+	q.mu.Unlock()
+
+	if cancelled != "" {
+		processing.CleanUpUserFiles(cancelled, "")
+		return nil
+	}
+	if q.archive != nil {
+		q.archiveMu.Lock()
+		defer q.archiveMu.Unlock()
+		err := q.archive.Delete(taskID)
+		if err == nil || (err == archive.ErrNotFound && inQueue) {
+			return nil
+		}
+		if err != archive.ErrNotFound {
+			return err
+		}
+	} else if inQueue {
+		return nil
+	}
+	return ErrTaskNotFound
+}
+
+// deleteArchived deletes an archived meeting that is no longer in the queue, reports whether it was deleted
+func (q *Queue) deleteArchived(taskID string) bool {
+	if err := q.Delete(taskID); err != nil {
+		slog.Error("Failed to delete archived meeting", "task_id", taskID, "err", err)
+		return false
+	}
+	return true
+}
+
+// setExpiry sets when the result of a finished task expires, caller must hold q.mu
+func (q *Queue) setExpiry(task *types.Task) {
+	ttl := task.TTL
+	if ttl == 0 {
+		ttl = q.retention.TTL
+	}
+	if ttl == 0 {
+		return
+	}
+	expiresAt := time.Now().Add(ttl)
+	task.ExpiresAt = &expiresAt
+}
+
+// IsFinished reports whether a task is done and has no re-summarizations queued. Tasks of the
+// queue are checked with q.mu held, copies from GetTaskInfo anywhere.
+func IsFinished(task *types.Task) bool {
+	return (task.Status == "completed" || task.Status == "failed") && !hasPendingSummaries(task)
+}
@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stanek-michal/go-ai-summarizer/internal/archive"
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// writeArchived writes a meeting to an archive directory as if it was archived at archivedAt
+func writeArchived(t *testing.T, dir string, task types.Task, archivedAt time.Time) {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"Task": task, "ArchivedAt": archivedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, task.ID + ".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// archivedIDs returns the IDs of the archived meetings, sorted
+func archivedIDs(meetings *archive.Archive) []string {
+	var ids []string
+	for _, meeting := range meetings.List() {
+		ids = append(ids, meeting.TaskID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestApplyRetentionTTL(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	writeArchived(t, dir, types.Task{ID: "expired", Status: "completed", ExpiresAt: &past}, now)
+	writeArchived(t, dir, types.Task{ID: "kept", Status: "completed", ExpiresAt: &future}, now.Add(-48 * time.Hour))
+	// Archived before a TTL was configured, they expire a TTL after they were archived
+	writeArchived(t, dir, types.Task{ID: "old", Status: "completed"}, now.Add(-25 * time.Hour))
+	writeArchived(t, dir, types.Task{ID: "recent", Status: "completed"}, now.Add(-23 * time.Hour))
+	meetings, err := archive.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewQueue(nil, nil, meetings, RetentionPolicy{TTL: 24 * time.Hour}, nil)
+	tasks := []*types.Task{
+		{ID: "done", Status: "completed", ExpiresAt: &past},
+		{ID: "failed", Status: "failed", ExpiresAt: &past},
+		{ID: "fresh", Status: "completed", ExpiresAt: &future},
+		// Not finished yet, an expiry is only set once they are
+		{ID: "resummarizing", Status: "completed", ExpiresAt: &past, Result: types.Result{
+			Summaries: []types.Summary{{ID: "2", Status: "processing"}},
+		}},
+		{ID: "running", Status: "processing", ExpiresAt: &past},
+	}
+	for _, task := range tasks {
+		q.taskLookup[task.ID] = task
+		q.taskQueue = append(q.taskQueue, task)
+	}
+
+	q.ApplyRetention()
+
+	var left []string
+	for _, task := range q.List() {
+		left = append(left, task.ID)
+	}
+	if want := []string{"fresh", "resummarizing", "running"}; !reflect.DeepEqual(left, want) {
+		t.Errorf("tasks left = %v, want %v", left, want)
+	}
+	if ids, want := archivedIDs(meetings), []string{"kept", "recent"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("archived meetings left = %v, want %v", ids, want)
+	}
+}
+
+func TestApplyRetentionMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeArchived(t, dir, types.Task{ID: "first", Status: "completed"}, now.Add(-3 * time.Hour))
+	writeArchived(t, dir, types.Task{ID: "second", Status: "completed"}, now.Add(-2 * time.Hour))
+	writeArchived(t, dir, types.Task{ID: "third", Status: "completed"}, now.Add(-time.Hour))
+	meetings, err := archive.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, meeting := range meetings.List() {
+		total += meeting.Size
+	}
+
+	// Under the limit nothing is deleted
+	q := NewQueue(nil, nil, meetings, RetentionPolicy{MaxBytes: total}, nil)
+	q.ApplyRetention()
+	if ids := archivedIDs(meetings); len(ids) != 3 {
+		t.Fatalf("archived meetings = %v, want all three", ids)
+	}
+
+	// Renaming the oldest meeting keeps its place, it is still deleted first
+	renamed, err := meetings.Get("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed.Meeting.Title = "Renamed"
+	if err := meetings.Save(*renamed); err != nil {
+		t.Fatal(err)
+	}
+	q.retention.MaxBytes = total - 1
+	q.ApplyRetention()
+	if ids, want := archivedIDs(meetings), []string{"second", "third"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("archived meetings = %v, want %v", ids, want)
+	}
+
+	// As many as needed go, oldest first
+	q.retention.MaxBytes = 1
+	q.ApplyRetention()
+	if ids := archivedIDs(meetings); len(ids) != 0 {
+		t.Errorf("archived meetings = %v, want none left over a 1 byte limit", ids)
+	}
+}
@@ -0,0 +1,255 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// Name of the file in the checkpoint directory that lists the saved tasks
+const checkpointFile = "tasks.json"
+
+// checkpointedTask is a task saved by Checkpoint, with the fields types.Task leaves out of JSON
+type checkpointedTask struct {
+	Task      types.Task
+	TokenHash string
+	TTL       time.Duration
+}
+
+// Close stops the queue from taking new work for shutdown: new tasks and summaries are
+// refused with ErrShuttingDown and queued jobs are no longer started
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notify()
+}
+
+// Closed reports whether the queue has been closed for shutdown
+func (q *Queue) Closed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Wait blocks until no task or summary is being processed, or ctx is done
+func (q *Queue) Wait(ctx context.Context) error {
+	for {
+		changed := q.Changed()
+		if !q.busy() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// busy reports whether a task or summary is being processed
+func (q *Queue) busy() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, task := range q.taskQueue {
+		if task.Status == "processing" {
+			return true
+		}
+		for _, summary := range task.Result.Summaries {
+			if summary.Status == "processing" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checkpoint saves the uploads that have not been processed yet, including the one being
+// processed, to dir for Restore to queue them again after a restart. Interrupted tasks start
+// over. Transcription-only tasks are cancelled, their requests do not survive a restart,
+// and queued re-summarizations fail. Call Close first.
+func (q *Queue) Checkpoint(dir string) error {
+	q.mu.Lock()
+	var saved []checkpointedTask
+	var inUse []bool
+	var cancelled []string
+	var failed []*types.Task
+	for _, task := range q.taskQueue {
+		if task.Transcription {
+			if task.Status == "waiting" {
+				cancelled = append(cancelled, task.ID)
+			}
+			continue
+		}
+		if task.Status == "waiting" || task.Status == "processing" {
+			saved = append(saved, checkpointedTask{Task: *copyTask(task), TokenHash: task.TokenHash, TTL: task.TTL})
+			inUse = append(inUse, task.Status == "processing")
+			continue
+		}
+		if failWaitingSummaries(task) {
+			failed = append(failed, task)
+		}
+	}
+	q.notify()
+	q.mu.Unlock()
+
+	for _, taskID := range cancelled {
+		q.Delete(taskID)
+	}
+	for _, task := range failed {
+		q.archiveTask(task)
+	}
+	if len(saved) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := range saved {
+		task := &saved[i].Task
+		// Named after the task, the cleanup of the original upload removes files by its name prefix
+		path := filepath.Join(dir, task.ID + filepath.Ext(task.FileName))
+		// The upload of the task being processed is still in use, it is cleaned up when it fails
+		if err := moveFile(task.FileName, path, inUse[i]); err != nil {
+			return err
+		}
+		task.FileName = path
+		task.Status = "waiting"
+		task.Result = types.Result{Summaries: task.Result.Summaries[:1]}
+		task.Result.Summaries[0].Status = "waiting"
+	}
+
+	// The task being processed may have finished while the uploads were saved, it is archived
+	// then and must not run again after the restart
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	unfinished := saved[:0]
+	for _, s := range saved {
+		if task := q.taskLookup[s.Task.ID]; task != nil && (task.Status == "waiting" || task.Status == "processing") {
+			unfinished = append(unfinished, s)
+		} else {
+			os.Remove(s.Task.FileName)
+		}
+	}
+	if len(unfinished) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(unfinished, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, checkpointFile), data, 0600); err != nil {
+		return err
+	}
+	slog.Info("Checkpointed unfinished tasks", "count", len(unfinished), "dir", dir)
+	return nil
+}
+
+// failWaitingSummaries fails the queued re-summarizations of a task, caller must hold q.mu
+func failWaitingSummaries(task *types.Task) bool {
+	found := false
+	for i := range task.Result.Summaries {
+		if summary := &task.Result.Summaries[i]; summary.Status == "waiting" {
+			summary.Status = "failed"
+			summary.ErrorMsg = ErrShuttingDown.Error()
+			found = true
+		}
+	}
+	return found
+}
+
+// moveFile moves src to dst, or copies it if keep is set
+func moveFile(src string, dst string, keep bool) error {
+	if !keep && os.Rename(src, dst) == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if !keep {
+		return os.Remove(src)
+	}
+	return nil
+}
+
+// restoreUpload moves a checkpointed upload to a new temp file and returns its path
+func restoreUpload(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "upload-*" + filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	if err := moveFile(path, file.Name(), false); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// Restore queues the tasks saved by Checkpoint in dir again, with their IDs and tokens.
+// Tasks whose upload has disappeared are dropped.
+func (q *Queue) Restore(dir string) error {
+	path := filepath.Join(dir, checkpointFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []checkpointedTask
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	var jobs []job
+	q.mu.Lock()
+	for _, s := range saved {
+		// The upload goes back to the temp directory like a new one
+		fileName, err := restoreUpload(s.Task.FileName)
+		if err != nil {
+			slog.Warn("Dropping checkpointed task", "task_id", s.Task.ID, "err", err)
+			continue
+		}
+		task := s.Task
+		task.FileName = fileName
+		task.TokenHash = s.TokenHash
+		task.TTL = s.TTL
+		q.taskQueue = append(q.taskQueue, &task)
+		q.taskLookup[task.ID] = &task
+		jobs = append(jobs, job{task: &task, queuedAt: task.CreatedAt})
+	}
+	q.mu.Unlock()
+
+	// Send the jobs in their original order
+	go func() {
+		for _, j := range jobs {
+			q.processing <- j
+		}
+	}()
+	slog.Info("Restored checkpointed tasks", "count", len(jobs), "dir", dir)
+	return os.Remove(path)
+}
@@ -0,0 +1,182 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// writeUpload writes an upload to dir and returns its path
+func writeUpload(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir) // restored uploads
+	checkpointDir := filepath.Join(dir, "checkpoint")
+	start := time.Now().Add(-time.Hour)
+
+	q := NewQueue(nil, nil, nil, RetentionPolicy{}, nil)
+	tasks := []*types.Task{
+		{ID: "done", Status: "completed", FileName: writeUpload(t, dir, "upload-1.wav", "done"), Result: types.Result{
+			Summary:   "Done.",
+			Summaries: []types.Summary{{ID: "1", Status: "completed", Text: "Done."}},
+		}},
+		{ID: "running", Status: "processing", FileName: writeUpload(t, dir, "upload-2.wav", "running"), TokenHash: "hash-running", Result: types.Result{
+			Transcript: "WEBVTT",
+			Summaries:  []types.Summary{{ID: "1", Status: "processing", Template: "default"}},
+		}},
+		{ID: "first", Status: "waiting", FileName: writeUpload(t, dir, "upload-3.mp4", "first"), TokenHash: "hash-first", TTL: time.Hour, Result: types.Result{
+			Summaries: []types.Summary{{ID: "1", Status: "waiting", Template: "default"}},
+		}},
+		{ID: "second", Status: "waiting", FileName: writeUpload(t, dir, "upload-4.wav", "second"), TokenHash: "hash-second", Result: types.Result{
+			Summaries: []types.Summary{{ID: "1", Status: "waiting", Template: "brief"}},
+		}},
+	}
+	for i, task := range tasks {
+		task.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		q.taskQueue = append(q.taskQueue, task)
+		q.taskLookup[task.ID] = task
+	}
+	q.Close()
+	if err := q.Checkpoint(checkpointDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The upload being processed is still in use, waiting ones are moved away
+	if _, err := os.Stat(tasks[1].FileName); err != nil {
+		t.Errorf("the upload of the running task was removed: %v", err)
+	}
+	for _, task := range tasks[2:] {
+		if _, err := os.Stat(task.FileName); !os.IsNotExist(err) {
+			t.Errorf("the upload of %s was not moved to the checkpoint", task.ID)
+		}
+	}
+
+	restored := NewQueue(nil, nil, nil, RetentionPolicy{}, nil)
+	if err := restored.Restore(checkpointDir); err != nil {
+		t.Fatal(err)
+	}
+	// Restoring again finds nothing
+	if err := restored.Restore(checkpointDir); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		id        string
+		tokenHash string
+		ttl       time.Duration
+		template  string
+		upload    string
+	}{
+		{"running", "hash-running", 0, "default", "running"},
+		{"first", "hash-first", time.Hour, "default", "first"},
+		{"second", "hash-second", 0, "brief", "second"},
+	}
+	if len(restored.taskQueue) != len(want) {
+		t.Fatalf("restored %d tasks, want %d", len(restored.taskQueue), len(want))
+	}
+	for i, w := range want {
+		var j job
+		select {
+		case j = <-restored.processing:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("job %d was not queued", i)
+		}
+		task := j.task
+		if task.ID != w.id || restored.taskQueue[i] != task || restored.taskLookup[w.id] != task {
+			t.Fatalf("job %d is %s, want %s in queue order", i, task.ID, w.id)
+		}
+		if task.Status != "waiting" || task.TokenHash != w.tokenHash || task.TTL != w.ttl || !task.CreatedAt.Equal(tasks[i+1].CreatedAt) {
+			t.Errorf("%s: restored as %+v", w.id, task)
+		}
+		// Interrupted work starts over with its initial summary
+		if task.Result.Transcript != "" || len(task.Result.Summaries) != 1 || task.Result.Summaries[0].Status != "waiting" || task.Result.Summaries[0].Template != w.template {
+			t.Errorf("%s: restored result %+v", w.id, task.Result)
+		}
+		if data, err := os.ReadFile(task.FileName); err != nil || string(data) != w.upload {
+			t.Errorf("%s: upload %q, %v", w.id, data, err)
+		}
+	}
+	select {
+	case j := <-restored.processing:
+		t.Errorf("%s was queued twice", j.task.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := os.Stat(filepath.Join(checkpointDir, checkpointFile)); !os.IsNotExist(err) {
+		t.Errorf("the checkpoint was kept after restoring: %v", err)
+	}
+}
+
+func TestCheckpointSkipsFinished(t *testing.T) {
+	dir := t.TempDir()
+	q := NewQueue(nil, nil, nil, RetentionPolicy{}, nil)
+	q.taskQueue = []*types.Task{{ID: "done", Status: "completed", FileName: writeUpload(t, dir, "upload-1.wav", "done")}}
+	q.taskLookup["done"] = q.taskQueue[0]
+	q.Close()
+	if err := q.Checkpoint(filepath.Join(dir, "checkpoint")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "checkpoint")); !os.IsNotExist(err) {
+		t.Errorf("a checkpoint was written without unfinished tasks: %v", err)
+	}
+}
+
+func TestCheckpointTaskFinishing(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	checkpointDir := filepath.Join(dir, "checkpoint")
+	// The upload of the running task is a pipe, so the task finishes while it is copied
+	upload := filepath.Join(dir, "upload-1.wav")
+	if err := syscall.Mkfifo(upload, 0644); err != nil {
+		t.Fatal(err)
+	}
+	q := NewQueue(nil, nil, nil, RetentionPolicy{}, nil)
+	running := &types.Task{ID: "running", Status: "processing", FileName: upload, Result: types.Result{
+		Summaries: []types.Summary{{ID: "1", Status: "processing"}},
+	}}
+	waiting := &types.Task{ID: "waiting", Status: "waiting", FileName: writeUpload(t, dir, "upload-2.wav", "waiting"), Result: types.Result{
+		Summaries: []types.Summary{{ID: "1", Status: "waiting"}},
+	}}
+	q.taskQueue = []*types.Task{running, waiting}
+	q.taskLookup["running"], q.taskLookup["waiting"] = running, waiting
+	q.Close()
+
+	go func() {
+		pipe, err := os.OpenFile(upload, os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		pipe.Write([]byte("running"))
+		q.mu.Lock()
+		running.Status = "completed"
+		running.Result.Summaries[0].Status = "completed"
+		q.mu.Unlock()
+		pipe.Close()
+	}()
+	if err := q.Checkpoint(checkpointDir); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewQueue(nil, nil, nil, RetentionPolicy{}, nil)
+	if err := restored.Restore(checkpointDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.taskQueue) != 1 || restored.taskQueue[0].ID != "waiting" {
+		t.Errorf("restored %d tasks, want only the waiting one", len(restored.taskQueue))
+	}
+	if _, err := os.Stat(filepath.Join(checkpointDir, "running.wav")); !os.IsNotExist(err) {
+		t.Errorf("the copy of the finished task's upload was kept: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package queue
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/stanek-michal/go-ai-summarizer/internal/processing"
+	"github.com/stanek-michal/go-ai-summarizer/internal/prompts"
+	"github.com/stanek-michal/go-ai-summarizer/internal/tasklog"
+	"github.com/stanek-michal/go-ai-summarizer/pkg/types"
+)
+
+// fakePython stands in for python: "-m llama_cpp.server" idles while the test serves the
+// server's API, the summarizer script writes one successful and one failed LLM call to its
+// --usage-out file and prints the summary
+const fakePython = `#!/bin/sh
+if [ "$1" = "-m" ]; then
+	exec sleep 600
+fi
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--usage-out) usage="$2"; shift ;;
+	--chunks-out) chunks="$2"; shift ;;
+	esac
+	shift
+done
+now=$(date +%s)
+cat > "$usage" <<EOF
+{"prompt_tokens": 30, "completion_tokens": 8, "calls": [
+	{"name": "chunk 1", "start": $((now - 3)), "end": $((now - 2)), "prompt_tokens": 20, "completion_tokens": 8},
+	{"name": "chunk 2", "start": $((now - 2)), "end": $((now - 1)), "prompt_tokens": 10, "error": "context overflow"}
+]}
+EOF
+echo '["The rollout moves."]' > "$chunks"
+echo "The rollout moves to March."
+`
+
+func TestSummarySpans(t *testing.T) {
+	// The test serves the API of the llama server on a free port
+	llamaAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer llamaAPI.Close()
+	port := llamaAPI.Listener.Addr().(*net.TCPAddr).Port
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "python"), []byte(fakePython), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin + string(os.PathListSeparator) + os.Getenv("PATH"))
+	t.Setenv("TMPDIR", dir)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	otel.SetTracerProvider(provider)
+
+	templates, err := prompts.NewStore(filepath.Join(dir, "templates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := templates.Create(prompts.DefaultTemplate, "Summarize the meeting."); err != nil {
+		t.Fatal(err)
+	}
+	logs, err := tasklog.NewStore(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm := processing.NewLLM(0, port)
+	defer llm.Close()
+	q := NewQueue(processing.NewProcessor(nil, llm), templates, nil, RetentionPolicy{}, logs)
+	q.taskLookup["t1"] = &types.Task{ID: "t1", Status: "completed", Result: types.Result{
+		Transcript: "WEBVTT\n\n00:00.000 --> 00:05.000\n[Ana]: The rollout moves to March.\n\n",
+		Summaries:  []types.Summary{{ID: "1", Status: "completed"}},
+	}}
+	go q.StartProcessing()
+
+	// The request that queued the summary
+	ctx, request := provider.Tracer("test").Start(context.Background(), "POST /tasks/{id}/summaries")
+	queuedAt := time.Now()
+	changed := q.Changed()
+	if _, err := q.EnqueueSummary(ctx, "t1", prompts.DefaultTemplate, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	request.End()
+	timeout := time.After(10 * time.Second)
+	for {
+		task, _ := q.GetTaskInfo("t1")
+		if status := task.Result.Summaries[1].Status; status == "completed" || status == "failed" {
+			if status == "failed" {
+				t.Fatalf("summary failed: %s", task.Result.Summaries[1].ErrorMsg)
+			}
+			break
+		}
+		select {
+		case <-changed:
+			changed = q.Changed()
+		case <-timeout:
+			t.Fatal("the summary was not processed")
+		}
+	}
+
+	spans := make(map[string][]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = append(spans[span.Name], span)
+	}
+	one := func(name string) tracetest.SpanStub {
+		t.Helper()
+		if len(spans[name]) != 1 {
+			t.Fatalf("got %d %s spans, want 1", len(spans[name]), name)
+		}
+		return spans[name][0]
+	}
+	requestSpan := one("POST /tasks/{id}/summaries")
+	wait, process, stage := one("queue.wait"), one("task.process"), one("summarize")
+	childOf := func(child tracetest.SpanStub, parent tracetest.SpanStub) {
+		t.Helper()
+		if child.Parent.SpanID() != parent.SpanContext.SpanID() || child.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+			t.Errorf("%s span is not a child of %s", child.Name, parent.Name)
+		}
+	}
+
+	// The job joins the trace of the request, waiting and processing are siblings under it
+	childOf(wait, requestSpan)
+	childOf(process, requestSpan)
+	if wait.StartTime.Before(queuedAt) || wait.EndTime.After(process.StartTime) {
+		t.Errorf("queue.wait from %v to %v should cover the time before processing started at %v", wait.StartTime, wait.EndTime, process.StartTime)
+	}
+	for _, span := range []tracetest.SpanStub{wait, process} {
+		if kind := stringAttribute(span, "task.kind"); kind != "summary" {
+			t.Errorf("%s task.kind = %q, want summary", span.Name, kind)
+		}
+		if id := stringAttribute(span, "task.id"); id != "t1" {
+			t.Errorf("%s task.id = %q, want t1", span.Name, id)
+		}
+	}
+
+	// Stages run under the task, LLM calls under their stage
+	childOf(stage, process)
+	childOf(one("llm.start"), stage)
+	childOf(one("cleanup"), process)
+	completions := spans["llm.completion"]
+	if len(completions) != 2 {
+		t.Fatalf("got %d llm.completion spans, want 2", len(completions))
+	}
+	for _, completion := range completions {
+		childOf(completion, stage)
+		if completion.EndTime.Sub(completion.StartTime) != time.Second {
+			t.Errorf("%s lasted %v, want the 1s timed by the script", completion.Name, completion.EndTime.Sub(completion.StartTime))
+		}
+		switch call := stringAttribute(completion, "llm.call"); call {
+		case "chunk 1":
+			if completion.Status.Code == codes.Error {
+				t.Error("the successful LLM call is marked failed")
+			}
+		case "chunk 2":
+			if completion.Status.Code != codes.Error || completion.Status.Description != "context overflow" {
+				t.Errorf("failed LLM call status = %+v", completion.Status)
+			}
+		default:
+			t.Errorf("unexpected llm.call %q", call)
+		}
+	}
+}
+
+func stringAttribute(span tracetest.SpanStub, key attribute.Key) string {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,331 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: summarizer/v1/summarizer.proto
+
+package summarizerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Summarizer_Submit_FullMethodName    = "/summarizer.v1.Summarizer/Submit"
+	Summarizer_GetTask_FullMethodName   = "/summarizer.v1.Summarizer/GetTask"
+	Summarizer_WatchTask_FullMethodName = "/summarizer.v1.Summarizer/WatchTask"
+	Summarizer_Cancel_FullMethodName    = "/summarizer.v1.Summarizer/Cancel"
+	Summarizer_ListTasks_FullMethodName = "/summarizer.v1.Summarizer/ListTasks"
+)
+
+// SummarizerClient is the client API for Summarizer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SummarizerClient interface {
+	// Submit uploads a recording: the first message carries the metadata, the following
+	// ones consecutive chunks of the file.
+	Submit(ctx context.Context, opts ...grpc.CallOption) (Summarizer_SubmitClient, error)
+	GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// WatchTask sends the task whenever its status or one of its summaries changes and
+	// ends when it has finished.
+	WatchTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (Summarizer_WatchTaskClient, error)
+	// Cancel removes a task that is still waiting to be processed, or deletes a finished one.
+	Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error)
+	// ListTasks returns the tasks the user may read, without results.
+	ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error)
+}
+
+type summarizerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSummarizerClient(cc grpc.ClientConnInterface) SummarizerClient {
+	return &summarizerClient{cc}
+}
+
+func (c *summarizerClient) Submit(ctx context.Context, opts ...grpc.CallOption) (Summarizer_SubmitClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Summarizer_ServiceDesc.Streams[0], Summarizer_Submit_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &summarizerSubmitClient{stream}
+	return x, nil
+}
+
+type Summarizer_SubmitClient interface {
+	Send(*SubmitRequest) error
+	CloseAndRecv() (*SubmitResponse, error)
+	grpc.ClientStream
+}
+
+type summarizerSubmitClient struct {
+	grpc.ClientStream
+}
+
+func (x *summarizerSubmitClient) Send(m *SubmitRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *summarizerSubmitClient) CloseAndRecv() (*SubmitResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(SubmitResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *summarizerClient) GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, Summarizer_GetTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *summarizerClient) WatchTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (Summarizer_WatchTaskClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Summarizer_ServiceDesc.Streams[1], Summarizer_WatchTask_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &summarizerWatchTaskClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Summarizer_WatchTaskClient interface {
+	Recv() (*Task, error)
+	grpc.ClientStream
+}
+
+type summarizerWatchTaskClient struct {
+	grpc.ClientStream
+}
+
+func (x *summarizerWatchTaskClient) Recv() (*Task, error) {
+	m := new(Task)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *summarizerClient) Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error) {
+	out := new(CancelResponse)
+	err := c.cc.Invoke(ctx, Summarizer_Cancel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *summarizerClient) ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error) {
+	out := new(ListTasksResponse)
+	err := c.cc.Invoke(ctx, Summarizer_ListTasks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SummarizerServer is the server API for Summarizer service.
+// All implementations must embed UnimplementedSummarizerServer
+// for forward compatibility
+type SummarizerServer interface {
+	// Submit uploads a recording: the first message carries the metadata, the following
+	// ones consecutive chunks of the file.
+	Submit(Summarizer_SubmitServer) error
+	GetTask(context.Context, *GetTaskRequest) (*Task, error)
+	// WatchTask sends the task whenever its status or one of its summaries changes and
+	// ends when it has finished.
+	WatchTask(*GetTaskRequest, Summarizer_WatchTaskServer) error
+	// Cancel removes a task that is still waiting to be processed, or deletes a finished one.
+	Cancel(context.Context, *CancelRequest) (*CancelResponse, error)
+	// ListTasks returns the tasks the user may read, without results.
+	ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error)
+	mustEmbedUnimplementedSummarizerServer()
+}
+
+// UnimplementedSummarizerServer must be embedded to have forward compatible implementations.
+type UnimplementedSummarizerServer struct {
+}
+
+func (UnimplementedSummarizerServer) Submit(Summarizer_SubmitServer) error {
+	return status.Errorf(codes.Unimplemented, "method Submit not implemented")
+}
+func (UnimplementedSummarizerServer) GetTask(context.Context, *GetTaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTask not implemented")
+}
+func (UnimplementedSummarizerServer) WatchTask(*GetTaskRequest, Summarizer_WatchTaskServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTask not implemented")
+}
+func (UnimplementedSummarizerServer) Cancel(context.Context, *CancelRequest) (*CancelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedSummarizerServer) ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTasks not implemented")
+}
+func (UnimplementedSummarizerServer) mustEmbedUnimplementedSummarizerServer() {}
+
+// UnsafeSummarizerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SummarizerServer will
+// result in compilation errors.
+type UnsafeSummarizerServer interface {
+	mustEmbedUnimplementedSummarizerServer()
+}
+
+func RegisterSummarizerServer(s grpc.ServiceRegistrar, srv SummarizerServer) {
+	s.RegisterService(&Summarizer_ServiceDesc, srv)
+}
+
+func _Summarizer_Submit_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SummarizerServer).Submit(&summarizerSubmitServer{stream})
+}
+
+type Summarizer_SubmitServer interface {
+	SendAndClose(*SubmitResponse) error
+	Recv() (*SubmitRequest, error)
+	grpc.ServerStream
+}
+
+type summarizerSubmitServer struct {
+	grpc.ServerStream
+}
+
+func (x *summarizerSubmitServer) SendAndClose(m *SubmitResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *summarizerSubmitServer) Recv() (*SubmitRequest, error) {
+	m := new(SubmitRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Summarizer_GetTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SummarizerServer).GetTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Summarizer_GetTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SummarizerServer).GetTask(ctx, req.(*GetTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Summarizer_WatchTask_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetTaskRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SummarizerServer).WatchTask(m, &summarizerWatchTaskServer{stream})
+}
+
+type Summarizer_WatchTaskServer interface {
+	Send(*Task) error
+	grpc.ServerStream
+}
+
+type summarizerWatchTaskServer struct {
+	grpc.ServerStream
+}
+
+func (x *summarizerWatchTaskServer) Send(m *Task) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Summarizer_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SummarizerServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Summarizer_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SummarizerServer).Cancel(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Summarizer_ListTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SummarizerServer).ListTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Summarizer_ListTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SummarizerServer).ListTasks(ctx, req.(*ListTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Summarizer_ServiceDesc is the grpc.ServiceDesc for Summarizer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Summarizer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "summarizer.v1.Summarizer",
+	HandlerType: (*SummarizerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTask",
+			Handler:    _Summarizer_GetTask_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Summarizer_Cancel_Handler,
+		},
+		{
+			MethodName: "ListTasks",
+			Handler:    _Summarizer_ListTasks_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Submit",
+			Handler:       _Summarizer_Submit_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchTask",
+			Handler:       _Summarizer_WatchTask_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "summarizer/v1/summarizer.proto",
+}
@@ -1,16 +1,120 @@
 package types
 
-// Result contains a full transcript and a text summary of it
+import "time"
+
+// Meeting holds optional details about the recorded meeting, available to summary templates
+type Meeting struct {
+        Title       string
+        Date        string
+        Attendees   []string
+}
+
+// Summary is a single summarization run over a transcript with a given template.
+// ChunkSummaries holds the per-chunk summaries the final text was built from.
+type Summary struct {
+        ID              string
+        Template        string
+        TemplateVersion int
+        Mode            string
+        Status          string
+        Text            string
+        ChunkSummaries  []string
+        ErrorMsg        string
+}
+
+// ActionItem is a task someone took on in the meeting, Timestamp refers to the transcript
+type ActionItem struct {
+        Owner       string
+        Task        string
+        DueDate     string
+        Timestamp   string
+}
+
+// Decision is a decision made in the meeting
+type Decision struct {
+        Text        string
+        Timestamp   string
+}
+
+// OpenQuestion is a question or issue raised in the meeting but left unresolved
+type OpenQuestion struct {
+        Text        string
+        Timestamp   string
+}
+
+// Result contains a full transcript and a text summary of it.
+// Summary holds the initial summary, Summaries all summaries generated so far.
+// Action items, decisions and open questions are extracted along with the initial summary.
 type Result struct {
-        Transcript  string
-        Summary     string
-        ErrorMsg    string
+        Transcript          string
+        Summary             string
+        Summaries           []Summary
+        ActionItems         []ActionItem
+        Decisions           []Decision
+        OpenQuestions       []OpenQuestion
+        // Speakers labeled automatically from enrolled voices (original label -> name)
+        RecognizedSpeakers  map[string]string
+        // Voice embedding per current speaker label, used to enroll speakers
+        SpeakerEmbeddings   map[string][]float64 `json:"-"`
+        ErrorMsg            string
 }
 
 // Task represents a processing task
 type Task struct {
-        ID       string
-        FileName string
-        Status   string
-        Result   Result
+        ID            string
+        TokenHash     string        `json:"-"` // SHA-256 of the access token returned on upload
+        OwnerID       string        `json:",omitempty"` // user who uploaded the meeting, empty for meetings from before accounts
+        TeamID        string        `json:",omitempty"` // team the meeting is shared with
+        FileName      string
+        Status        string
+        Meeting       Meeting
+        Result        Result
+        CreatedAt     time.Time
+        ExpiresAt     *time.Time    `json:",omitempty"` // set when the task finishes, nil if kept until deleted
+        TTL           time.Duration `json:"-"`         // requested lifetime of the result, 0 for the server default
+        Transcription bool          `json:"-"`         // only transcribed for /v1/audio/transcriptions, never summarized or archived
+}
+
+// TaskInfo is a task without its result, as returned by the API's task resources
+type TaskInfo struct {
+        ID        string
+        OwnerID   string        `json:",omitempty"`
+        TeamID    string        `json:",omitempty"`
+        Status    string        // waiting, processing, completed or failed
+        Meeting   Meeting
+        CreatedAt *time.Time    `json:",omitempty"` // unknown for meetings archived before it was recorded
+        ExpiresAt *time.Time    `json:",omitempty"`
+}
+
+// SpeakerStats are participation statistics of one speaker, times in seconds
+type SpeakerStats struct {
+        Speaker          string
+        SpeakingTime     float64
+        Share            float64 // fraction of the speaking time of all speakers
+        Turns            int
+        LongestMonologue float64
+        Words            int
+        Interruptions    int     // times the speaker started while someone else was talking
+        OverlapTime      float64 // time spent talking over or being talked over
+}
+
+// Analytics describe the dynamics of a diarized meeting, times in seconds
+type Analytics struct {
+        Duration      float64
+        SpeechTime    float64
+        SilenceRatio  float64
+        Turns         int
+        Interruptions int
+        Speakers      []SpeakerStats
+}
+
+// APIError describes a failed request, Code is stable and meant for programs, Message for people
+type APIError struct {
+        Code    string `json:"code"`
+        Message string `json:"message"`
+}
+
+// ErrorResponse is the body of every error response: {"error": {"code": ..., "message": ...}}
+type ErrorResponse struct {
+        Error APIError `json:"error"`
 }
@@ -1,12 +1,26 @@
 #!/usr/bin/env python3
 
+import argparse
+import atexit
+import functools
+import json
 import os
 import sys
+import time
 from openai import OpenAI
 import requests
 import re
 
-client = OpenAI(base_url = "http://127.0.0.1:8000/v1", api_key="akhfbsaeklg")
+# The llama server started by the Go server, --server-url overrides it
+LLAMA_SERVER_URL = "http://127.0.0.1:8000"
+
+client = OpenAI(base_url = LLAMA_SERVER_URL + "/v1", api_key="akhfbsaeklg")
+
+def use_server(url):
+    """Talk to the llama server at url instead of the default one"""
+    global LLAMA_SERVER_URL, client
+    LLAMA_SERVER_URL = url.rstrip("/")
+    client = OpenAI(base_url = LLAMA_SERVER_URL + "/v1", api_key="akhfbsaeklg")
 
 TIME_RANGE_PATTERN = re.compile(r"(\d{2}:\d{2}\.\d{3}) --> (\d{2}:\d{2}\.\d{3})")
 
@@ -101,123 +115,430 @@ def condense_vtt_transcript(filepath):
 
         return condensed_transcript
 
-# TODO XXX:
-# expose tokenize() llamacpp function as API and use here
-# for now - approximate
-def stub_token_count(text):
+# Context size of the llama server (--n_ctx) and room kept for the generated text
+DEFAULT_N_CTX = 25000
+MAX_OUTPUT_TOKENS = 8192
+# Margin for the chat template wrapped around the prompt and tokenizer differences
+CONTEXT_SAFETY_MARGIN = 512
+
+_tokenizer_warned = False
+
+# Every count is an HTTP request, transcript turns are counted again by the extraction pass
+@functools.lru_cache(maxsize=None)
+def count_tokens(text):
+    """
+    Count tokens with the model tokenizer of the running llama server.
+    Tries the llama-cpp-python endpoint first, then the llama.cpp server one.
+    If neither is available fall back to a conservative estimate.
+    """
+    global _tokenizer_warned
+    endpoints = (
+        ("/extras/tokenize/count", {"input": text}, lambda result: result["count"]),
+        ("/tokenize", {"content": text}, lambda result: len(result["tokens"])),
+    )
+    for path, body, parse in endpoints:
+        try:
+            response = requests.post(f"{LLAMA_SERVER_URL}{path}", json=body, timeout=60)
+            if response.status_code == 200:
+                return parse(response.json())
+        except (requests.RequestException, ValueError, KeyError) as e:
+            if not _tokenizer_warned:
+                print(f"Tokenizer request to {path} failed: {e}", file=sys.stderr)
+    if not _tokenizer_warned:
+        print("Warning: tokenizer endpoint unavailable, estimating token counts", file=sys.stderr)
+        _tokenizer_warned = True
+    return estimate_token_count(text)
+
+def estimate_token_count(text):
     """
-    Stub function to compute approximate tokens.
-    For now, just guess ~1 token per word as a placeholder.
+    Rough upper bound for token count: ~3 characters per token covers most
+    non-English text and code better than counting words
     """
-    return len(text.split())
+    return max(len(text.split()), (len(text) + 2) // 3)
 
-def chunk_transcript(transcript, total_tokens, max_tokens_per_chunk, big_speech_len):
-    chunks = []
+def split_long_turn(turn, max_tokens):
+    """
+    Split a single speaker turn that does not fit into one chunk at sentence (or word)
+    boundaries, returns (part, token count) pairs
+    """
+    # Keep the time range / speaker label prefix on every piece
+    header, sep, speech = turn.rpartition(']: ')
+    prefix = header + sep
+    if not sep:
+        prefix, speech = '', turn
+
+    # Every piece is counted once and parts are packed by the sum of their pieces' counts,
+    # with a token for each separating space
+    prefix_tokens = count_tokens(prefix) if prefix else 0
+    budget = max(1, max_tokens - prefix_tokens)
+    parts = []
+    current, current_tokens = [], 0
+    for piece, tokens in split_text(speech, budget):
+        if current and current_tokens + 1 + tokens > budget:
+            parts.append((prefix + ' '.join(current), prefix_tokens + current_tokens))
+            current, current_tokens = [], 0
+        current_tokens += tokens + (1 if current else 0)
+        current.append(piece)
+    if current:
+        parts.append((prefix + ' '.join(current), prefix_tokens + current_tokens))
+    return parts
+
+def split_text(text, max_tokens):
+    """
+    Split text into (piece, token count) pairs of at most max_tokens each, at sentence
+    boundaries, then at words, and a word that is too long on its own in halves
+    """
+    tokens = count_tokens(text)
+    if tokens <= max_tokens:
+        return [(text, tokens)]
+    pieces = re.split(r'(?<=[.!?])\s+', text)
+    if len(pieces) == 1:
+        pieces = text.split()
+    if len(pieces) == 1:
+        middle = len(text) // 2
+        if middle == 0:
+            return [(text, tokens)]  # a single character, nothing left to split
+        pieces = [text[:middle], text[middle:]]
+    return [pair for piece in pieces for pair in split_text(piece, max_tokens)]
+
+def chunk_transcript(turns, max_tokens_per_chunk):
+    """
+    Pack condensed transcript turns into chunks of at most max_tokens_per_chunk
+    model tokens, only ever breaking between speaker turns (unless a single
+    turn is too long on its own). Chunks are balanced so that the last one
+    is not just a small remainder.
+    """
+    turn_tokens = []
+    for turn in turns:
+        tokens = count_tokens(turn)
+        if tokens > max_tokens_per_chunk:
+            turn_tokens.extend(split_long_turn(turn, max_tokens_per_chunk))
+        else:
+            turn_tokens.append((turn, tokens))
+
+    separator_tokens = 1
+    total_tokens = sum(tokens + separator_tokens for _, tokens in turn_tokens)
+    print(f"Transcript token count: {total_tokens}, max tokens per chunk: {max_tokens_per_chunk}", file=sys.stderr)
     if total_tokens <= max_tokens_per_chunk:
-        chunks.append(transcript)
-        return chunks
-
-    # Attempt naive chunking by line
-    chunks_num = total_tokens // max_tokens_per_chunk
-    if total_tokens % max_tokens_per_chunk > 0:
-        chunks_num += 1
-
-    lines = transcript.split('\n')
-
-    # Calculate the initial position of line separators, dividing the text evenly
-    # Note: first separator is always at index 0
-    # NOTE: the following divides according to lines, not tokens
-    # If there is just one speaker, this will not work well, but hopefully single-speaker videos are
-    # small enough to fit in max_tokens_per_chunk as one chunk in the first condition in this function
-    line_separators = [i * (len(lines) // chunks_num) for i in range(chunks_num)]
-
-    # Adjust the line separators in reverse
-    for i in range(len(line_separators) - 1, 0, -1):
-        sep = line_separators[i]
-        # Attempt to ensure large lines remain in one chunk
-        while sep < len(lines) - 1 and len(lines[sep].split(':', 1)[-1]) < big_speech_len:
-            sep += 1	# Move forward to find a big speech
-            if i < len(line_separators) - 1 and sep >= line_separators[i + 1]:	# Ensure not to cross the next separator
-                sep = line_separators[i + 1] - 1	# Adjust to not cross over
-                break
-        line_separators[i] = sep
-    # No need to adjust the first separator (i=0), it should always remain as 0
-
-    # Split transcript into chunks
-    for i in range(len(line_separators) - 1):
-        chunk = '\n'.join(lines[line_separators[i]:line_separators[i + 1]])
-        chunks.append(chunk)
-
-    # Add the last chunk
-    chunks.append('\n'.join(lines[line_separators[-1]:]))
-
-    print(f"Total number of lines in transcript: {len(lines)}, chunks_num={len(chunks)}", file=sys.stderr)
-    print(f"Line separators: {line_separators}", file=sys.stderr)
+        return ['\n'.join(turn for turn, _ in turn_tokens)]
+
+    chunks_num = -(-total_tokens // max_tokens_per_chunk)
+    target_tokens = total_tokens / chunks_num
+
+    chunks = []
+    current = []
+    current_tokens = 0
+    for turn, tokens in turn_tokens:
+        if current and (current_tokens + tokens > max_tokens_per_chunk or current_tokens >= target_tokens):
+            chunks.append('\n'.join(current))
+            current = []
+            current_tokens = 0
+        current.append(turn)
+        current_tokens += tokens + separator_tokens
+    if current:
+        chunks.append('\n'.join(current))
+
+    print(f"Number of turns: {len(turn_tokens)}, chunks_num={len(chunks)}", file=sys.stderr)
     return chunks
 
+def chunk_token_budget(n_ctx, prompt):
+    """Tokens left for transcript text once the prompt and the output are accounted for"""
+    budget = n_ctx - count_tokens(prompt) - MAX_OUTPUT_TOKENS - CONTEXT_SAFETY_MARGIN
+    if budget <= 0:
+        print(f"Error: prompt does not fit into context of {n_ctx} tokens", file=sys.stderr)
+        sys.exit(1)
+    return budget
+
+# Used when the caller does not pass its own prompt file
+DEFAULT_PREPROMPT = (
+    "Write a detailed summary of the following transcript from a work meeting. "
+    "Organize the content into clear, chronological paragraphs that maintain a natural narrative flow. "
+    "Make sure to include all important details, technical insights, and notable terms, "
+    "suitable for a technical reader. Ensure to integrate the contributions of all speakers, "
+    "omitting only minor interjections. The summary should provide a comprehensive and detailed "
+    "overview that logically progresses through the discussions, targeted at two pages in length.\n\n"
+)
+
 def print_text_var(textvar, label):
     #DEBUG FUNC
     #print(f"\nPRINTING {label} START:\n{textvar}\nPRINTING {label} END.\n")
     pass
 
-def run_summarization_pipeline(transcript_file_path):
+# Merges summaries of consecutive transcript parts in map-reduce mode,
+# the template prompt is appended so the final document keeps the requested style
+REDUCE_PREPROMPT = (
+    "The following texts are summaries of consecutive parts of one work meeting, in chronological order. "
+    "Merge them into a single coherent document without repeating information, keeping all important "
+    "details, decisions and the contributions of all speakers. "
+    "Do not mention that the input was split into parts. "
+    "Follow these instructions for the style and length of the result:\n\n"
+)
+
+SUMMARY_MODES = ("map_reduce", "concat")
+
+# Token usage of all completions, written to --usage-out for the server's metrics.
+# Every call is listed with its timing too, the server turns them into trace spans.
+usage = {"prompt_tokens": 0, "completion_tokens": 0, "calls": []}
+
+def create_completion(name, **kwargs):
+    """Call the llama-cpp server, recording the call as name (e.g. "chunk 3") in the usage"""
+    call = {"name": name, "start": time.time()}
+    usage["calls"].append(call)
+    try:
+        response = client.chat.completions.create(
+            model="any-model-name-here",  # Llama-cpp doesn't strictly use this, but let's put something
+            **kwargs,
+        )
+    except Exception as e:
+        call["error"] = str(e)
+        raise
+    finally:
+        call["end"] = time.time()
+    if response.usage is not None:
+        call["prompt_tokens"] = response.usage.prompt_tokens or 0
+        call["completion_tokens"] = response.usage.completion_tokens or 0
+        usage["prompt_tokens"] += call["prompt_tokens"]
+        usage["completion_tokens"] += call["completion_tokens"]
+    return response
+
+def write_usage(path):
+    with open(path, 'w') as usage_file:
+        json.dump(usage, usage_file)
+
+def complete(prompt, name):
+    """Run a single chat completion against the llama-cpp server and return the text"""
+    messages = [
+        {"role": "user", "content": prompt}
+    ]
+    try:
+        response = create_completion(
+            name,
+            messages=messages,
+            temperature=0.7,
+            max_tokens=MAX_OUTPUT_TOKENS,
+        )
+        return response.choices[0].message.content
+    except Exception as e:
+        print("Error calling openai.ChatCompletion:", str(e), file=sys.stderr)
+        sys.exit(1)
+
+def group_summaries(summaries, max_tokens_per_group):
+    """Split summaries into consecutive groups that each fit in max_tokens_per_group"""
+    groups = []
+    current_group = []
+    current_tokens = 0
+    for summary in summaries:
+        tokens = count_tokens(summary) + 2
+        if current_group and current_tokens + tokens > max_tokens_per_group:
+            groups.append(current_group)
+            current_group = []
+            current_tokens = 0
+        current_group.append(summary)
+        current_tokens += tokens
+    if current_group:
+        groups.append(current_group)
+    return groups
+
+def truncate_text(text, max_tokens):
+    """Cut text down to at most max_tokens, at sentence (or word) boundaries"""
+    kept, kept_tokens = [], 0
+    for piece, tokens in split_text(text, max_tokens):
+        if kept_tokens + tokens + (1 if kept else 0) > max_tokens:
+            break
+        kept_tokens += tokens + (1 if kept else 0)
+        kept.append(piece)
+    return ' '.join(kept)
+
+def reduce_summaries(summaries, preprompt, n_ctx):
+    """
+    Merge chunk summaries into one document. If they don't fit into a single
+    context together, merge them in groups and repeat on the merged results.
+    """
+    max_tokens_per_group = chunk_token_budget(n_ctx, REDUCE_PREPROMPT + preprompt)
+    # Any two summaries must fit into one group, or the reduction could not progress
+    max_tokens_per_summary = max(1, max_tokens_per_group // 2 - 2)
+    summaries = list(summaries)
+    level = 1
+    while len(summaries) > 1:
+        for i, summary in enumerate(summaries):
+            if count_tokens(summary) > max_tokens_per_summary:
+                print(f"Warning: truncating summary {i + 1} of reduce level {level} to {max_tokens_per_summary} tokens", file=sys.stderr)
+                summaries[i] = truncate_text(summary, max_tokens_per_summary)
+        groups = group_summaries(summaries, max_tokens_per_group)
+        print(f"Reduce level {level}: {len(summaries)} summaries in {len(groups)} group(s)", file=sys.stderr)
+
+        merged = []
+        for n, group in enumerate(groups, start=1):
+            if len(group) == 1:
+                merged.append(group[0])
+                continue
+            parts = "\n\n".join(f"PART {i} OF {len(group)}:\n{text}" for i, text in enumerate(group, start=1))
+            merged.append(complete(REDUCE_PREPROMPT + preprompt + parts, f"reduce {level}.{n}"))
+        summaries = merged
+        level += 1
+    return summaries[0]
+
+# Structured extraction of action items, decisions and open questions
+EXTRACTION_PREPROMPT = (
+    "Extract structured information from the following transcript of a work meeting. "
+    "Each transcript block starts with its time range, use the start time of the block where an item "
+    "is mentioned as its timestamp (empty if the transcript has no times).\n"
+    "- action_items: tasks someone agreed or was asked to do, with the owner (speaker name or label, "
+    "empty if unknown), the task and the due date exactly as mentioned (empty if none)\n"
+    "- decisions: decisions that were made\n"
+    "- open_questions: questions or issues that were raised but not resolved\n"
+    "Only include items that are actually present in the transcript. Answer with JSON only.\n\n"
+)
+
+EXTRACTION_SCHEMA = {
+    "type": "object",
+    "properties": {
+        "action_items": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "properties": {
+                    "owner": {"type": "string"},
+                    "task": {"type": "string"},
+                    "due_date": {"type": "string"},
+                    "timestamp": {"type": "string"},
+                },
+                "required": ["owner", "task", "due_date", "timestamp"],
+            },
+        },
+        "decisions": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "properties": {
+                    "text": {"type": "string"},
+                    "timestamp": {"type": "string"},
+                },
+                "required": ["text", "timestamp"],
+            },
+        },
+        "open_questions": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "properties": {
+                    "text": {"type": "string"},
+                    "timestamp": {"type": "string"},
+                },
+                "required": ["text", "timestamp"],
+            },
+        },
+    },
+    "required": ["action_items", "decisions", "open_questions"],
+}
+
+def complete_json(prompt, schema, name):
+    """Run a chat completion constrained to the given JSON schema, returns the parsed object or None"""
+    messages = [
+        {"role": "user", "content": prompt}
+    ]
+    try:
+        response = create_completion(
+            name,
+            messages=messages,
+            temperature=0.2,
+            max_tokens=MAX_OUTPUT_TOKENS,
+            response_format={"type": "json_object", "schema": schema},
+        )
+        return json.loads(response.choices[0].message.content)
+    except Exception as e:
+        print("Error extracting structured items:", str(e), file=sys.stderr)
+        return None
+
+def extract_items(chunks):
+    """
+    Extract action items, decisions and open questions from every chunk.
+    Returns them with the field names of the Go types.Result structs.
+    """
+    items = {"ActionItems": [], "Decisions": [], "OpenQuestions": []}
+    seen = set()
+
+    def add(kind, key, entry):
+        if not key or (kind, key.lower()) in seen:
+            return
+        seen.add((kind, key.lower()))
+        items[kind].append(entry)
+
+    for i, chunk_text in enumerate(chunks, start=1):
+        extracted = complete_json(EXTRACTION_PREPROMPT + chunk_text, EXTRACTION_SCHEMA, f"extract {i}")
+        if extracted is None:
+            print(f"Skipping structured extraction for chunk {i}", file=sys.stderr)
+            continue
+        for item in extracted.get("action_items", []):
+            task = item.get("task", "").strip()
+            add("ActionItems", task, {
+                "Owner": item.get("owner", "").strip(),
+                "Task": task,
+                "DueDate": item.get("due_date", "").strip(),
+                "Timestamp": item.get("timestamp", "").strip(),
+            })
+        for kind, field in (("Decisions", "decisions"), ("OpenQuestions", "open_questions")):
+            for item in extracted.get(field, []):
+                text = item.get("text", "").strip()
+                add(kind, text, {"Text": text, "Timestamp": item.get("timestamp", "").strip()})
+    return items
+
+def run_summarization_pipeline(transcript_file_path, preprompt=None, mode="map_reduce", n_ctx=DEFAULT_N_CTX, extract=False):
+    """Returns the final summary, the list of per-chunk summaries and the extracted items (None unless extract)"""
     # 1) Condense transcript
     condensed_lines = condense_vtt_transcript(transcript_file_path)
 
-    # For chunking & summarization steps, just join with newlines
-    condensed = '\n'.join(condensed_lines)
-    print_text_var(condensed, "condensed")
+    print_text_var('\n'.join(condensed_lines), "condensed")
+    if preprompt is None:
+        preprompt = DEFAULT_PREPROMPT
 
-    # 2) Stubbed token length
-    transcript_token_length = stub_token_count(condensed)
-    print("Approximate token length:", transcript_token_length, file=sys.stderr)
+    # 2) Token budget per chunk - what is left of the context after prompt and output
+    max_tokens_per_chunk = chunk_token_budget(n_ctx, preprompt)
 
-    # 3) Chunk transcript
-    max_tokens_per_chunk = 22000
-    big_speech_len = 100
-    chunks = chunk_transcript(condensed, transcript_token_length, max_tokens_per_chunk, big_speech_len)
+    # 3) Chunk transcript at speaker turns using the model tokenizer
+    chunks = chunk_transcript(condensed_lines, max_tokens_per_chunk)
     print_text_var(str(len(chunks)), "len of chunks")
 
-    # 4) Summarize each chunk with OpenAI-compatible llama-cpp server
-    preprompt = (
-        "Write a detailed summary of the following transcript from a work meeting. "
-        "Organize the content into clear, chronological paragraphs that maintain a natural narrative flow. "
-        "Make sure to include all important details, technical insights, and notable terms, "
-        "suitable for a technical reader. Ensure to integrate the contributions of all speakers, "
-        "omitting only minor interjections. The summary should provide a comprehensive and detailed "
-        "overview that logically progresses through the discussions, targeted at two pages in length.\n\n"
-    )
-
-    final_summary = ""
+    # 4) Summarize each chunk with OpenAI-compatible llama-cpp server (map)
 
+    chunk_summaries = []
     for i, chunk_text in enumerate(chunks, start=1):
         print_text_var(chunk_text, f"CHUNK {i}")
-        messages = [
-            {"role": "user", "content": preprompt + chunk_text}
-        ]
-        try:
-            response = client.chat.completions.create(
-                model="any-model-name-here",  # Llama-cpp doesn't strictly use this, but let's put something
-                messages=messages,
-                temperature=0.7,
-                max_tokens=8192,
-            )
-            chunk_summary = response.choices[0].message.content
-            final_summary += chunk_summary + "\n\n"
-        except Exception as e:
-            print("Error calling openai.ChatCompletion:", str(e), file=sys.stderr)
-            sys.exit(1)
+        chunk_summaries.append(complete(preprompt + chunk_text, f"chunk {i}"))
+
+    # 5) Combine chunk summaries into the final summary (reduce)
+    if mode == "concat" or len(chunk_summaries) == 1:
+        final_summary = "".join(summary + "\n\n" for summary in chunk_summaries)
+    else:
+        final_summary = reduce_summaries(chunk_summaries, preprompt, n_ctx)
 
     print_text_var(final_summary, "FINAL SUMMARY")
-    return final_summary
 
-def main():
-    if len(sys.argv) != 2:
-        print("Error: Invalid number of arguments.", file=sys.stderr)
-        print("Usage: python generate_ai_summary.py <transcript.vtt>", file=sys.stderr)
-        sys.exit(1)
+    # 6) Structured action items, decisions and open questions
+    items = None
+    if extract:
+        extraction_chunks = chunk_transcript(condensed_lines, chunk_token_budget(n_ctx, EXTRACTION_PREPROMPT))
+        items = extract_items(extraction_chunks)
 
-    vtt_transcript_file_path = sys.argv[1]
+    return final_summary, chunk_summaries, items
+
+def main():
+    parser = argparse.ArgumentParser(description="Summarize a .vtt meeting transcript")
+    parser.add_argument("transcript", help="path to the .vtt transcript")
+    parser.add_argument("preprompt", nargs="?", help="optional file with the summary prompt")
+    parser.add_argument("--mode", choices=SUMMARY_MODES, default="map_reduce",
+                        help="map_reduce merges chunk summaries into one document, concat joins them")
+    parser.add_argument("--chunks-out", help="write the per-chunk summaries to this file as a JSON list")
+    parser.add_argument("--n-ctx", type=int, default=DEFAULT_N_CTX, help="context size of the llama server")
+    parser.add_argument("--extract-out", help="extract action items, decisions and open questions into this JSON file")
+    parser.add_argument("--usage-out", help="write the token usage of all completions to this file as JSON")
+    parser.add_argument("--server-url", help=f"root URL of the llama server (default {LLAMA_SERVER_URL})")
+    args = parser.parse_args()
+
+    if args.server_url:
+        use_server(args.server_url)
+
+    vtt_transcript_file_path = args.transcript
     if not vtt_transcript_file_path.endswith('.vtt'):
         print("Error: The provided file is not a .vtt file.", file=sys.stderr)
         sys.exit(1)
@@ -226,9 +547,31 @@ def main():
         print(f"Error: The file {vtt_transcript_file_path} does not exist.", file=sys.stderr)
         sys.exit(1)
 
-    summary = run_summarization_pipeline(vtt_transcript_file_path)
+    # Optional prompt file chosen by the server (summary template)
+    preprompt = None
+    if args.preprompt:
+        try:
+            with open(args.preprompt, 'r') as prompt_file:
+                preprompt = prompt_file.read()
+        except OSError as e:
+            print(f"Error: could not read prompt file {args.preprompt}: {e}", file=sys.stderr)
+            sys.exit(1)
+
+    if args.usage_out:
+        # Also written when a completion fails and the script exits early
+        atexit.register(write_usage, args.usage_out)
+
+    summary, chunk_summaries, items = run_summarization_pipeline(
+        vtt_transcript_file_path, preprompt, args.mode, args.n_ctx, extract=bool(args.extract_out))
+
+    if args.chunks_out:
+        with open(args.chunks_out, 'w') as chunks_file:
+            json.dump(chunk_summaries, chunks_file)
+    if args.extract_out:
+        with open(args.extract_out, 'w') as extract_file:
+            json.dump(items, extract_file)
+
     print(summary)
 
 if __name__ == "__main__":
     main()
-
@@ -0,0 +1,100 @@
+#!/usr/bin/env python3
+
+"""
+Compute one voice embedding per speaker label of a diarized whisperx transcript.
+
+Usage: python speaker_embeddings.py <audio.wav> <transcript.vtt> <output.json>
+
+Writes {"SPEAKER_00": [floats...], ...} to output.json. The embedding of a
+speaker is the mean of the L2-normalized embeddings of its longest segments.
+Needs a HuggingFace token in HF_TOKEN (or hf_token.txt) for the pyannote model.
+"""
+
+import json
+import os
+import re
+import sys
+
+import numpy as np
+import torch
+from pyannote.audio import Inference, Model
+from pyannote.core import Segment
+
+EMBEDDING_MODEL = "pyannote/wespeaker-voxceleb-resnet34-LM"
+TIME_RANGE_PATTERN = re.compile(r"((?:\d{2}:)?\d{2}:\d{2}\.\d{3}) --> ((?:\d{2}:)?\d{2}:\d{2}\.\d{3})")
+SPEAKER_PATTERN = re.compile(r"^\[([^\]]+)\]: ")
+
+# Short segments give noisy embeddings
+MIN_SEGMENT_SECONDS = 1.5
+MAX_SEGMENTS_PER_SPEAKER = 20
+
+def parse_time(value):
+    seconds = 0.0
+    for part in value.split(':'):
+        seconds = seconds * 60 + float(part)
+    return seconds
+
+def speaker_segments(vtt_path):
+    """Returns {label: [(start, end), ...]} from the cue timings of a diarized .vtt"""
+    with open(vtt_path, 'r') as file:
+        lines = file.read().splitlines()
+
+    segments = {}
+    for i, line in enumerate(lines):
+        speaker_match = SPEAKER_PATTERN.match(line)
+        if not speaker_match or i == 0:
+            continue
+        range_match = TIME_RANGE_PATTERN.search(lines[i - 1])
+        if not range_match:
+            continue
+        start, end = (parse_time(t) for t in range_match.groups())
+        if end - start >= MIN_SEGMENT_SECONDS:
+            segments.setdefault(speaker_match.group(1), []).append((start, end))
+    return segments
+
+def hf_token():
+    token = os.environ.get("HF_TOKEN")
+    if not token and os.path.exists("hf_token.txt"):
+        with open("hf_token.txt", 'r') as token_file:
+            token = token_file.read().strip()
+    return token
+
+def main():
+    if len(sys.argv) != 4:
+        print("Usage: python speaker_embeddings.py <audio.wav> <transcript.vtt> <output.json>", file=sys.stderr)
+        sys.exit(1)
+    audio_path, vtt_path, output_path = sys.argv[1:]
+
+    segments = speaker_segments(vtt_path)
+    if not segments:
+        print("No diarized segments found, nothing to embed", file=sys.stderr)
+        with open(output_path, 'w') as output_file:
+            json.dump({}, output_file)
+        return
+
+    device = torch.device("cuda" if torch.cuda.is_available() else "cpu")
+    model = Model.from_pretrained(EMBEDDING_MODEL, use_auth_token=hf_token())
+    inference = Inference(model, window="whole", device=device)
+
+    embeddings = {}
+    for label, speaker_segs in segments.items():
+        longest = sorted(speaker_segs, key=lambda seg: seg[1] - seg[0], reverse=True)[:MAX_SEGMENTS_PER_SPEAKER]
+        vectors = []
+        for start, end in longest:
+            try:
+                vector = np.asarray(inference.crop(audio_path, Segment(start, end))).flatten()
+            except Exception as e:
+                print(f"Skipping segment {start}-{end} of {label}: {e}", file=sys.stderr)
+                continue
+            norm = np.linalg.norm(vector)
+            if norm > 0:
+                vectors.append(vector / norm)
+        if vectors:
+            embeddings[label] = np.mean(vectors, axis=0).tolist()
+        print(f"{label}: embedded {len(vectors)} segment(s)", file=sys.stderr)
+
+    with open(output_path, 'w') as output_file:
+        json.dump(embeddings, output_file)
+
+if __name__ == "__main__":
+    main()